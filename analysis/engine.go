@@ -0,0 +1,249 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// MateScore is the evaluation in centipawns given to forced mates
+const MateScore = 10000
+
+// GameEvaluator evaluates every position of a game
+type GameEvaluator interface {
+	// EvaluateGame returns an evaluation in centipawns for each position of the game, starting with the initial one.
+	// Evaluations are from white's point of view.
+	EvaluateGame(gm *game.Game) ([]int, error)
+}
+
+// DefaultEngineTimeout bounds the evaluation of a game when the engine has no timeout of its own
+const DefaultEngineTimeout = time.Minute
+
+// stopGrace is how long an engine asked to stop has to answer before it is killed
+const stopGrace = 5 * time.Second
+
+// UCIEngine evaluates games with an external engine speaking the Universal Chess Interface, such as Stockfish
+// At most the given number of engines run at once, further evaluations wait for one of them to finish.
+type UCIEngine struct {
+	Path  string
+	Depth int
+	// Timeout bounds the evaluation of a whole game, including the wait for a free engine
+	Timeout time.Duration
+	slots   chan struct{}
+}
+
+// NewUCIEngine returns an evaluator running at most processes instances of the engine binary at the given path,
+// searching to the given depth
+func NewUCIEngine(path string, depth int, processes int) *UCIEngine {
+	if processes < 1 {
+		processes = 1
+	}
+	return &UCIEngine{
+		Path:    path,
+		Depth:   depth,
+		Timeout: DefaultEngineTimeout,
+		slots:   make(chan struct{}, processes),
+	}
+}
+
+// ErrEngineStopped is returned when the engine exits before answering
+var ErrEngineStopped = errors.New("the engine stopped unexpectedly")
+
+// ErrEngineTimeout is returned when a game could not be evaluated before the timeout of the engine
+var ErrEngineTimeout = errors.New("the engine did not evaluate the game in time")
+
+// EvaluateGame starts the engine once and searches each position of the game.
+// At the timeout the engine is asked to stop searching, and it is killed if it does not answer shortly after.
+func (e UCIEngine) EvaluateGame(gm *game.Game) ([]int, error) {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultEngineTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	search, stopSearch := context.WithDeadline(context.Background(), deadline)
+	defer stopSearch()
+	process, killProcess := context.WithDeadline(context.Background(), deadline.Add(stopGrace))
+	defer killProcess()
+	if e.slots != nil {
+		select {
+		case e.slots <- struct{}{}:
+			defer func() { <-e.slots }()
+		case <-search.Done():
+			return nil, ErrEngineTimeout
+		}
+	}
+
+	cmd := exec.CommandContext(process, e.Path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+	session := uciSession{in: stdin, out: bufio.NewScanner(stdout), mu: &sync.Mutex{}}
+	go func() {
+		<-search.Done()
+		if search.Err() == context.DeadlineExceeded {
+			session.send("stop")
+		}
+	}()
+	evaluations, err := e.evaluateAll(session, gm, search)
+	if err != nil && search.Err() == context.DeadlineExceeded {
+		return nil, ErrEngineTimeout
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.send("quit")
+	return evaluations, nil
+}
+
+// evaluateAll searches the positions of the game one after the other until the search is done
+func (e UCIEngine) evaluateAll(session uciSession, gm *game.Game, search context.Context) ([]int, error) {
+	session.send("uci")
+	if err := session.waitFor("uciok"); err != nil {
+		return nil, err
+	}
+	session.send("isready")
+	if err := session.waitFor("readyok"); err != nil {
+		return nil, err
+	}
+	evaluations := []int{}
+	for _, position := range gm.Positions() {
+		if search.Err() != nil {
+			return nil, search.Err()
+		}
+		evaluation, err := e.evaluate(session, position)
+		if err != nil {
+			return nil, err
+		}
+		evaluations = append(evaluations, evaluation)
+	}
+	return evaluations, nil
+}
+
+func (e UCIEngine) evaluate(session uciSession, position *chess.Position) (int, error) {
+	switch position.Status() {
+	case chess.Checkmate:
+		if position.Turn() == chess.White {
+			return -MateScore, nil
+		}
+		return MateScore, nil
+	case chess.Stalemate:
+		return 0, nil
+	}
+	session.send("position fen " + position.String())
+	session.send(fmt.Sprintf("go depth %v", e.Depth))
+	score := 0
+	for session.out.Scan() {
+		line := session.out.Text()
+		if strings.HasPrefix(line, "bestmove") {
+			// Engines report the score for the side to move
+			if position.Turn() == chess.Black {
+				score = -score
+			}
+			return score, nil
+		}
+		if parsed, ok := parseScore(line); ok {
+			score = parsed
+		}
+	}
+	return 0, ErrEngineStopped
+}
+
+// parseScore reads the score of an "info" line, e.g. "info depth 12 score cp 35 ..." or "info ... score mate -3"
+func parseScore(line string) (int, bool) {
+	fields := strings.Fields(line)
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i] != "score" {
+			continue
+		}
+		value, err := strconv.Atoi(fields[i+2])
+		if err != nil {
+			return 0, false
+		}
+		switch fields[i+1] {
+		case "cp":
+			return value, true
+		case "mate":
+			if value < 0 {
+				return -MateScore, true
+			}
+			return MateScore, true
+		}
+	}
+	return 0, false
+}
+
+type uciSession struct {
+	in  io.Writer
+	out *bufio.Scanner
+	// mu serializes the commands of the evaluation and the stop sent at the timeout
+	mu *sync.Mutex
+}
+
+func (s uciSession) send(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintln(s.in, command)
+}
+
+func (s uciSession) waitFor(response string) error {
+	for s.out.Scan() {
+		if strings.TrimSpace(s.out.Text()) == response {
+			return nil
+		}
+	}
+	return ErrEngineStopped
+}
+
+// MaterialEvaluator evaluates positions by counting material, it is used when no engine is available
+type MaterialEvaluator struct{}
+
+var materialValues = map[chess.PieceType]int{
+	chess.Queen:  900,
+	chess.Rook:   500,
+	chess.Bishop: 300,
+	chess.Knight: 300,
+	chess.Pawn:   100,
+}
+
+// EvaluateGame returns the material balance of each position, forced mates are scored as such
+func (m MaterialEvaluator) EvaluateGame(gm *game.Game) ([]int, error) {
+	evaluations := []int{}
+	for _, position := range gm.Positions() {
+		evaluation := 0
+		for _, piece := range position.Board().SquareMap() {
+			if piece.Color() == chess.White {
+				evaluation += materialValues[piece.Type()]
+			} else {
+				evaluation -= materialValues[piece.Type()]
+			}
+		}
+		if position.Status() == chess.Checkmate {
+			evaluation = MateScore
+			if position.Turn() == chess.White {
+				evaluation = -MateScore
+			}
+		}
+		evaluations = append(evaluations, evaluation)
+	}
+	return evaluations, nil
+}
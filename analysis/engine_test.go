@@ -0,0 +1,119 @@
+package analysis_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/analysis"
+	"github.com/cjsaylor/chessbot/game"
+)
+
+// fakeEngine answers every search with a score of 30 centipawns for the side to move
+const fakeEngine = `#!/bin/sh
+while read line; do
+	case "$line" in
+		uci) echo "id name fake"; echo "uciok" ;;
+		isready) echo "readyok" ;;
+		go*) echo "info depth 1 score cp 10"; echo "info depth 2 score cp 30 pv e2e4"; echo "bestmove e2e4" ;;
+		quit) exit 0 ;;
+	esac
+done
+`
+
+func TestUCIEngineEvaluatesFromWhitesPointOfView(t *testing.T) {
+	dir, err := ioutil.TempDir("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "engine")
+	if err := ioutil.WriteFile(path, []byte(fakeEngine), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	for _, move := range []string{"f2f3", "e7e5", "g2g4", "d8h4"} {
+		gm.Move(move)
+	}
+	evaluations, err := analysis.NewUCIEngine(path, 1, 1).EvaluateGame(gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{30, -30, 30, -30, -analysis.MateScore}
+	if !reflect.DeepEqual(evaluations, expected) {
+		t.Errorf("expected evaluations %v, got %v", expected, evaluations)
+	}
+}
+
+func TestReviewJudgesMoves(t *testing.T) {
+	review := analysis.NewReview([]int{20, -300, -250, -400, 100, 2000}, game.White)
+	expected := map[int]analysis.Judgement{1: analysis.Blunder, 3: analysis.Mistake, 4: analysis.Blunder}
+	if !reflect.DeepEqual(review.Judgements, expected) {
+		t.Errorf("expected judgements %v, got %v", expected, review.Judgements)
+	}
+	if review.Count(game.Black, analysis.Blunder) != 1 || review.Count(game.White, analysis.Blunder) != 1 {
+		t.Errorf("expected one blunder per side, got %v", review.Judgements)
+	}
+	if review.Evaluations[5] != 1000 || review.AverageLoss[game.Black] != 275 {
+		t.Errorf("expected bounded evaluations and an average loss of 275 for black, got %v and %v", review.Evaluations, review.AverageLoss)
+	}
+}
+
+// slowEngine logs every start and only answers a search once it is asked to stop
+const slowEngine = `#!/bin/sh
+echo started >> "$(dirname "$0")/starts"
+while read line; do
+	case "$line" in
+		uci) echo "uciok" ;;
+		isready) echo "readyok" ;;
+		stop) echo "info depth 1 score cp 10"; echo "bestmove e2e4" ;;
+		quit) exit 0 ;;
+	esac
+done
+`
+
+func TestUCIEngineStopsAtTimeoutAndLimitsProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "engine")
+	if err := ioutil.WriteFile(path, []byte(slowEngine), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.Move("e2e4")
+	engine := analysis.NewUCIEngine(path, 1, 1)
+	engine.Timeout = 500 * time.Millisecond
+	waiting := *engine
+	waiting.Timeout = 100 * time.Millisecond
+
+	started := time.Now()
+	errs := make(chan error, 2)
+	go func() {
+		_, err := engine.EvaluateGame(gm)
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		_, err := waiting.EvaluateGame(gm)
+		errs <- err
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != analysis.ErrEngineTimeout {
+			t.Errorf("expected both evaluations to time out, got %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("expected the engine to stop when asked, it took %v", elapsed)
+	}
+	starts, _ := ioutil.ReadFile(filepath.Join(dir, "starts"))
+	if strings.Count(string(starts), "started") != 1 {
+		t.Errorf("expected the second evaluation to wait for the only engine, got %q", starts)
+	}
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import "github.com/cjsaylor/chessbot/game"
+
+// Judgement classifies a move by how much of the evaluation it gave away
+type Judgement string
+
+// Mistake and Blunder are moves losing at least MistakeThreshold and BlunderThreshold centipawns
+const (
+	Mistake          Judgement = "mistake"
+	Blunder          Judgement = "blunder"
+	MistakeThreshold           = 100
+	BlunderThreshold           = 300
+)
+
+// reviewCap bounds evaluations so that moves in already decided positions are not judged by huge swings
+const reviewCap = 1000
+
+// Review summarizes where a game turned based on the evaluation of each position
+type Review struct {
+	// Evaluations in centipawns from white's point of view, bounded to +/- 1000
+	Evaluations []int
+	// Judgements of the moves leading to a position, keyed by its index in Evaluations
+	Judgements map[int]Judgement
+	// AverageLoss is the average centipawn loss of the moves of each side
+	AverageLoss map[game.Color]int
+	firstMover  game.Color
+}
+
+// NewReview judges the moves between consecutive evaluations of a game whose first move was made by firstMover
+func NewReview(evaluations []int, firstMover game.Color) Review {
+	review := Review{
+		Judgements:  map[int]Judgement{},
+		AverageLoss: map[game.Color]int{},
+		firstMover:  firstMover,
+	}
+	for _, evaluation := range evaluations {
+		if evaluation > reviewCap {
+			evaluation = reviewCap
+		} else if evaluation < -reviewCap {
+			evaluation = -reviewCap
+		}
+		review.Evaluations = append(review.Evaluations, evaluation)
+	}
+	totals, moves := map[game.Color]int{}, map[game.Color]int{}
+	for ply := 1; ply < len(review.Evaluations); ply++ {
+		color := review.Mover(ply)
+		loss := review.Evaluations[ply-1] - review.Evaluations[ply]
+		if color == game.Black {
+			loss = -loss
+		}
+		if loss < 0 {
+			loss = 0
+		}
+		totals[color] += loss
+		moves[color]++
+		switch {
+		case loss >= BlunderThreshold:
+			review.Judgements[ply] = Blunder
+		case loss >= MistakeThreshold:
+			review.Judgements[ply] = Mistake
+		}
+	}
+	for color, count := range moves {
+		review.AverageLoss[color] = totals[color] / count
+	}
+	return review
+}
+
+// Count returns how many moves of the side received the judgement
+func (r Review) Count(color game.Color, judgement Judgement) int {
+	count := 0
+	for ply, j := range r.Judgements {
+		if j == judgement && r.Mover(ply) == color {
+			count++
+		}
+	}
+	return count
+}
+
+// Mover returns the side which made the move leading to the position at the index of Evaluations
+func (r Review) Mover(ply int) game.Color {
+	if (ply%2 == 1) == (r.firstMover == game.White) {
+		return game.White
+	}
+	return game.Black
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	apiTokensBucket   = []byte("api_tokens")
+	botAccountsBucket = []byte("bot_accounts")
+)
+
+// BoltStore implements the TokenStorage and BotStorage interfaces and persists in an embedded bolt database.
+// Like the sqlite store, only hashes of the tokens are persisted.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore creates (if not exists) the buckets of API tokens and bot accounts in the database.
+// The database can be shared with the bolt stores of other packages.
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{apiTokensBucket, botAccountsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// get decodes the value stored under the key into value, or returns false when there is none
+func (b *BoltStore) get(bucket []byte, key string, value interface{}) (bool, error) {
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(bucket).Get([]byte(key))
+		if stored == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(stored, value)
+	})
+	return found, err
+}
+
+// StoreToken stores an API token granted to a workspace member
+func (b *BoltStore) StoreToken(owner TokenOwner, token string) error {
+	return b.storeTokenHash(hashToken(token), owner)
+}
+
+func (b *BoltStore) storeTokenHash(hash string, owner TokenOwner) error {
+	value, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiTokensBucket).Put([]byte(hash), value)
+	})
+}
+
+// OwnerByToken retrieves the workspace member an API token was granted to
+func (b *BoltStore) OwnerByToken(token string) (*TokenOwner, error) {
+	owner := TokenOwner{}
+	found, err := b.get(apiTokensBucket, hashToken(token), &owner)
+	if err == nil && !found {
+		err = errors.New("API token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &owner, nil
+}
+
+// StoreBot stores a bot account along with the token it authenticates with, replacing its previous token
+func (b *BoltStore) StoreBot(bot BotAccount, token string) error {
+	return b.storeBotHash(hashToken(token), bot)
+}
+
+func (b *BoltStore) storeBotHash(hash string, bot BotAccount) error {
+	value, err := json.Marshal(bot)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(botAccountsBucket)
+		replaced := [][]byte{}
+		err := bucket.ForEach(func(key []byte, stored []byte) error {
+			existing := BotAccount{}
+			if err := json.Unmarshal(stored, &existing); err != nil {
+				return err
+			}
+			if existing.WorkspaceID != bot.WorkspaceID || existing.ID != bot.ID {
+				return nil
+			}
+			if existing.OwnerID != bot.OwnerID {
+				return ErrBotNameTaken
+			}
+			replaced = append(replaced, append([]byte{}, key...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range replaced {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return bucket.Put([]byte(hash), value)
+	})
+}
+
+// BotByToken retrieves the bot account a token was issued to
+func (b *BoltStore) BotByToken(token string) (*BotAccount, error) {
+	bot := BotAccount{}
+	found, err := b.get(botAccountsBucket, hashToken(token), &bot)
+	if err == nil && !found {
+		err = errors.New("bot token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bot, nil
+}
+
+// RetrieveBot retrieves a bot account of a workspace by its ID
+func (b *BoltStore) RetrieveBot(workspaceID string, botID string) (*BotAccount, error) {
+	var found *BotAccount
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(botAccountsBucket).ForEach(func(key []byte, value []byte) error {
+			bot := BotAccount{}
+			if err := json.Unmarshal(value, &bot); err != nil {
+				return err
+			}
+			if bot.WorkspaceID == workspaceID && bot.ID == botID {
+				found = &bot
+			}
+			return nil
+		})
+	})
+	if err == nil && found == nil {
+		err = errors.New("bot not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// dump returns every API token and bot account of the store by the hash of their token
+func (b *BoltStore) dump() (storedTokens, error) {
+	stored := newStoredTokens()
+	err := b.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(apiTokensBucket).ForEach(func(key []byte, value []byte) error {
+			owner := TokenOwner{}
+			if err := json.Unmarshal(value, &owner); err != nil {
+				return err
+			}
+			stored.tokens[string(key)] = owner
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(botAccountsBucket).ForEach(func(key []byte, value []byte) error {
+			bot := BotAccount{}
+			if err := json.Unmarshal(value, &bot); err != nil {
+				return err
+			}
+			stored.bots[string(key)] = bot
+			return nil
+		})
+	})
+	return stored, err
+}
+
+// ImportSqlite copies every API token and bot account of the sqlite store into the bolt store
+func (b *BoltStore) ImportSqlite(store *SqliteStore) error {
+	stored, err := store.dump()
+	if err != nil {
+		return err
+	}
+	return stored.copyTo(b)
+}
+
+// ExportSqlite copies every API token and bot account of the bolt store into the sqlite store
+func (b *BoltStore) ExportSqlite(store *SqliteStore) error {
+	stored, err := b.dump()
+	if err != nil {
+		return err
+	}
+	return stored.copyTo(store)
+}
+
+// storedTokens is everything kept by a token and bot store, as copied between the bolt and sqlite stores.
+// The tokens themselves are never persisted, so both are keyed by the hash of their token.
+type storedTokens struct {
+	tokens map[string]TokenOwner
+	bots   map[string]BotAccount
+}
+
+func newStoredTokens() storedTokens {
+	return storedTokens{
+		tokens: make(map[string]TokenOwner),
+		bots:   make(map[string]BotAccount),
+	}
+}
+
+func (s storedTokens) copyTo(store interface {
+	storeTokenHash(hash string, owner TokenOwner) error
+	storeBotHash(hash string, bot BotAccount) error
+}) error {
+	for hash, owner := range s.tokens {
+		if err := store.storeTokenHash(hash, owner); err != nil {
+			return err
+		}
+	}
+	for hash, bot := range s.bots {
+		if err := store.storeBotHash(hash, bot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/api"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStoreImportsAndExportsSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := api.NewSqliteStore(filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := api.TokenOwner{WorkspaceID: "T1", UserID: "U1"}
+	bot := api.BotAccount{ID: "stockfish", WorkspaceID: "T1", OwnerID: "U1"}
+	sqlite.StoreToken(owner, "token")
+	sqlite.StoreBot(bot, "bot-token")
+
+	db, err := bolt.Open(filepath.Join(dir, "chessbot.bolt"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, err := api.NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OwnerByToken("token"); err == nil {
+		t.Error("expected an error for a missing token")
+	}
+	if err := store.ImportSqlite(sqlite); err != nil {
+		t.Fatal(err)
+	}
+	if imported, err := store.OwnerByToken("token"); err != nil || *imported != owner {
+		t.Errorf("expected the token to be imported, got %v (%v)", imported, err)
+	}
+	if imported, err := store.BotByToken("bot-token"); err != nil || *imported != bot {
+		t.Errorf("expected the bot to be imported, got %v (%v)", imported, err)
+	}
+
+	if err := store.StoreBot(api.BotAccount{ID: "stockfish", WorkspaceID: "T1", OwnerID: "U2"}, "other-token"); err != api.ErrBotNameTaken {
+		t.Errorf("expected the bot name to be taken, got %v", err)
+	}
+	if err := store.StoreBot(bot, "rotated-token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.BotByToken("bot-token"); err == nil {
+		t.Error("expected the previous bot token to be revoked")
+	}
+	if retrieved, err := store.RetrieveBot("T1", "stockfish"); err != nil || *retrieved != bot {
+		t.Errorf("expected the bot to be retrieved, got %v (%v)", retrieved, err)
+	}
+
+	if err := store.ExportSqlite(sqlite); err != nil {
+		t.Fatal(err)
+	}
+	if exported, err := sqlite.BotByToken("rotated-token"); err != nil || *exported != bot {
+		t.Errorf("expected the rotated token to be exported, got %v (%v)", exported, err)
+	}
+	if _, err := sqlite.BotByToken("bot-token"); err == nil {
+		t.Error("expected the previous bot token to be replaced")
+	}
+	if exported, err := sqlite.OwnerByToken("token"); err != nil || *exported != owner {
+		t.Errorf("expected the token to be exported, got %v (%v)", exported, err)
+	}
+}
@@ -0,0 +1,417 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// KeepAliveInterval is how often an empty line is written to idle event streams
+const KeepAliveInterval = 6 * time.Second
+
+// BotHandler serves the subset of the Lichess Bot API required for external programs to play games as a team member
+// It must be registered as an observer of the game storage to stream game events.
+type BotHandler struct {
+	gameStorage game.GameStorage
+	botStorage  BotStorage
+	mu          sync.Mutex
+	subscribers map[chan *game.Game]bool
+}
+
+// NewBotHandler returns an instance of the bot API handler
+func NewBotHandler(store game.GameStorage, bots BotStorage) *BotHandler {
+	return &BotHandler{
+		gameStorage: store,
+		botStorage:  bots,
+		subscribers: make(map[chan *game.Game]bool),
+	}
+}
+
+// RegisterBot creates a new bot account, or rotates the token of a bot registered by the same owner,
+// and returns the token it authenticates with. ErrBotNameTaken is returned when another member owns the bot.
+func RegisterBot(store BotStorage, bot BotAccount) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	return token, store.StoreBot(bot, token)
+}
+
+type botPlayer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type botGameState struct {
+	Type   string `json:"type"`
+	Moves  string `json:"moves"`
+	WTime  int    `json:"wtime"`
+	BTime  int    `json:"btime"`
+	WInc   int    `json:"winc"`
+	BInc   int    `json:"binc"`
+	Status string `json:"status"`
+	Winner string `json:"winner,omitempty"`
+}
+
+type botVariant struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type botGameFull struct {
+	Type       string       `json:"type"`
+	ID         string       `json:"id"`
+	Rated      bool         `json:"rated"`
+	Variant    botVariant   `json:"variant"`
+	Speed      string       `json:"speed"`
+	White      botPlayer    `json:"white"`
+	Black      botPlayer    `json:"black"`
+	InitialFen string       `json:"initialFen"`
+	State      botGameState `json:"state"`
+}
+
+type botEventGame struct {
+	ID       string `json:"id"`
+	GameID   string `json:"gameId"`
+	Color    string `json:"color"`
+	FEN      string `json:"fen"`
+	IsMyTurn bool   `json:"isMyTurn"`
+}
+
+type botEvent struct {
+	Type string       `json:"type"`
+	Game botEventGame `json:"game"`
+}
+
+type botAccountResponse struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Title    string `json:"title"`
+}
+
+var botStatuses = map[chess.Method]string{
+	chess.NoMethod:             "started",
+	chess.Checkmate:            "mate",
+	chess.Resignation:          "resign",
+	chess.Stalemate:            "stalemate",
+	chess.DrawOffer:            "draw",
+	chess.ThreefoldRepetition:  "draw",
+	chess.FivefoldRepetition:   "draw",
+	chess.FiftyMoveRule:        "draw",
+	chess.SeventyFiveMoveRule:  "draw",
+	chess.InsufficientMaterial: "draw",
+}
+
+// GameStored forwards the stored game to every open stream
+func (b *BotHandler) GameStored(gm *game.Game) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- gm:
+		default:
+			log.Printf("Dropped update of game %v for a slow bot stream", gm.ID)
+		}
+	}
+}
+
+func (b *BotHandler) subscribe() chan *game.Game {
+	subscriber := make(chan *game.Game, 16)
+	b.mu.Lock()
+	b.subscribers[subscriber] = true
+	b.mu.Unlock()
+	return subscriber
+}
+
+func (b *BotHandler) unsubscribe(subscriber chan *game.Game) {
+	b.mu.Lock()
+	delete(b.subscribers, subscriber)
+	b.mu.Unlock()
+}
+
+func (b *BotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bot, err := b.botStorage.BotByToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "No such token")
+		return
+	}
+	segments := []string{}
+	for _, segment := range strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api"), "/"), "/") {
+		unescaped, _ := url.PathUnescape(segment)
+		segments = append(segments, unescaped)
+	}
+	path := strings.Join(segments, "/")
+	switch {
+	case path == "account" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, botAccountResponse{ID: bot.ID, Username: bot.ID, Title: "BOT"})
+	case path == "stream/event" && r.Method == http.MethodGet:
+		b.streamEvents(w, r, bot)
+	case len(segments) == 4 && strings.HasPrefix(path, "bot/game/stream/") && r.Method == http.MethodGet:
+		b.streamGame(w, r, bot, segments[3])
+	case len(segments) == 5 && segments[0] == "bot" && segments[1] == "game" && segments[3] == "move" && r.Method == http.MethodPost:
+		b.move(w, bot, segments[2], segments[4])
+	case len(segments) == 4 && segments[0] == "bot" && segments[1] == "game" && segments[3] == "resign" && r.Method == http.MethodPost:
+		b.resign(w, bot, segments[2])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (b *BotHandler) retrieveBotGame(bot *BotAccount, gameID string) (*game.Game, error) {
+	gm, err := b.gameStorage.RetrieveGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if gm.WorkspaceID != bot.WorkspaceID {
+		return nil, game.ErrPlayerNotFound
+	}
+	if _, err := gm.PlayerByID(bot.ID); err != nil {
+		return nil, game.ErrPlayerNotFound
+	}
+	return gm, nil
+}
+
+func newBotPlayer(player game.Player, bot *BotAccount) botPlayer {
+	if player.HasMember(bot.ID) {
+		return botPlayer{ID: bot.ID, Name: bot.ID}
+	}
+	team := strings.Join(player.Members(), ",")
+	return botPlayer{ID: team, Name: team}
+}
+
+func newBotGameState(gm *game.Game) botGameState {
+	moves := []string{}
+	for _, move := range gm.Moves() {
+		moves = append(moves, move.String())
+	}
+	state := botGameState{
+		Type:   "gameState",
+		Moves:  strings.Join(moves, " "),
+		Status: botStatuses[gm.Method()],
+	}
+	switch gm.Outcome() {
+	case chess.WhiteWon:
+		state.Winner = "white"
+	case chess.BlackWon:
+		state.Winner = "black"
+	}
+	return state
+}
+
+func newBotGameFull(gm *game.Game, bot *BotAccount) botGameFull {
+	return botGameFull{
+		Type:       "gameFull",
+		ID:         gm.ID,
+		Variant:    botVariant{Key: "standard", Name: "Standard"},
+		Speed:      "correspondence",
+		White:      newBotPlayer(gm.Players[game.White], bot),
+		Black:      newBotPlayer(gm.Players[game.Black], bot),
+		InitialFen: "startpos",
+		State:      newBotGameState(gm),
+	}
+}
+
+func newBotEvent(eventType string, gm *game.Game, bot *BotAccount) botEvent {
+	color := "black"
+	if gm.Players[game.White].HasMember(bot.ID) {
+		color = "white"
+	}
+	return botEvent{
+		Type: eventType,
+		Game: botEventGame{
+			ID:       gm.ID,
+			GameID:   gm.ID,
+			Color:    color,
+			FEN:      gm.FEN(),
+			IsMyTurn: gm.Outcome() == chess.NoOutcome && gm.TurnPlayer().HasMember(bot.ID),
+		},
+	}
+}
+
+// ndjsonWriter writes newline delimited JSON, flushing after every line
+type ndjsonWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newNDJSONWriter(w http.ResponseWriter) *ndjsonWriter {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	writer := &ndjsonWriter{w: w, flusher: flusher}
+	writer.flush()
+	return writer
+}
+
+func (n *ndjsonWriter) write(value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := n.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	n.flush()
+	return nil
+}
+
+func (n *ndjsonWriter) keepAlive() error {
+	if _, err := n.w.Write([]byte("\n")); err != nil {
+		return err
+	}
+	n.flush()
+	return nil
+}
+
+func (n *ndjsonWriter) flush() {
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+}
+
+// streamEvents streams the start and finish of every game the bot takes part in
+// Ongoing games are announced as started as soon as the stream is opened.
+func (b *BotHandler) streamEvents(w http.ResponseWriter, r *http.Request, bot *BotAccount) {
+	subscriber := b.subscribe()
+	defer b.unsubscribe(subscriber)
+	stream := newNDJSONWriter(w)
+	started := map[string]bool{}
+	finished := map[string]bool{}
+	if lister, ok := b.gameStorage.(game.GameLister); ok {
+		games, err := lister.ListGames(bot.WorkspaceID)
+		if err != nil {
+			log.Println(err)
+		}
+		for _, gm := range games {
+			if gm.Players[game.White].HasMember(bot.ID) || gm.Players[game.Black].HasMember(bot.ID) {
+				started[gm.ID] = true
+				if gm.Outcome() == chess.NoOutcome {
+					stream.write(newBotEvent("gameStart", gm, bot))
+				}
+			}
+		}
+	}
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if err := stream.keepAlive(); err != nil {
+				return
+			}
+		case gm := <-subscriber:
+			if gm.WorkspaceID != bot.WorkspaceID || (!gm.Players[game.White].HasMember(bot.ID) && !gm.Players[game.Black].HasMember(bot.ID)) {
+				continue
+			}
+			var err error
+			if !started[gm.ID] {
+				started[gm.ID] = true
+				err = stream.write(newBotEvent("gameStart", gm, bot))
+			}
+			if gm.Outcome() != chess.NoOutcome && !finished[gm.ID] && err == nil {
+				finished[gm.ID] = true
+				err = stream.write(newBotEvent("gameFinish", gm, bot))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamGame streams the full game followed by its state after every stored change, until the game is over
+func (b *BotHandler) streamGame(w http.ResponseWriter, r *http.Request, bot *BotAccount, gameID string) {
+	subscriber := b.subscribe()
+	defer b.unsubscribe(subscriber)
+	gm, err := b.retrieveBotGame(bot, gameID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "No such game")
+		return
+	}
+	stream := newNDJSONWriter(w)
+	if err := stream.write(newBotGameFull(gm, bot)); err != nil || gm.Outcome() != chess.NoOutcome {
+		return
+	}
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if err := stream.keepAlive(); err != nil {
+				return
+			}
+		case gm := <-subscriber:
+			if gm.ID != gameID {
+				continue
+			}
+			if err := stream.write(newBotGameState(gm)); err != nil || gm.Outcome() != chess.NoOutcome {
+				return
+			}
+		}
+	}
+}
+
+func (b *BotHandler) move(w http.ResponseWriter, bot *BotAccount, gameID string, uci string) {
+	gm, err := b.retrieveBotGame(bot, gameID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "No such game")
+		return
+	}
+	if gm.Outcome() != chess.NoOutcome {
+		writeError(w, http.StatusBadRequest, game.ErrGameCompleted.Error())
+		return
+	}
+	var moveErr error
+	_, err = game.Update(b.gameStorage, gm, func(gm *game.Game) error {
+		_, moveErr = gm.MoveAs(bot.ID, uci)
+		return moveErr
+	})
+	switch {
+	case moveErr != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case game.IsConflict(err):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, "could not store the game")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (b *BotHandler) resign(w http.ResponseWriter, bot *BotAccount, gameID string) {
+	gm, err := b.retrieveBotGame(bot, gameID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "No such game")
+		return
+	}
+	if gm.Outcome() != chess.NoOutcome {
+		writeError(w, http.StatusBadRequest, game.ErrGameCompleted.Error())
+		return
+	}
+	_, err = game.Update(b.gameStorage, gm, func(gm *game.Game) error {
+		return gm.ResignAs(bot.ID)
+	})
+	if game.IsConflict(err) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, "could not store the game")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
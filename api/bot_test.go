@@ -0,0 +1,155 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/api"
+	"github.com/cjsaylor/chessbot/game"
+)
+
+func openStream(t *testing.T, url string, token string) (*bufio.Scanner, func()) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for %v, got %v", url, resp.StatusCode)
+	}
+	return bufio.NewScanner(resp.Body), func() { resp.Body.Close() }
+}
+
+func nextEvent(t *testing.T, scanner *bufio.Scanner) map[string]interface{} {
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			event := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatal(err)
+			}
+			return event
+		}
+	}
+	t.Fatal("stream closed unexpectedly")
+	return nil
+}
+
+func TestBotPlaysGame(t *testing.T) {
+	bots := api.NewMemoryStore()
+	token, err := api.RegisterBot(bots, api.BotAccount{ID: "stockfish", WorkspaceID: "T1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := game.NewObservedStore(game.NewMemoryStore())
+	handler := api.NewBotHandler(store, bots)
+	store.Observe(handler)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	events, closeEvents := openStream(t, server.URL+"/api/stream/event", token)
+	defer closeEvents()
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " stockfish "})
+	gm.WorkspaceID = "T1"
+	gm.Start()
+	store.StoreGame(gm.ID, gm)
+	if event := nextEvent(t, events); event["type"] != "gameStart" {
+		t.Fatalf("expected a gameStart event, got %v", event)
+	}
+
+	states, closeStates := openStream(t, server.URL+"/api/bot/game/stream/1234", token)
+	defer closeStates()
+	full := nextEvent(t, states)
+	if full["type"] != "gameFull" {
+		t.Fatalf("expected a gameFull event, got %v", full)
+	}
+	if gm.Players[game.Black].HasMember("stockfish") {
+		gm.Move("e2e4")
+		store.StoreGame(gm.ID, gm)
+		if state := nextEvent(t, states); state["moves"] != "e2e4" {
+			t.Fatalf("expected the opponent move to be streamed, got %v", state)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/bot/game/1234/move/d2d3", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gm.Players[game.White].HasMember("stockfish") {
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the bot move to be accepted, got status %v", resp.StatusCode)
+		}
+		if state := nextEvent(t, states); state["moves"] != "d2d3" {
+			t.Errorf("expected the bot move to be streamed, got %v", state)
+		}
+	} else if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected d2d3 to be rejected for black, got status %v", resp.StatusCode)
+	}
+}
+
+func TestBotRequiresToken(t *testing.T) {
+	handler := api.NewBotHandler(game.NewMemoryStore(), api.NewMemoryStore())
+	req := httptest.NewRequest(http.MethodGet, "/api/account", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %v", rec.Code)
+	}
+}
+
+func TestBotNamesAreUniquePerWorkspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sqliteStore, err := api.NewSqliteStore(filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]api.BotStorage{
+		"memory": api.NewMemoryStore(),
+		"sqlite": sqliteStore,
+	}
+	for name, bots := range stores {
+		t.Run(name, func(t *testing.T) {
+			first, err := api.RegisterBot(bots, api.BotAccount{ID: "stockfish", WorkspaceID: "T1", OwnerID: "U1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := api.RegisterBot(bots, api.BotAccount{ID: "stockfish", WorkspaceID: "T1", OwnerID: "U2"}); err != api.ErrBotNameTaken {
+				t.Errorf("expected another member to be refused the bot name, got %v", err)
+			}
+			if _, err := bots.BotByToken(first); err != nil {
+				t.Errorf("expected the refused registration to keep the token of the owner, got %v", err)
+			}
+			if _, err := api.RegisterBot(bots, api.BotAccount{ID: "stockfish", WorkspaceID: "T2", OwnerID: "U2"}); err != nil {
+				t.Errorf("expected the bot name to be free in another workspace, got %v", err)
+			}
+			rotated, err := api.RegisterBot(bots, api.BotAccount{ID: "stockfish", WorkspaceID: "T1", OwnerID: "U1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := bots.BotByToken(first); err == nil {
+				t.Error("expected the rotated token to be revoked")
+			}
+			if bot, err := bots.BotByToken(rotated); err != nil || bot.OwnerID != "U1" {
+				t.Errorf("expected the new token to authenticate the bot, got %v (%v)", bot, err)
+			}
+			if bot, err := bots.RetrieveBot("T1", "stockfish"); err != nil || bot.OwnerID != "U1" {
+				t.Errorf("expected to retrieve the bot by name, got %v (%v)", bot, err)
+			}
+		})
+	}
+}
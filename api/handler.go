@@ -0,0 +1,203 @@
+// Package api exposes the games of a workspace through an authenticated JSON API
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// Handler is an http handler serving the games API under /api/games
+type Handler struct {
+	gameStorage  game.GameStorage
+	tokenStorage TokenStorage
+}
+
+// NewHTTPHandler returns an instance of the games API handler
+func NewHTTPHandler(store game.GameStorage, tokens TokenStorage) *Handler {
+	return &Handler{
+		gameStorage:  store,
+		tokenStorage: tokens,
+	}
+}
+
+// GenerateToken creates a new random API token and stores it for the member of the workspace
+// Moves made with the token are made as that member.
+func GenerateToken(store TokenStorage, workspaceID string, userID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	return token, store.StoreToken(TokenOwner{WorkspaceID: workspaceID, UserID: userID}, token)
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type gameResponse struct {
+	ID        string     `json:"id"`
+	White     []string   `json:"white"`
+	Black     []string   `json:"black"`
+	Turn      game.Color `json:"turn"`
+	Outcome   string     `json:"outcome"`
+	Method    string     `json:"method,omitempty"`
+	FEN       string     `json:"fen"`
+	PGN       string     `json:"pgn"`
+	LastMoved *time.Time `json:"last_moved,omitempty"`
+}
+
+type moveRequest struct {
+	Move string `json:"move"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func newGameResponse(gm *game.Game) gameResponse {
+	response := gameResponse{
+		ID:      gm.ID,
+		White:   gm.Players[game.White].Members(),
+		Black:   gm.Players[game.Black].Members(),
+		Turn:    gm.Turn(),
+		Outcome: gm.Outcome().String(),
+		FEN:     gm.FEN(),
+		PGN:     gm.PGN(),
+	}
+	if gm.Outcome() != chess.NoOutcome {
+		response.Method = gm.Method().String()
+	}
+	if lastMoved := gm.LastMoved(); !lastMoved.IsZero() {
+		response.LastMoved = &lastMoved
+	}
+	return response
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func (a Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	owner, err := a.tokenStorage.OwnerByToken(token)
+	if token == "" || err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid API token")
+		return
+	}
+	workspaceID := owner.WorkspaceID
+	segments := []string{}
+	for _, segment := range strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api/games"), "/"), "/") {
+		if unescaped, err := url.PathUnescape(segment); err == nil && unescaped != "" {
+			segments = append(segments, unescaped)
+		}
+	}
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+		a.listGames(w, workspaceID)
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		if gm := a.retrieveGame(w, workspaceID, segments[0]); gm != nil {
+			writeJSON(w, http.StatusOK, newGameResponse(gm))
+		}
+	case len(segments) == 2 && segments[1] == "moves" && r.Method == http.MethodPost:
+		a.move(w, r, owner, segments[0])
+	case len(segments) <= 2:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (a Handler) listGames(w http.ResponseWriter, workspaceID string) {
+	lister, ok := a.gameStorage.(game.GameLister)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "game storage does not support listing games")
+		return
+	}
+	games, err := lister.ListGames(workspaceID)
+	if err != nil {
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, "could not list games")
+		return
+	}
+	response := []gameResponse{}
+	for _, gm := range games {
+		response = append(response, newGameResponse(gm))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// retrieveGame writes a not found response if the game does not exist within the workspace
+func (a Handler) retrieveGame(w http.ResponseWriter, workspaceID string, gameID string) *game.Game {
+	gm, err := a.gameStorage.RetrieveGame(gameID)
+	if err != nil || gm.WorkspaceID != workspaceID {
+		writeError(w, http.StatusNotFound, "game not found")
+		return nil
+	}
+	return gm
+}
+
+// move plays as the member the token was granted to
+func (a Handler) move(w http.ResponseWriter, r *http.Request, owner *TokenOwner, gameID string) {
+	if owner.UserID == "" {
+		writeError(w, http.StatusForbidden, "this API token was not granted to a member, request a new one to make moves")
+		return
+	}
+	var request moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Move == "" {
+		writeError(w, http.StatusBadRequest, "a move is required")
+		return
+	}
+	gm := a.retrieveGame(w, owner.WorkspaceID, gameID)
+	if gm == nil {
+		return
+	}
+	if gm.Outcome() != chess.NoOutcome {
+		writeError(w, http.StatusConflict, game.ErrGameCompleted.Error())
+		return
+	}
+	var moveErr error
+	gm, err := game.Update(a.gameStorage, gm, func(gm *game.Game) error {
+		_, moveErr = gm.MoveAs(owner.UserID, request.Move)
+		return moveErr
+	})
+	switch {
+	case moveErr == game.ErrNotPlayersTurn || game.IsConflict(err):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case moveErr != nil:
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, "could not store the game")
+		return
+	}
+	writeJSON(w, http.StatusOK, newGameResponse(gm))
+}
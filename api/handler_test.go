@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/api"
+	"github.com/cjsaylor/chessbot/game"
+)
+
+func setupAPI(t *testing.T) (http.Handler, *api.MemoryStore, *game.Game) {
+	tokens := api.NewMemoryStore()
+	store := game.NewMemoryStore()
+	gm := game.NewGame("1234", game.Player{ID: " U1 U2 "}, game.Player{ID: " U3 "})
+	gm.WorkspaceID = "T1"
+	store.StoreGame(gm.ID, gm)
+	other := game.NewGame("5678", game.Player{ID: " U4 "}, game.Player{ID: " U5 "})
+	other.WorkspaceID = "T2"
+	store.StoreGame(other.ID, other)
+	return api.NewHTTPHandler(store, tokens), tokens, gm
+}
+
+func tokenFor(t *testing.T, tokens api.TokenStorage, userID string) string {
+	token, err := api.GenerateToken(tokens, "T1", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func request(handler http.Handler, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIServesConcurrentRequests(t *testing.T) {
+	handler, tokens, _ := setupAPI(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				token, err := api.GenerateToken(tokens, "T1", "U1")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if rec := request(handler, http.MethodGet, "/api/games", token, ""); rec.Code != http.StatusOK {
+					t.Errorf("expected status 200, got %v", rec.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	handler, _, _ := setupAPI(t)
+	if rec := request(handler, http.MethodGet, "/api/games", "invalid", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %v", rec.Code)
+	}
+}
+
+func TestAPIListsWorkspaceGames(t *testing.T) {
+	handler, tokens, _ := setupAPI(t)
+	token := tokenFor(t, tokens, "U9")
+	rec := request(handler, http.MethodGet, "/api/games", token, "")
+	var games []struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(rec.Body).Decode(&games)
+	if len(games) != 1 || games[0].ID != "1234" {
+		t.Errorf("expected only the workspace game, got %v", games)
+	}
+	if rec := request(handler, http.MethodGet, "/api/games/5678", token, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected a game of another workspace to be hidden, got status %v", rec.Code)
+	}
+}
+
+func TestAPIMove(t *testing.T) {
+	handler, tokens, gm := setupAPI(t)
+	waiting := gm.Players[game.Black].Members()[0]
+	if gm.Turn() == game.Black {
+		waiting = gm.Players[game.White].Members()[0]
+	}
+	mover := gm.TurnPlayer().Members()[0]
+	// the user of the body is ignored, moves are made as the member the token was granted to
+	if rec := request(handler, http.MethodPost, "/api/games/1234/moves", tokenFor(t, tokens, waiting), `{"user":"`+mover+`","move":"e2e4"}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a move out of turn, got %v", rec.Code)
+	}
+	token := tokenFor(t, tokens, mover)
+	if rec := request(handler, http.MethodPost, "/api/games/1234/moves", token, `{"move":"e2e5"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for an illegal move, got %v", rec.Code)
+	}
+	if err := tokens.StoreToken(api.TokenOwner{WorkspaceID: "T1"}, "workspace-token"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := request(handler, http.MethodPost, "/api/games/1234/moves", "workspace-token", `{"move":"e2e4"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a token not granted to a member, got %v", rec.Code)
+	}
+	rec := request(handler, http.MethodPost, "/api/games/1234/moves", token, `{"move":"e2e4"}`)
+	var response struct {
+		Turn string `json:"turn"`
+		FEN  string `json:"fen"`
+	}
+	json.NewDecoder(rec.Body).Decode(&response)
+	if rec.Code != http.StatusOK || response.Turn != "Black" {
+		t.Errorf("expected the move to be played, got status %v and %v", rec.Code, response)
+	}
+}
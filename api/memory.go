@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"sync"
+)
+
+// MemoryStore implements the TokenStorage and BotStorage interfaces and holds all state in memory
+// Once the MemoryStore instance is released, all data in that storage is lost
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]TokenOwner
+	bots   map[string]BotAccount
+}
+
+// NewMemoryStore returns a MemoryStore pointer
+func NewMemoryStore() *MemoryStore {
+	store := MemoryStore{
+		tokens: make(map[string]TokenOwner, 10),
+		bots:   make(map[string]BotAccount, 10),
+	}
+	return &store
+}
+
+// StoreToken stores an API token granted to a workspace member
+func (m *MemoryStore) StoreToken(owner TokenOwner, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[hashToken(token)] = owner
+	return nil
+}
+
+// OwnerByToken retrieves the workspace member an API token was granted to
+func (m *MemoryStore) OwnerByToken(token string) (*TokenOwner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owner, ok := m.tokens[hashToken(token)]
+	if !ok {
+		return nil, errors.New("API token not found")
+	}
+	return &owner, nil
+}
+
+// StoreBot stores a bot account along with the token it authenticates with, replacing its previous token
+func (m *MemoryStore) StoreBot(bot BotAccount, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, existing := range m.bots {
+		if existing.WorkspaceID != bot.WorkspaceID || existing.ID != bot.ID {
+			continue
+		}
+		if existing.OwnerID != bot.OwnerID {
+			return ErrBotNameTaken
+		}
+		delete(m.bots, hash)
+	}
+	m.bots[hashToken(token)] = bot
+	return nil
+}
+
+// BotByToken retrieves the bot account a token was issued to
+func (m *MemoryStore) BotByToken(token string) (*BotAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bot, ok := m.bots[hashToken(token)]
+	if !ok {
+		return nil, errors.New("bot token not found")
+	}
+	return &bot, nil
+}
+
+// RetrieveBot retrieves a bot account of a workspace by its ID
+func (m *MemoryStore) RetrieveBot(workspaceID string, botID string) (*BotAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, bot := range m.bots {
+		if bot.WorkspaceID == workspaceID && bot.ID == botID {
+			return &bot, nil
+		}
+	}
+	return nil, errors.New("bot not found")
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"database/sql"
+
+	"github.com/cjsaylor/chessbot/migration"
+
+	// import postgres package for use with the sql interface
+	_ "github.com/lib/pq"
+)
+
+// PostgresMigrations creates and evolves the API token and bot account tables on Postgres
+var PostgresMigrations = migration.Set{
+	Component: "api",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create api tokens and bot accounts",
+			Up: `
+				CREATE TABLE api_tokens (
+					token_hash text PRIMARY KEY,
+					workspace_id text NOT NULL
+				);
+				CREATE TABLE bot_accounts (
+					token_hash text PRIMARY KEY,
+					bot_id text NOT NULL,
+					workspace_id text NOT NULL
+				);
+			`,
+		},
+		{
+			Version:     2,
+			Description: "tie api tokens to the member who requested them",
+			Up:          `ALTER TABLE api_tokens ADD COLUMN user_id text NOT NULL DEFAULT '';`,
+		},
+		{
+			Version:     3,
+			Description: "tie bot accounts to the member who registered them and make their names unique",
+			Up: `
+				ALTER TABLE bot_accounts ADD COLUMN owner_id text NOT NULL DEFAULT '';
+				DELETE FROM bot_accounts WHERE ctid NOT IN (
+					SELECT min(ctid) FROM bot_accounts GROUP BY workspace_id, bot_id
+				);
+				CREATE UNIQUE INDEX bot_accounts_workspace_bot ON bot_accounts (workspace_id, bot_id);
+			`,
+		},
+	},
+}
+
+// PostgresStore is an implementation of the TokenStorage and BotStorage interfaces that persists using Postgres
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore migrates the API token and bot account tables of the database to the latest version
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	if err := migration.Apply(db, migration.Postgres, PostgresMigrations); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// StoreToken stores an API token granted to a workspace member. Only a hash of the token is persisted.
+func (s *PostgresStore) StoreToken(owner TokenOwner, token string) error {
+	_, err := s.db.Exec("insert into api_tokens (token_hash, workspace_id, user_id) values ($1, $2, $3)", hashToken(token), owner.WorkspaceID, owner.UserID)
+	return err
+}
+
+// OwnerByToken retrieves the workspace member an API token was granted to
+func (s *PostgresStore) OwnerByToken(token string) (*TokenOwner, error) {
+	owner := TokenOwner{}
+	err := s.db.QueryRow("select workspace_id, user_id from api_tokens where token_hash = $1", hashToken(token)).Scan(&owner.WorkspaceID, &owner.UserID)
+	return &owner, err
+}
+
+// StoreBot stores a bot account along with the token it authenticates with, replacing its previous token.
+// Only a hash of the token is persisted.
+func (s *PostgresStore) StoreBot(bot BotAccount, token string) error {
+	result, err := s.db.Exec(`
+		insert into bot_accounts (token_hash, bot_id, workspace_id, owner_id) values ($1, $2, $3, $4)
+		on conflict (workspace_id, bot_id) do update set token_hash = excluded.token_hash
+		where bot_accounts.owner_id = excluded.owner_id
+	`, hashToken(token), bot.ID, bot.WorkspaceID, bot.OwnerID)
+	if err != nil {
+		return err
+	}
+	if stored, err := result.RowsAffected(); err != nil {
+		return err
+	} else if stored == 0 {
+		return ErrBotNameTaken
+	}
+	return nil
+}
+
+// BotByToken retrieves the bot account a token was issued to
+func (s *PostgresStore) BotByToken(token string) (*BotAccount, error) {
+	bot := BotAccount{}
+	err := s.db.QueryRow("select bot_id, workspace_id, owner_id from bot_accounts where token_hash = $1", hashToken(token)).Scan(&bot.ID, &bot.WorkspaceID, &bot.OwnerID)
+	return &bot, err
+}
+
+// RetrieveBot retrieves a bot account of a workspace by its ID
+func (s *PostgresStore) RetrieveBot(workspaceID string, botID string) (*BotAccount, error) {
+	bot := BotAccount{WorkspaceID: workspaceID, ID: botID}
+	err := s.db.QueryRow("select owner_id from bot_accounts where workspace_id = $1 and bot_id = $2", workspaceID, botID).Scan(&bot.OwnerID)
+	return &bot, err
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"database/sql"
+
+	"github.com/cjsaylor/chessbot/migration"
+
+	// import sqlite package for use with the sql interface
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SqliteMigrations creates and evolves the API token and bot account tables on sqlite.
+// The first migrations tolerate databases created before the schema was versioned.
+var SqliteMigrations = migration.Set{
+	Component: "api",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create api tokens",
+			Up: `
+				CREATE TABLE IF NOT EXISTS api_tokens (
+					token_hash text PRIMARY KEY,
+					workspace_id text NOT NULL
+				);
+			`,
+		},
+		{
+			Version:     2,
+			Description: "create bot accounts",
+			Up: `
+				CREATE TABLE IF NOT EXISTS bot_accounts (
+					token_hash text PRIMARY KEY,
+					bot_id text NOT NULL,
+					workspace_id text NOT NULL
+				);
+			`,
+		},
+		{
+			Version:     3,
+			Description: "tie api tokens to the member who requested them",
+			Run:         migration.AddSqliteColumn("api_tokens", "user_id", "text NOT NULL DEFAULT ''"),
+		},
+		{
+			Version:     4,
+			Description: "tie bot accounts to the member who registered them",
+			Run:         migration.AddSqliteColumn("bot_accounts", "owner_id", "text NOT NULL DEFAULT ''"),
+		},
+		{
+			Version:     5,
+			Description: "make bot names unique within a workspace",
+			Up: `
+				DELETE FROM bot_accounts WHERE rowid NOT IN (
+					SELECT min(rowid) FROM bot_accounts GROUP BY workspace_id, bot_id
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS bot_accounts_workspace_bot ON bot_accounts (workspace_id, bot_id);
+			`,
+		},
+	},
+}
+
+// SqliteStore is an implementation of the TokenStorage and BotStorage interfaces that persists using sqlite3
+type SqliteStore struct {
+	path string
+	db   *sql.DB
+}
+
+// NewSqliteStore creates (if not exists) the DB file at the path specified and migrates its structure
+// It implements the TokenStorage and BotStorage interfaces and is intended as a suitable
+// perminent storage of API tokens and bot accounts
+func NewSqliteStore(path string) (*SqliteStore, error) {
+	store := SqliteStore{
+		path: path,
+	}
+	db, err := sql.Open("sqlite3", store.path)
+	if err != nil {
+		return nil, err
+	}
+	if err = migration.Apply(db, migration.Sqlite, SqliteMigrations); err != nil {
+		return nil, err
+	}
+	store.db = db
+	return &store, nil
+}
+
+// StoreToken stores an API token granted to a workspace member. Only a hash of the token is persisted.
+func (s *SqliteStore) StoreToken(owner TokenOwner, token string) error {
+	return s.storeTokenHash(hashToken(token), owner)
+}
+
+func (s *SqliteStore) storeTokenHash(hash string, owner TokenOwner) error {
+	stmt, _ := s.db.Prepare("insert or replace into api_tokens (token_hash, workspace_id, user_id) values (?, ?, ?)")
+	defer stmt.Close()
+	_, err := stmt.Exec(hash, owner.WorkspaceID, owner.UserID)
+	return err
+}
+
+// OwnerByToken retrieves the workspace member an API token was granted to
+func (s *SqliteStore) OwnerByToken(token string) (*TokenOwner, error) {
+	stmt, _ := s.db.Prepare("select workspace_id, user_id from api_tokens where token_hash = ?")
+	defer stmt.Close()
+	owner := TokenOwner{}
+	row := stmt.QueryRow(hashToken(token))
+	err := row.Scan(&owner.WorkspaceID, &owner.UserID)
+	return &owner, err
+}
+
+// StoreBot stores a bot account along with the token it authenticates with, replacing its previous token.
+// Only a hash of the token is persisted.
+func (s *SqliteStore) StoreBot(bot BotAccount, token string) error {
+	return s.storeBotHash(hashToken(token), bot)
+}
+
+func (s *SqliteStore) storeBotHash(hash string, bot BotAccount) error {
+	result, err := s.db.Exec(`
+		insert into bot_accounts (token_hash, bot_id, workspace_id, owner_id) values (?, ?, ?, ?)
+		on conflict (workspace_id, bot_id) do update set token_hash = excluded.token_hash
+		where bot_accounts.owner_id = excluded.owner_id
+	`, hash, bot.ID, bot.WorkspaceID, bot.OwnerID)
+	if err != nil {
+		return err
+	}
+	if stored, err := result.RowsAffected(); err != nil {
+		return err
+	} else if stored == 0 {
+		return ErrBotNameTaken
+	}
+	return nil
+}
+
+// BotByToken retrieves the bot account a token was issued to
+func (s *SqliteStore) BotByToken(token string) (*BotAccount, error) {
+	stmt, _ := s.db.Prepare("select bot_id, workspace_id, owner_id from bot_accounts where token_hash = ?")
+	defer stmt.Close()
+	bot := BotAccount{}
+	row := stmt.QueryRow(hashToken(token))
+	err := row.Scan(&bot.ID, &bot.WorkspaceID, &bot.OwnerID)
+	return &bot, err
+}
+
+// RetrieveBot retrieves a bot account of a workspace by its ID
+func (s *SqliteStore) RetrieveBot(workspaceID string, botID string) (*BotAccount, error) {
+	bot := BotAccount{WorkspaceID: workspaceID, ID: botID}
+	err := s.db.QueryRow("select owner_id from bot_accounts where workspace_id = ? and bot_id = ?", workspaceID, botID).Scan(&bot.OwnerID)
+	return &bot, err
+}
+
+// dump returns every API token and bot account of the store by the hash of their token
+func (s *SqliteStore) dump() (storedTokens, error) {
+	stored := newStoredTokens()
+	rows, err := s.db.Query("select token_hash, workspace_id, user_id from api_tokens")
+	if err != nil {
+		return stored, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		owner := TokenOwner{}
+		if err := rows.Scan(&hash, &owner.WorkspaceID, &owner.UserID); err != nil {
+			return stored, err
+		}
+		stored.tokens[hash] = owner
+	}
+	if err := rows.Err(); err != nil {
+		return stored, err
+	}
+	bots, err := s.db.Query("select token_hash, bot_id, workspace_id, owner_id from bot_accounts")
+	if err != nil {
+		return stored, err
+	}
+	defer bots.Close()
+	for bots.Next() {
+		var hash string
+		bot := BotAccount{}
+		if err := bots.Scan(&hash, &bot.ID, &bot.WorkspaceID, &bot.OwnerID); err != nil {
+			return stored, err
+		}
+		stored.bots[hash] = bot
+	}
+	return stored, bots.Err()
+}
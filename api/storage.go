@@ -0,0 +1,36 @@
+package api
+
+import "errors"
+
+// TokenOwner is the member of a workspace an API token was granted to
+// Tokens granted before they were tied to a member have an empty UserID and cannot make moves.
+type TokenOwner struct {
+	WorkspaceID string
+	UserID      string
+}
+
+// TokenStorage interface guarentees implemented methods for API token storage
+// Tokens are scoped to a single workspace and act as the member who requested them.
+type TokenStorage interface {
+	StoreToken(owner TokenOwner, token string) error
+	OwnerByToken(token string) (*TokenOwner, error)
+}
+
+// BotAccount is an external program registered to play games as a team member of a workspace
+// Its ID is unique within the workspace and only the member who registered it (OwnerID) can rotate its token.
+type BotAccount struct {
+	ID          string
+	WorkspaceID string
+	OwnerID     string
+}
+
+// ErrBotNameTaken is returned when storing a bot whose ID was registered by another member of the workspace
+var ErrBotNameTaken = errors.New("bot name already taken")
+
+// BotStorage interface guarentees implemented methods for bot account storage
+// StoreBot replaces the token of a bot registered by the same owner and returns ErrBotNameTaken otherwise.
+type BotStorage interface {
+	StoreBot(bot BotAccount, token string) error
+	BotByToken(token string) (*BotAccount, error)
+	RetrieveBot(workspaceID string, botID string) (*BotAccount, error)
+}
@@ -0,0 +1,75 @@
+// Package backup takes consistent snapshots of the sqlite database and keeps them on a backup target
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup is a target keeping database snapshots by name
+type Backup interface {
+	// Store saves a snapshot under the name, replacing any snapshot of the same name
+	Store(name string, snapshot io.ReadSeeker) error
+	// Snapshots lists the names of the stored snapshots, oldest first
+	Snapshots() ([]string, error)
+	// Retrieve writes the snapshot of the name to w
+	Retrieve(name string, w io.Writer) error
+	// Remove deletes the snapshot of the name
+	Remove(name string) error
+}
+
+const (
+	snapshotPrefix = "chessbot-"
+	snapshotSuffix = ".db"
+	// snapshotTime has a fixed width so snapshot names sort by the time they were taken
+	snapshotTime = "20060102-150405.000000000"
+)
+
+// SnapshotName is the name of a snapshot taken at the given time
+func SnapshotName(at time.Time) string {
+	return snapshotPrefix + at.UTC().Format(snapshotTime) + snapshotSuffix
+}
+
+// isSnapshot tells snapshot names apart from other files kept next to them
+func isSnapshot(name string) bool {
+	return strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix)
+}
+
+func sortedSnapshots(names []string) []string {
+	snapshots := []string{}
+	for _, name := range names {
+		if isSnapshot(name) {
+			snapshots = append(snapshots, name)
+		}
+	}
+	sort.Strings(snapshots)
+	return snapshots
+}
+
+// Restore replaces the database at path with the most recent snapshot of the target.
+// It returns the name of the restored snapshot, or an empty name when the target has none.
+func Restore(target Backup, path string) (string, error) {
+	snapshots, err := target.Snapshots()
+	if err != nil || len(snapshots) == 0 {
+		return "", err
+	}
+	latest := snapshots[len(snapshots)-1]
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".restore")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	err = target.Retrieve(latest, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return latest, os.Rename(file.Name(), path)
+}
@@ -0,0 +1,256 @@
+package backup_test
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cjsaylor/chessbot/backup"
+	"github.com/cjsaylor/chessbot/game"
+)
+
+// fakeS3 is a stand-in for an S3 compatible server (e.g. MinIO) supporting the requests of the S3 target
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	IsTruncated bool
+	Contents    []struct {
+		Key  string
+		Size int
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		result := listBucketResult{}
+		keys := []string{}
+		for key := range f.objects {
+			if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result.Contents = append(result.Contents, struct {
+				Key  string
+				Size int
+			}{Key: key, Size: len(f.objects[key])})
+		}
+		xml.NewEncoder(w).Encode(result)
+		return
+	}
+	key := parts[1]
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		f.objects[key] = body
+	case http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type targetTest struct {
+	name   string
+	target backup.Backup
+}
+
+func targetTestTable(t *testing.T) []targetTest {
+	dir, err := ioutil.TempDir("", "chessbot-backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory, err := backup.NewDirectoryBackup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []targetTest{
+		{name: "directory", target: directory},
+		{name: "s3", target: backup.NewS3Backup(s3.New(sess), "backups", "chessbot")},
+	}
+}
+
+// newDatabase returns the path of a sqlite database holding a game and its store
+func newDatabase(t *testing.T) (string, *game.SqliteStore) {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "chessbot.db")
+	store, err := game.NewSqliteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm := game.NewGame("1234", game.Player{ID: "1"}, game.Player{ID: "2"})
+	if err := store.StoreGame("1234", gm); err != nil {
+		t.Fatal(err)
+	}
+	return path, store
+}
+
+func TestSnapshotCopiesDatabaseInUse(t *testing.T) {
+	path, _ := newDatabase(t)
+	dest := filepath.Join(filepath.Dir(path), "snapshot.db")
+	if err := backup.Snapshot(path, dest); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := game.NewSqliteStore(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshot.RetrieveGame("1234"); err != nil {
+		t.Errorf("expected the game in the snapshot: %v", err)
+	}
+}
+
+func TestSchedulerCoalescesStoredGamesAndRetainsRecentSnapshots(t *testing.T) {
+	for _, tt := range targetTestTable(t) {
+		t.Run(tt.name, func(t *testing.T) {
+			path, store := newDatabase(t)
+			scheduler := backup.NewScheduler(path, tt.target, time.Hour, 2)
+			gm, _ := store.RetrieveGame("1234")
+			for i := 0; i < 3; i++ {
+				scheduler.GameStored(gm)
+			}
+			if err := scheduler.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if snapshots, _ := tt.target.Snapshots(); len(snapshots) != 1 {
+				t.Fatalf("expected the stored games to share a snapshot, got %v", snapshots)
+			}
+			if err := scheduler.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := scheduler.Run(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if snapshots, _ := tt.target.Snapshots(); len(snapshots) != 2 {
+				t.Errorf("expected 2 retained snapshots, got %v", snapshots)
+			}
+		})
+	}
+}
+
+// slowBackup holds uploads until released
+type slowBackup struct {
+	backup.Backup
+	storing chan bool
+	release chan bool
+}
+
+func (b slowBackup) Store(name string, snapshot io.ReadSeeker) error {
+	b.storing <- true
+	<-b.release
+	return b.Backup.Store(name, snapshot)
+}
+
+func TestSchedulerFlushWaitsForRunningSnapshot(t *testing.T) {
+	path, store := newDatabase(t)
+	directory, err := backup.NewDirectoryBackup(filepath.Join(filepath.Dir(path), "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := slowBackup{Backup: directory, storing: make(chan bool), release: make(chan bool)}
+	scheduler := backup.NewScheduler(path, target, time.Millisecond, 2)
+	gm, _ := store.RetrieveGame("1234")
+	scheduler.GameStored(gm)
+	<-target.storing
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- scheduler.Flush()
+	}()
+	select {
+	case <-flushed:
+		t.Fatal("expected the flush to wait for the snapshot being uploaded")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(target.release)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, _ := directory.Snapshots(); len(snapshots) != 1 {
+		t.Errorf("expected the snapshot to be stored by the time the flush returned, got %v", snapshots)
+	}
+}
+
+func TestRestoreReplacesDatabaseWithLatestSnapshot(t *testing.T) {
+	for _, tt := range targetTestTable(t) {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := ioutil.TempDir("", "chessbot")
+			restorePath := filepath.Join(dir, "chessbot.db")
+			if restored, err := backup.Restore(tt.target, restorePath); err != nil || restored != "" {
+				t.Fatalf("expected nothing to restore from an empty target, got %v (%v)", restored, err)
+			}
+
+			path, store := newDatabase(t)
+			scheduler := backup.NewScheduler(path, tt.target, time.Hour, 0)
+			if err := scheduler.Run(); err != nil {
+				t.Fatal(err)
+			}
+			gm, _ := store.RetrieveGame("1234")
+			gm.Move("e2e4")
+			if err := store.StoreGame("1234", gm); err != nil {
+				t.Fatal(err)
+			}
+			if err := scheduler.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			restored, err := backup.Restore(tt.target, restorePath)
+			if err != nil || restored == "" {
+				t.Fatalf("expected a snapshot to be restored, got %v (%v)", restored, err)
+			}
+			restoredStore, err := game.NewSqliteStore(restorePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restoredGame, err := restoredStore.RetrieveGame("1234")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(restoredGame.Moves()) != 1 {
+				t.Errorf("expected the latest snapshot to be restored, got %v", restoredGame.PGN())
+			}
+		})
+	}
+}
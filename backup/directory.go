@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DirectoryBackup keeps snapshots as files of a local directory, e.g. a mounted volume
+type DirectoryBackup struct {
+	dir string
+}
+
+// NewDirectoryBackup creates (if not exists) the directory and returns a target keeping snapshots in it
+func NewDirectoryBackup(dir string) (*DirectoryBackup, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirectoryBackup{dir: dir}, nil
+}
+
+// Store writes the snapshot next to its final name first, so a partial snapshot is never listed
+func (d *DirectoryBackup) Store(name string, snapshot io.ReadSeeker) error {
+	file, err := ioutil.TempFile(d.dir, name+".partial")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, snapshot)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(d.dir, name))
+}
+
+// Snapshots lists the snapshots of the directory, oldest first
+func (d *DirectoryBackup) Snapshots() ([]string, error) {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, file := range files {
+		if !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	return sortedSnapshots(names), nil
+}
+
+// Retrieve copies a snapshot of the directory to w
+func (d *DirectoryBackup) Retrieve(name string, w io.Writer) error {
+	file, err := os.Open(filepath.Join(d.dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Remove deletes a snapshot of the directory
+func (d *DirectoryBackup) Remove(name string) error {
+	return os.Remove(filepath.Join(d.dir, name))
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// S3Backup keeps snapshots as objects of an S3 compatible bucket, under a key prefix
+type S3Backup struct {
+	client s3iface.S3API
+	bucket string
+	prefix string
+}
+
+// NewS3Backup returns a target keeping snapshots in the bucket, with keys starting with the prefix
+func NewS3Backup(client s3iface.S3API, bucket string, prefix string) *S3Backup {
+	return &S3Backup{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+// NewS3Client returns a client of the S3 region, credentials are taken from the AWS_* environment variables.
+// A custom endpoint (e.g. a MinIO server) is addressed with path style requests.
+func NewS3Client(region string, endpoint string) (*s3.S3, error) {
+	awsConfig := &aws.Config{
+		Region: aws.String(region),
+	}
+	if endpoint != "" {
+		awsConfig.Endpoint = aws.String(endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(sess), nil
+}
+
+func (s *S3Backup) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+// Store uploads a snapshot in a single request
+func (s *S3Backup) Store(name string, snapshot io.ReadSeeker) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+		Body:   snapshot,
+	})
+	return err
+}
+
+// Snapshots lists the snapshots under the prefix, oldest first
+func (s *S3Backup) Snapshots() ([]string, error) {
+	prefix := ""
+	if s.prefix != "" {
+		prefix = s.prefix + "/"
+	}
+	names := []string{}
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.StringValue(object.Key), prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortedSnapshots(names), nil
+}
+
+// Retrieve downloads a snapshot to w
+func (s *S3Backup) Retrieve(name string, w io.Writer) error {
+	object, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+	_, err = io.Copy(w, object.Body)
+	return err
+}
+
+// Remove deletes a snapshot from the bucket
+func (s *S3Backup) Remove(name string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+)
+
+// Scheduler snapshots the database to a target a while after a game is stored, so the games stored in between
+// are covered by a single snapshot. Only the most recent snapshots are retained.
+// It must be registered as an observer of the game storage.
+type Scheduler struct {
+	// Delay is how long after a stored game the snapshot is taken
+	Delay time.Duration
+	// Retain is the number of snapshots kept on the target, older ones are removed
+	Retain int
+	path   string
+	target Backup
+	mu     sync.Mutex
+	timer  *time.Timer
+	// done is closed once the snapshot of the latest timer was taken
+	done chan struct{}
+	// running serializes snapshots
+	running sync.Mutex
+}
+
+// NewScheduler returns a scheduler backing up the sqlite database at path to the target
+func NewScheduler(path string, target Backup, delay time.Duration, retain int) *Scheduler {
+	return &Scheduler{
+		Delay:  delay,
+		Retain: retain,
+		path:   path,
+		target: target,
+	}
+}
+
+// GameStored schedules a snapshot unless one is already pending
+func (s *Scheduler) GameStored(gm *game.Game) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		return
+	}
+	done := make(chan struct{})
+	s.done = done
+	s.timer = time.AfterFunc(s.Delay, func() {
+		defer close(done)
+		s.mu.Lock()
+		s.timer = nil
+		s.mu.Unlock()
+		if err := s.Run(); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+// Flush takes the pending snapshot right away, or waits for the one being taken, e.g. before shutting down
+func (s *Scheduler) Flush() error {
+	s.mu.Lock()
+	pending := s.timer != nil && s.timer.Stop()
+	done := s.done
+	if pending {
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	if !pending {
+		// the timer already fired, its snapshot may still be taken or uploaded
+		if done != nil {
+			<-done
+		}
+		return nil
+	}
+	defer close(done)
+	return s.Run()
+}
+
+// Run takes a snapshot, stores it on the target and removes the snapshots beyond the retention
+func (s *Scheduler) Run() error {
+	s.running.Lock()
+	defer s.running.Unlock()
+	file, err := ioutil.TempFile("", "chessbot-snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if err := Snapshot(s.path, file.Name()); err != nil {
+		return err
+	}
+	name := SnapshotName(time.Now())
+	if err := s.target.Store(name, file); err != nil {
+		return err
+	}
+	log.Printf("Backed up %v as %v\n", s.path, name)
+	return s.prune()
+}
+
+func (s *Scheduler) prune() error {
+	if s.Retain <= 0 {
+		return nil
+	}
+	snapshots, err := s.target.Snapshots()
+	if err != nil {
+		return err
+	}
+	for len(snapshots) > s.Retain {
+		if err := s.target.Remove(snapshots[0]); err != nil {
+			return err
+		}
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
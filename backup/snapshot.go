@@ -0,0 +1,50 @@
+//go:build cgo
+// +build cgo
+
+package backup
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// lockedRetries is how many times a snapshot is retried while the database is locked by a writer
+const lockedRetries = 50
+
+// ErrDatabaseLocked is returned when a snapshot could not be taken because the database stayed locked
+var ErrDatabaseLocked = errors.New("database stayed locked during the snapshot")
+
+// Snapshot copies the sqlite database at path into the file at dest with the online backup API,
+// so the copy is consistent even while the database is written to
+func Snapshot(path string, dest string) error {
+	driver := &sqlite3.SQLiteDriver{}
+	srcConn, err := driver.Open(path)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	destConn, err := driver.Open(dest)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	backup, err := destConn.(*sqlite3.SQLiteConn).Backup("main", srcConn.(*sqlite3.SQLiteConn), "main")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < lockedRetries; i++ {
+		done, err := backup.Step(-1)
+		if err != nil {
+			backup.Close()
+			return err
+		}
+		if done {
+			return backup.Finish()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	backup.Close()
+	return ErrDatabaseLocked
+}
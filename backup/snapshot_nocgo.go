@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package backup
+
+import "errors"
+
+// Snapshot is not supported by binaries built without cgo, as the sqlite driver needs it
+func Snapshot(path string, dest string) error {
+	return errors.New("sqlite snapshots require a binary built with cgo")
+}
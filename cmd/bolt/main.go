@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/cjsaylor/chessbot/api"
+	"github.com/cjsaylor/chessbot/config"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/webhook"
+	bolt "go.etcd.io/bbolt"
+)
+
+func main() {
+	importPath := flag.String("import", "", "sqlite database to copy into the bolt database")
+	exportPath := flag.String("export", "", "sqlite database to copy the bolt database into")
+	flag.Parse()
+	config, err := config.ParseConfiguration()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.BoltPath == "" {
+		log.Fatal("BOLTPATH must be set")
+	}
+	sqlitePath := *importPath
+	if sqlitePath == "" {
+		sqlitePath = *exportPath
+	}
+	if sqlitePath == "" || (*importPath != "" && *exportPath != "") {
+		log.Fatal("either -import or -export must be given a sqlite database")
+	}
+
+	db, err := bolt.Open(config.BoltPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	gameBoltStore, err := game.NewBoltStore(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authBoltStore, err := integration.NewBoltStore(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokenBoltStore, err := api.NewBoltStore(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookBoltStore, err := webhook.NewBoltStore(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gameSQLStore, err := game.NewSqliteStore(sqlitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authSQLStore, err := integration.NewSqliteStore(sqlitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokenSQLStore, err := api.NewSqliteStore(sqlitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookSQLStore, err := webhook.NewSqliteStore(sqlitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *importPath != "" {
+		if err := gameBoltStore.ImportSqlite(gameSQLStore); err != nil {
+			log.Fatal(err)
+		}
+		if err := authBoltStore.ImportSqlite(authSQLStore); err != nil {
+			log.Fatal(err)
+		}
+		if err := tokenBoltStore.ImportSqlite(tokenSQLStore); err != nil {
+			log.Fatal(err)
+		}
+		if err := webhookBoltStore.ImportSqlite(webhookSQLStore); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Imported %v into %v\n", sqlitePath, config.BoltPath)
+		return
+	}
+	if err := gameBoltStore.ExportSqlite(gameSQLStore); err != nil {
+		log.Fatal(err)
+	}
+	if err := authBoltStore.ExportSqlite(authSQLStore); err != nil {
+		log.Fatal(err)
+	}
+	if err := tokenBoltStore.ExportSqlite(tokenSQLStore); err != nil {
+		log.Fatal(err)
+	}
+	if err := webhookBoltStore.ExportSqlite(webhookSQLStore); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Exported %v into %v\n", config.BoltPath, sqlitePath)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/cjsaylor/chessbot/config"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+func main() {
+	config, err := config.ParseConfiguration()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.EmailAddress == "" || config.SMTPAddr == "" || config.IMAPAddr == "" || config.EmailAuthServID == "" {
+		log.Fatal("EMAILADDRESS, EMAILAUTHSERVID, SMTPADDR and IMAPADDR must be set")
+	}
+	var gameStorage game.GameStorage
+	if config.SqlitePath != "" {
+		gameSQLStore, err := game.NewSqliteStore(config.SqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameStorage = gameSQLStore
+	} else {
+		gameStorage = game.NewMemoryStore()
+	}
+	var auth smtp.Auth
+	if config.SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(config.SMTPAddr)
+		auth = smtp.PlainAuth("", config.SMTPUser, config.SMTPPassword, host)
+	}
+	gateway := integration.EmailGateway{
+		Address:      config.EmailAddress,
+		AuthServID:   config.EmailAuthServID,
+		SMTPAddr:     config.SMTPAddr,
+		SMTPAuth:     auth,
+		IMAPAddr:     config.IMAPAddr,
+		IMAPTLS:      config.IMAPTLS,
+		IMAPUser:     config.IMAPUser,
+		IMAPPassword: config.IMAPPassword,
+		GameStorage:  gameStorage,
+		RenderBoard:  rendering.RenderGame,
+	}
+	log.Printf("Polling %v every %v\n", config.IMAPAddr, config.EmailPollInterval)
+	for {
+		if err := gateway.Poll(); err != nil {
+			log.Println(err)
+		}
+		time.Sleep(config.EmailPollInterval)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/cjsaylor/chessbot/config"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+func main() {
+	config, err := config.ParseConfiguration()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.IRCServer == "" {
+		log.Fatal("IRCSERVER must be set")
+	}
+	var gameStorage game.GameStorage
+	if config.SqlitePath != "" {
+		gameSQLStore, err := game.NewSqliteStore(config.SqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameStorage = gameSQLStore
+	} else {
+		gameStorage = game.NewMemoryStore()
+	}
+	var linkRenderer *rendering.RenderLink
+	if config.SigningKey != "" {
+		renderLink := rendering.NewRenderLink(config.Hostname, config.SigningKey)
+		linkRenderer = &renderLink
+	}
+
+	var conn net.Conn
+	if config.IRCTLS {
+		conn, err = tls.Dial("tcp", config.IRCServer, &tls.Config{})
+	} else {
+		conn, err = net.Dial("tcp", config.IRCServer)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	log.Printf("Connected to %v as %v\n", config.IRCServer, config.IRCNick)
+	bot := integration.NewIRCBot(config.IRCNick, config.IRCChannels, gameStorage, linkRenderer)
+	if err := bot.Run(conn); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/cjsaylor/chessbot/api"
+	"github.com/cjsaylor/chessbot/config"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/migration"
+	"github.com/cjsaylor/chessbot/webhook"
+)
+
+func main() {
+	status := flag.Bool("status", false, "only print the schema version of every component")
+	flag.Parse()
+	config, err := config.ParseConfiguration()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var db *sql.DB
+	var dialect migration.Dialect
+	var sets []migration.Set
+	switch {
+	case config.PostgresURL != "":
+		db, err = sql.Open("postgres", config.PostgresURL)
+		dialect = migration.Postgres
+		sets = []migration.Set{
+			game.PostgresMigrations,
+			integration.PostgresMigrations,
+			api.PostgresMigrations,
+			webhook.PostgresMigrations,
+		}
+	case config.SqlitePath != "":
+		db, err = sql.Open("sqlite3", config.SqlitePath)
+		dialect = migration.Sqlite
+		sets = []migration.Set{
+			game.SqliteMigrations,
+			integration.SqliteMigrations,
+			api.SqliteMigrations,
+			webhook.SqliteMigrations,
+		}
+	default:
+		log.Fatal("SQLITEPATH or POSTGRESURL must be set")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	for _, set := range sets {
+		if !*status {
+			if err := migration.Apply(db, dialect, set); err != nil {
+				log.Fatal(err)
+			}
+		}
+		version, err := migration.Version(db, dialect, set.Component)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%-12v %v/%v\n", set.Component, version, set.Latest())
+	}
+}
@@ -1,102 +1,253 @@
 package main
 
 import (
+	"database/sql"
+	"expvar"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/cjsaylor/chessbot/analysis"
+	"github.com/cjsaylor/chessbot/api"
+	"github.com/cjsaylor/chessbot/backup"
 	"github.com/cjsaylor/chessbot/config"
 	"github.com/cjsaylor/chessbot/game"
 	"github.com/cjsaylor/chessbot/integration"
 	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/cjsaylor/chessbot/spectator"
+	"github.com/cjsaylor/chessbot/webhook"
+	bolt "go.etcd.io/bbolt"
 )
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-func main() {
-	dir, dderr := os.Getwd()
-	if dderr != nil {
-		log.Fatal(dderr)
+// newBackupTarget returns the target configured for sqlite snapshots, or nil if backups are disabled
+func newBackupTarget(config config.Configuration) (backup.Backup, error) {
+	if config.BackupS3Bucket != "" {
+		client, err := backup.NewS3Client(config.BackupS3Region, config.BackupS3Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return backup.NewS3Backup(client, config.BackupS3Bucket, config.BackupS3Prefix), nil
 	}
-	fmt.Println("Current dir: " + dir)
-
-	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY set as env
-	var cloudcube_cube = os.Getenv("CLOUDCUBE_CUBENAME")
-	var cloudcube_bucket = "cloud-cube-eu"
-
-	// Session object should be reused
-	sess, serr := session.NewSession(&aws.Config{
-		Region: aws.String("eu-west-1")},
-	)
-	if serr != nil {
-		fmt.Println(serr)
-		os.Exit(1)
+	if config.BackupDir != "" {
+		return backup.NewDirectoryBackup(config.BackupDir)
 	}
+	return nil, nil
+}
 
-	// Create S3 service client and uploader
-	svc := s3.New(sess)
-	keyName := cloudcube_cube + "/chessbot.db"
-	uploader := s3manager.NewUploaderWithClient(svc)
-
-	// Download the current db file
-	downloader := s3manager.NewDownloaderWithClient(svc)
-	f, ferr := os.Create("./chessbot.db")
-	if ferr != nil {
-		fmt.Println(ferr)
+// flushOnShutdown takes the pending snapshot before the process stops, so the latest games are not lost
+func flushOnShutdown(scheduler *backup.Scheduler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	if err := scheduler.Flush(); err != nil {
+		log.Println(err)
 	}
+	os.Exit(0)
+}
 
-	dbFileSize, derr := downloader.Download(f, &s3.GetObjectInput{
-		Bucket: aws.String(cloudcube_bucket),
-		Key:    aws.String(keyName),
-	})
-	if derr != nil {
-		fmt.Printf("error downloading the db file!")
-		fmt.Println(derr)
+func main() {
+	dir, dderr := os.Getwd()
+	if dderr != nil {
+		log.Fatal(dderr)
 	}
+	fmt.Println("Current dir: " + dir)
 
 	config, err := config.ParseConfiguration()
 	if err != nil {
 		log.Fatal(err)
 	}
 	var gameStorage game.GameStorage
-	var authStorage integration.AuthStorage
-	if config.SqlitePath != "" {
-		gameSQLStore, err := game.NewSqliteStore(config.SqlitePath, uploader, cloudcube_bucket, keyName)
+	var authStorage interface {
+		integration.AuthStorage
+		integration.PreferenceStorage
+	}
+	var tokenStorage interface {
+		api.TokenStorage
+		api.BotStorage
+	}
+	var webhookStorage webhook.WebhookStorage
+	var backupScheduler *backup.Scheduler
+	var dbFileSize int64
+	if config.PostgresURL != "" {
+		db, err := sql.Open("postgres", config.PostgresURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.SetMaxOpenConns(config.PostgresMaxOpen)
+		db.SetMaxIdleConns(config.PostgresMaxIdle)
+		db.SetConnMaxLifetime(config.PostgresMaxLife)
+		gamePostgresStore, err := game.NewPostgresStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authPostgresStore, err := integration.NewPostgresStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tokenPostgresStore, err := api.NewPostgresStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		webhookPostgresStore, err := webhook.NewPostgresStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameStorage = gamePostgresStore
+		authStorage = authPostgresStore
+		tokenStorage = tokenPostgresStore
+		webhookStorage = webhookPostgresStore
+	} else if config.BoltPath != "" {
+		db, err := bolt.Open(config.BoltPath, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameBoltStore, err := game.NewBoltStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authBoltStore, err := integration.NewBoltStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tokenBoltStore, err := api.NewBoltStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		webhookBoltStore, err := webhook.NewBoltStore(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameStorage = gameBoltStore
+		authStorage = authBoltStore
+		tokenStorage = tokenBoltStore
+		webhookStorage = webhookBoltStore
+	} else if config.SqlitePath != "" {
+		backupTarget, err := newBackupTarget(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if backupTarget != nil && config.BackupRestore {
+			// only restore on a new host, restarts keep the games stored since the latest snapshot
+			if _, err := os.Stat(config.SqlitePath); os.IsNotExist(err) {
+				restored, err := backup.Restore(backupTarget, config.SqlitePath)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if restored != "" {
+					log.Printf("Restored %v from %v\n", config.SqlitePath, restored)
+				}
+			} else {
+				log.Printf("Not restoring %v as it already exists\n", config.SqlitePath)
+			}
+		}
+		gameSQLStore, err := game.NewSqliteStore(config.SqlitePath)
 		if err != nil {
 			log.Fatal(err)
 		}
 		authSQLStore, err := integration.NewSqliteStore(config.SqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tokenSQLStore, err := api.NewSqliteStore(config.SqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		webhookSQLStore, err := webhook.NewSqliteStore(config.SqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
 		gameStorage = gameSQLStore
 		authStorage = authSQLStore
+		tokenStorage = tokenSQLStore
+		webhookStorage = webhookSQLStore
+		if backupTarget != nil {
+			backupScheduler = backup.NewScheduler(config.SqlitePath, backupTarget, config.BackupDelay, config.BackupRetain)
+		}
+		if info, err := os.Stat(config.SqlitePath); err == nil {
+			dbFileSize = info.Size()
+		}
 	} else {
 		memoryStore := game.NewMemoryStore()
 		gameStorage = memoryStore
 		authStorage = integration.NewMemoryStore()
+		tokenStorage = api.NewMemoryStore()
+		webhookStorage = webhook.NewMemoryStore()
 	}
-	renderLink := rendering.NewRenderLink(config.Hostname, config.SigningKey)
+	observedStorage := game.NewObservedStore(gameStorage, webhook.NewDispatcher(webhookStorage))
+	gameStorage = observedStorage
+	if backupScheduler != nil {
+		observedStorage.Observe(backupScheduler)
+		go flushOnShutdown(backupScheduler)
+	}
+	renderLink := rendering.NewRenderLink(config.Hostname, config.SigningKey).WithExpiry(config.BoardLinkExpiry)
+	renderCache := rendering.NewRenderCache(config.RenderCacheSize, config.RenderCacheDir)
+	observedStorage.Observe(renderCache)
+	expvar.Publish("render_cache", expvar.Func(func() interface{} {
+		return renderCache.Stats()
+	}))
 	http.Handle("/board", rendering.BoardRenderHandler{
 		LinkRenderer: renderLink,
+		GameStorage:  gameStorage,
+		Cache:        renderCache,
+	})
+	var evaluator analysis.GameEvaluator = analysis.MaterialEvaluator{}
+	if config.EnginePath != "" {
+		engine := analysis.NewUCIEngine(config.EnginePath, config.EngineDepth, config.EngineProcesses)
+		engine.Timeout = config.EngineTimeout
+		evaluator = engine
+	}
+	evaluationHandler := rendering.EvaluationRenderHandler{
+		LinkRenderer: renderLink,
+		GameStorage:  gameStorage,
+		Evaluator:    evaluator,
+		Cache:        renderCache,
+	}
+	observedStorage.Observe(evaluationHandler)
+	http.Handle("/evaluation", evaluationHandler)
+	http.Handle("/replay", rendering.ReplayRenderHandler{
+		LinkRenderer: renderLink,
+		GameStorage:  gameStorage,
+		FrameDelay:   config.ReplayFrameDelay,
+		Cache:        renderCache,
 	})
+	watchLink := spectator.NewWatchLink(config.Hostname, config.SigningKey)
+	spectatorHandler := spectator.NewHTTPHandler(gameStorage, watchLink, renderLink)
+	observedStorage.Observe(spectatorHandler)
+	http.Handle("/watch/", spectatorHandler)
 	http.Handle("/analyze", analysis.NewHTTPHandler(gameStorage, analysis.NewChesscomAnalyzer(config.ChessAffiliateCode)))
 	// http.Handle("/analyze", analysis.NewHTTPHandler(gameStorage, analysis.LichessAnalyzer{}))
-	http.Handle("/slack", integration.SlackHandler{
+	apiHandler := api.NewHTTPHandler(gameStorage, tokenStorage)
+	http.Handle("/api/games", apiHandler)
+	http.Handle("/api/games/", apiHandler)
+	botHandler := api.NewBotHandler(gameStorage, tokenStorage)
+	observedStorage.Observe(botHandler)
+	http.Handle("/api/account", botHandler)
+	http.Handle("/api/stream/event", botHandler)
+	http.Handle("/api/bot/", botHandler)
+	slackHandler := integration.SlackHandler{
 		SigningKey:        config.SlackSigningKey,
 		Hostname:          config.Hostname,
 		AuthStorage:       authStorage,
 		GameStorage:       gameStorage,
 		LinkRenderer:      renderLink,
+		WatchLink:         watchLink,
+		APITokenStorage:   tokenStorage,
+		BotStorage:        tokenStorage,
+		WebhookStorage:    webhookStorage,
+		PreferenceStorage: authStorage,
 		DbFileSizeInBytes: dbFileSize,
-	})
+	}
+	observedStorage.Observe(integration.NewSlackBotMoves(slackHandler))
+	http.Handle("/slack", slackHandler)
 	http.Handle("/slack/action", integration.SlackActionHandler{
 		SigningKey:   config.SlackSigningKey,
 		Hostname:     config.Hostname,
@@ -110,6 +261,33 @@ func main() {
 		SlackAppID:        config.SlackAppID,
 		AuthStore:         authStorage,
 	})
+	if config.TelegramToken != "" {
+		if config.TelegramSecret == "" {
+			log.Fatal("TELEGRAMSECRET is required to verify the updates of the Telegram webhook")
+		}
+		http.Handle("/telegram", integration.TelegramHandler{
+			Token:       config.TelegramToken,
+			APIURL:      config.TelegramAPIURL,
+			SecretToken: config.TelegramSecret,
+			GameStorage: gameStorage,
+			RenderBoard: rendering.RenderGame,
+			Users:       integration.NewTelegramDirectory(),
+		})
+	}
+	if config.MatrixHomeserver != "" {
+		if config.MatrixHSToken == "" {
+			log.Fatal("MATRIXHSTOKEN is required to verify the transactions of the homeserver")
+		}
+		matrixHandler := integration.NewMatrixHandler(
+			config.MatrixHomeserver,
+			config.MatrixASToken,
+			config.MatrixHSToken,
+			config.MatrixUserID,
+			gameStorage,
+			rendering.RenderGame)
+		http.Handle("/_matrix/app/v1/transactions/", matrixHandler)
+		http.Handle("/transactions/", matrixHandler)
+	}
 	log.Printf("Listening on port %v\n", config.Port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", config.Port), nil))
 }
@@ -2,20 +2,64 @@
 package config
 
 import (
+	"time"
+
 	"github.com/caarlos0/env"
 )
 
 // Configuration holds all application configuration
 type Configuration struct {
-	Port               int    `env:"PORT" envDefault:"8080"`
-	Hostname           string `env:"HOSTNAME" envDefault:"localhost:8080"`
-	SigningKey         string `env:"SIGNINGKEY"`
-	SqlitePath         string `env:"SQLITEPATH"`
-	SlackAppID         string `env:"SLACKAPPID"`
-	SlackClientID      string `env:"SLACKCLIENTID"`
-	SlackClientSecret  string `env:"SLACKCLIENTSECRET"`
-	SlackSigningKey    string `env:"SLACKSIGNINGKEY"`
-	ChessAffiliateCode string `env:"CHESSAFFILIATECODE" envDefault:"75071678"`
+	Port               int           `env:"PORT" envDefault:"8080"`
+	Hostname           string        `env:"HOSTNAME" envDefault:"localhost:8080"`
+	SigningKey         string        `env:"SIGNINGKEY"`
+	SqlitePath         string        `env:"SQLITEPATH"`
+	BoltPath           string        `env:"BOLTPATH"`
+	BackupDir          string        `env:"BACKUPDIR"`
+	BackupS3Bucket     string        `env:"BACKUPS3BUCKET"`
+	BackupS3Prefix     string        `env:"BACKUPS3PREFIX"`
+	BackupS3Region     string        `env:"BACKUPS3REGION" envDefault:"eu-west-1"`
+	BackupS3Endpoint   string        `env:"BACKUPS3ENDPOINT"`
+	BackupDelay        time.Duration `env:"BACKUPDELAY" envDefault:"1m"`
+	BackupRetain       int           `env:"BACKUPRETAIN" envDefault:"24"`
+	BackupRestore      bool          `env:"BACKUPRESTORE"`
+	PostgresURL        string        `env:"POSTGRESURL"`
+	PostgresMaxOpen    int           `env:"POSTGRESMAXOPEN" envDefault:"10"`
+	PostgresMaxIdle    int           `env:"POSTGRESMAXIDLE" envDefault:"2"`
+	PostgresMaxLife    time.Duration `env:"POSTGRESMAXLIFE" envDefault:"30m"`
+	SlackAppID         string        `env:"SLACKAPPID"`
+	SlackClientID      string        `env:"SLACKCLIENTID"`
+	SlackClientSecret  string        `env:"SLACKCLIENTSECRET"`
+	SlackSigningKey    string        `env:"SLACKSIGNINGKEY"`
+	ChessAffiliateCode string        `env:"CHESSAFFILIATECODE" envDefault:"75071678"`
+	ReplayFrameDelay   time.Duration `env:"REPLAYFRAMEDELAY" envDefault:"1s"`
+	BoardLinkExpiry    time.Duration `env:"BOARDLINKEXPIRY"`
+	RenderCacheSize    int           `env:"RENDERCACHESIZE" envDefault:"256"`
+	RenderCacheDir     string        `env:"RENDERCACHEDIR"`
+	EnginePath         string        `env:"ENGINEPATH"`
+	EngineDepth        int           `env:"ENGINEDEPTH" envDefault:"12"`
+	EngineProcesses    int           `env:"ENGINEPROCESSES" envDefault:"2"`
+	EngineTimeout      time.Duration `env:"ENGINETIMEOUT" envDefault:"1m"`
+	TelegramToken      string        `env:"TELEGRAMTOKEN"`
+	TelegramSecret     string        `env:"TELEGRAMSECRET"`
+	TelegramAPIURL     string        `env:"TELEGRAMAPIURL" envDefault:"https://api.telegram.org"`
+	MatrixHomeserver   string        `env:"MATRIXHOMESERVER"`
+	MatrixUserID       string        `env:"MATRIXUSERID"`
+	MatrixASToken      string        `env:"MATRIXASTOKEN"`
+	MatrixHSToken      string        `env:"MATRIXHSTOKEN"`
+	IRCServer          string        `env:"IRCSERVER"`
+	IRCTLS             bool          `env:"IRCTLS"`
+	IRCNick            string        `env:"IRCNICK" envDefault:"chessbot"`
+	IRCChannels        []string      `env:"IRCCHANNELS" envSeparator:","`
+	EmailAddress       string        `env:"EMAILADDRESS"`
+	EmailAuthServID    string        `env:"EMAILAUTHSERVID"`
+	EmailPollInterval  time.Duration `env:"EMAILPOLLINTERVAL" envDefault:"1m"`
+	SMTPAddr           string        `env:"SMTPADDR"`
+	SMTPUser           string        `env:"SMTPUSER"`
+	SMTPPassword       string        `env:"SMTPPASSWORD"`
+	IMAPAddr           string        `env:"IMAPADDR"`
+	IMAPTLS            bool          `env:"IMAPTLS" envDefault:"true"`
+	IMAPUser           string        `env:"IMAPUSER"`
+	IMAPPassword       string        `env:"IMAPPASSWORD"`
 }
 
 // ParseConfiguration retrieves values from environment variables and returns a Configuration struct
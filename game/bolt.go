@@ -0,0 +1,317 @@
+package game
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	gamesBucket      = []byte("games")
+	gameEventsBucket = []byte("game_events")
+	challengesBucket = []byte("challenges")
+)
+
+// gameRecord is a game as kept by the bolt store, and as copied between the bolt and sqlite stores
+type gameRecord struct {
+	ID          string
+	White       string
+	Black       string
+	WorkspaceID string
+	ChannelID   string
+	LastMoved   time.Time
+	PGN         string
+	Version     int
+}
+
+func newGameRecord(ID string, gm *Game) gameRecord {
+	return gameRecord{
+		ID:          ID,
+		White:       gm.Players[White].ID,
+		Black:       gm.Players[Black].ID,
+		WorkspaceID: gm.WorkspaceID,
+		ChannelID:   gm.ChannelID,
+		LastMoved:   gm.LastMoved(),
+		PGN:         gm.PGN(),
+		Version:     gm.version,
+	}
+}
+
+func (r gameRecord) game() (*Game, error) {
+	gm, err := NewGameFromPGN(r.ID, r.PGN, Player{ID: r.White}, Player{ID: r.Black})
+	if err != nil {
+		return nil, err
+	}
+	gm.lastMoved = r.LastMoved
+	gm.WorkspaceID = r.WorkspaceID
+	gm.ChannelID = r.ChannelID
+	gm.version = r.Version
+	return gm, nil
+}
+
+// BoltStore is an implementation of GameStorage and ChallengeStorage interfaces that persists in an embedded bolt
+// database. Unlike the sqlite store it does not need cgo.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore creates (if not exists) the buckets of games, their events and challenges in the database.
+// The database can be shared with the bolt stores of other packages.
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{gamesBucket, gameEventsBucket, challengesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// StoreGame stores a game by ID along with the events recorded since it was retrieved,
+// unless it was stored by someone else since it was retrieved
+func (b *BoltStore) StoreGame(ID string, gm *Game) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		current, err := getGameRecord(tx, ID)
+		if err != nil {
+			return err
+		}
+		if (current == nil && gm.version != 0) || (current != nil && current.Version != gm.version) {
+			return &ConflictError{ID: ID, Version: gm.version}
+		}
+		record := newGameRecord(ID, gm)
+		record.Version++
+		return putGameRecord(tx, record, gm.unsavedEvents())
+	})
+	if err != nil {
+		return err
+	}
+	gm.stored()
+	return nil
+}
+
+// RetrieveGame retrieves a game by ID
+func (b *BoltStore) RetrieveGame(ID string) (*Game, error) {
+	var record *gameRecord
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getGameRecord(tx, ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("Game by %v not found", ID)
+	}
+	return record.game()
+}
+
+// ListGames retrieves all games of a workspace, ordered by ID
+func (b *BoltStore) ListGames(workspaceID string) ([]*Game, error) {
+	records := []gameRecord{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(gamesBucket).ForEach(func(key []byte, value []byte) error {
+			record := gameRecord{}
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if record.WorkspaceID == workspaceID {
+				records = append(records, record)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	games := []*Game{}
+	for _, record := range records {
+		gm, err := record.game()
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, gm)
+	}
+	return games, nil
+}
+
+// Events returns the history of a game, oldest event first
+func (b *BoltStore) Events(gameID string) ([]Event, error) {
+	events := []Event{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		events, err = getEvents(tx, gameID)
+		return err
+	})
+	return events, err
+}
+
+// StoreChallenge stores a challenge, replacing any challenge between the same players
+func (b *BoltStore) StoreChallenge(challenge *Challenge) error {
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(challengesBucket).Put(challengeKey(challenge.ChallengerID, challenge.ChallengedID), value)
+	})
+}
+
+// RetrieveChallenge retrives a challenge by the challenger and challenged ID
+func (b *BoltStore) RetrieveChallenge(challengerID string, challengedID string) (*Challenge, error) {
+	challenge := &Challenge{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(challengesBucket).Get(challengeKey(challengerID, challengedID))
+		if value == nil {
+			return fmt.Errorf("Challenge %v%v not found", challengerID, challengedID)
+		}
+		return json.Unmarshal(value, challenge)
+	})
+	return challenge, err
+}
+
+// RemoveChallenge removes a challenge from the DB
+func (b *BoltStore) RemoveChallenge(challengerID string, challengedID string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(challengesBucket).Delete(challengeKey(challengerID, challengedID))
+	})
+}
+
+// ImportSqlite copies every game, with its history, and every challenge of the sqlite store into the bolt store.
+// Games and challenges already in the bolt store are replaced.
+func (b *BoltStore) ImportSqlite(store *SqliteStore) error {
+	records, err := store.gameRecords()
+	if err != nil {
+		return err
+	}
+	challenges, err := store.challenges()
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, record := range records {
+			events, err := store.Events(record.ID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(gameEventsBucket).DeleteBucket([]byte(record.ID)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if err := putGameRecord(tx, record, events); err != nil {
+				return err
+			}
+		}
+		for _, challenge := range challenges {
+			value, err := json.Marshal(challenge)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(challengesBucket).Put(challengeKey(challenge.ChallengerID, challenge.ChallengedID), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExportSqlite copies every game, with its history, and every challenge of the bolt store into the sqlite store.
+// Games and challenges already in the sqlite store are replaced.
+func (b *BoltStore) ExportSqlite(store *SqliteStore) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(gamesBucket).ForEach(func(key []byte, value []byte) error {
+			record := gameRecord{}
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			events, err := getEvents(tx, record.ID)
+			if err != nil {
+				return err
+			}
+			return store.putGameRecord(record, events)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(challengesBucket).ForEach(func(key []byte, value []byte) error {
+			challenge := Challenge{}
+			if err := json.Unmarshal(value, &challenge); err != nil {
+				return err
+			}
+			return store.putChallenge(challenge)
+		})
+	})
+}
+
+func challengeKey(challengerID string, challengedID string) []byte {
+	return []byte(challengerID + "\x00" + challengedID)
+}
+
+func getGameRecord(tx *bolt.Tx, ID string) (*gameRecord, error) {
+	value := tx.Bucket(gamesBucket).Get([]byte(ID))
+	if value == nil {
+		return nil, nil
+	}
+	record := &gameRecord{}
+	return record, json.Unmarshal(value, record)
+}
+
+// putGameRecord stores the game and appends the events to its history
+func putGameRecord(tx *bolt.Tx, record gameRecord, events []Event) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(gamesBucket).Put([]byte(record.ID), value); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	history, err := tx.Bucket(gameEventsBucket).CreateBucketIfNotExists([]byte(record.ID))
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		event.GameID = record.ID
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		sequence, err := history.NextSequence()
+		if err != nil {
+			return err
+		}
+		// big endian keys keep the events in the order they were stored
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, sequence)
+		if err := history.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getEvents(tx *bolt.Tx, gameID string) ([]Event, error) {
+	events := []Event{}
+	history := tx.Bucket(gameEventsBucket).Bucket([]byte(gameID))
+	if history == nil {
+		return events, nil
+	}
+	err := history.ForEach(func(key []byte, value []byte) error {
+		event := Event{}
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
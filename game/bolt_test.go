@@ -0,0 +1,84 @@
+package game_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStoreImportsAndExportsSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := game.NewSqliteStore(filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// players are not shuffled, which would change the colors expected by the other tests of the package
+	gm, err := game.NewGameFromPGN("1234", "*", game.Player{ID: "U1"}, game.Player{ID: "U2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.WorkspaceID = "T1"
+	gm.ChannelID = "C1"
+	gm.MoveAs(gm.TurnPlayer().ID, "e2e4")
+	if err := sqlite.StoreGame(gm.ID, gm); err != nil {
+		t.Fatal(err)
+	}
+	challenge := &game.Challenge{ChallengerID: "U1", ChallengedID: "U2", GameID: "1234", ChannelID: "C1"}
+	if err := sqlite.StoreChallenge(challenge); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "chessbot.bolt"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, err := game.NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ImportSqlite(sqlite); err != nil {
+		t.Fatal(err)
+	}
+	games, err := store.ListGames("T1")
+	if err != nil || len(games) != 1 || games[0].PGN() != gm.PGN() || games[0].ChannelID != "C1" {
+		t.Fatalf("expected the game to be imported, got %v (%v)", games, err)
+	}
+	if events, _ := store.Events("1234"); len(events) != 2 {
+		t.Errorf("expected the history to be imported, got %+v", events)
+	}
+	if imported, err := store.RetrieveChallenge("U1", "U2"); err != nil || *imported != *challenge {
+		t.Errorf("expected the challenge to be imported, got %v (%v)", imported, err)
+	}
+
+	imported := games[0]
+	if _, err := imported.MoveAs(imported.TurnPlayer().ID, "e7e5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreGame(imported.ID, imported); err != nil {
+		t.Fatalf("expected the imported game to keep its version: %v", err)
+	}
+	exported, err := game.NewSqliteStore(filepath.Join(dir, "exported.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ExportSqlite(exported); err != nil {
+		t.Fatal(err)
+	}
+	exportedGame, err := exported.RetrieveGame("1234")
+	if err != nil || len(exportedGame.Moves()) != 2 || exportedGame.Version() != imported.Version() {
+		t.Fatalf("expected the game to be exported, got %v (%v)", exportedGame, err)
+	}
+	if events, _ := exported.Events("1234"); len(events) != 3 {
+		t.Errorf("expected the history to be exported, got %+v", events)
+	}
+	if _, err := exported.RetrieveChallenge("U1", "U2"); err != nil {
+		t.Errorf("expected the challenge to be exported: %v", err)
+	}
+}
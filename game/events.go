@@ -0,0 +1,147 @@
+package game
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// EventType is the kind of action recorded in the history of a game
+type EventType string
+
+// EventChallenge is the creation of a game, followed by the moves, take backs and resignations of its players
+const (
+	EventChallenge EventType = "challenge"
+	EventMove      EventType = "move"
+	EventTakeback  EventType = "takeback"
+	EventResign    EventType = "resign"
+)
+
+// Event is an action taken in a game. The events of a game are its full history, replaying them rebuilds the game.
+type Event struct {
+	GameID string
+	Type   EventType
+	// ActorID is the member who acted, or the whole team when the member is not known
+	ActorID string
+	// Move is the move played or taken back in UCI notation
+	Move string
+	// White, Black, WorkspaceID and FEN describe the game created by a challenge
+	White       string
+	Black       string
+	WorkspaceID string
+	FEN         string
+	CreatedAt   time.Time
+}
+
+// EventStorage is implemented by storages keeping the history of every game, oldest event first
+type EventStorage interface {
+	Events(gameID string) ([]Event, error)
+}
+
+// ErrIncompleteHistory is an error representing a history that does not start with the creation of the game,
+// e.g. for games stored before their events were recorded
+var ErrIncompleteHistory = errors.New("the history of the game is incomplete")
+
+// record adds an action to the events stored along with the game
+func (g *Game) record(eventType EventType, actorID string, move string) {
+	g.events = append(g.events, Event{
+		GameID:    g.ID,
+		Type:      eventType,
+		ActorID:   actorID,
+		Move:      move,
+		CreatedAt: g.timeProvider(),
+	})
+}
+
+// unsavedEvents are the events to store along with the game, starting with its creation when it was never stored
+func (g *Game) unsavedEvents() []Event {
+	if g.version != 0 {
+		return g.events
+	}
+	createdAt := g.timeProvider()
+	if len(g.events) > 0 {
+		createdAt = g.events[0].CreatedAt
+	}
+	created := Event{
+		GameID:      g.ID,
+		Type:        EventChallenge,
+		ActorID:     g.CreatedBy,
+		White:       g.Players[White].ID,
+		Black:       g.Players[Black].ID,
+		WorkspaceID: g.WorkspaceID,
+		FEN:         g.game.Positions()[0].String(),
+		CreatedAt:   createdAt,
+	}
+	return append([]Event{created}, g.events...)
+}
+
+// Replay rebuilds a game from its history
+func Replay(events []Event) (*Game, error) {
+	if len(events) == 0 || events[0].Type != EventChallenge {
+		return nil, ErrIncompleteHistory
+	}
+	created := events[0]
+	start, err := chess.FEN(created.FEN)
+	if err != nil {
+		return nil, err
+	}
+	gm := &Game{
+		ID:           created.GameID,
+		WorkspaceID:  created.WorkspaceID,
+		CreatedBy:    created.ActorID,
+		game:         chess.NewGame(start, chess.UseNotation(chess.LongAlgebraicNotation{})),
+		timeProvider: defaultTimeProvider,
+		Players: map[Color]Player{
+			White: {ID: created.White, color: White},
+			Black: {ID: created.Black, color: Black},
+		},
+	}
+	for _, event := range events[1:] {
+		switch event.Type {
+		case EventMove:
+			if err := gm.game.MoveStr(event.Move); err != nil {
+				return nil, err
+			}
+			gm.started = true
+			gm.lastMoved = event.CreatedAt
+		case EventTakeback:
+			if gm.LastMove() == nil || gm.LastMove().String() != event.Move {
+				return nil, ErrIncompleteHistory
+			}
+			gm.undo()
+			gm.lastMoved = time.Time{}
+		case EventResign:
+			player, err := gm.PlayerByID(event.ActorID)
+			if err != nil {
+				return nil, err
+			}
+			gm.game.Resign(colorMap[player.color])
+		}
+	}
+	return gm, nil
+}
+
+// scanEvents reads the rows of the game_events table, as selected by the sql stores
+func scanEvents(gameID string, rows *sql.Rows) ([]Event, error) {
+	events := []Event{}
+	for rows.Next() {
+		event := Event{GameID: gameID}
+		var eventType string
+		if err := rows.Scan(
+			&eventType,
+			&event.ActorID,
+			&event.Move,
+			&event.White,
+			&event.Black,
+			&event.WorkspaceID,
+			&event.FEN,
+			&event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Type = EventType(eventType)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
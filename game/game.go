@@ -50,13 +50,23 @@ type Player struct {
 
 // Game is the state of a game (active or not)
 type Game struct {
-	ID           string
+	ID          string
+	WorkspaceID string
+	// CreatedBy is the member who created the game, recorded as the actor of its first event
+	CreatedBy string
+	// ChannelID is the conversation the game is played in, for platforms whose game ID does not identify it
+	ChannelID    string
 	game         *chess.Game
 	Players      map[Color]Player
 	started      bool
 	lastMoved    time.Time
 	checkedTile  *chess.Square
 	timeProvider TimeProvider
+	version      int
+	// events are the actions taken since the game was last stored
+	events []Event
+	// storedEvents are the events saved the last time the game was stored
+	storedEvents []Event
 }
 
 // NewGame will create a new game with typical starting positions
@@ -122,19 +132,53 @@ func NewGameFromPGN(ID string, pgn string, white Player, black Player) (*Game, e
 	return game, nil
 }
 
+// ErrPlayerNotFound is an error representing a lookup of a player that is not part of the game.
+var ErrPlayerNotFound = errors.New("player not found with given ID")
+
 // PlayerByID returns a reference to a player given their ID
+// A player can either be a single member or a space padded list of team members (" U1 U2 ")
 func (g *Game) PlayerByID(ID string) (*Player, error) {
 	for _, player := range g.Players {
-		if strings.Contains(player.ID, " "+ID+" ") {
+		if player.HasMember(ID) {
 			return &player, nil
 		}
 	}
-	return nil, errors.New("player not found with given ID")
+	return nil, ErrPlayerNotFound
+}
+
+// HasMember determines if the given ID is the player or one of the player's team members
+func (p Player) HasMember(ID string) bool {
+	return p.ID == ID || strings.Contains(p.ID, " "+ID+" ")
+}
+
+// Members returns the IDs of all team members that make up this player
+func (p Player) Members() []string {
+	return strings.Fields(p.ID)
 }
 
 // Resign will resign a player from the game
 func (g *Game) Resign(resigner Player) {
+	g.resign(resigner, resigner.ID)
+}
+
+// ResignAs resigns the player of a member, recording which member resigned
+func (g *Game) ResignAs(memberID string) error {
+	player, err := g.PlayerByID(memberID)
+	if err != nil {
+		return err
+	}
+	g.resign(*player, memberID)
+	return nil
+}
+
+func (g *Game) resign(resigner Player, actorID string) {
+	if g.Outcome() != chess.NoOutcome {
+		return
+	}
 	g.game.Resign(colorMap[resigner.color])
+	if g.Outcome() != chess.NoOutcome {
+		g.record(EventResign, actorID, "")
+	}
 }
 
 // TurnPlayer returns which player should move next
@@ -181,6 +225,11 @@ func (g *Game) Outcome() chess.Outcome {
 	return g.game.Outcome()
 }
 
+// Method returns the method by which the game was completed (checkmate, resignation, etc)
+func (g *Game) Method() chess.Method {
+	return g.game.Method()
+}
+
 // ResultText will show the outcome of the game in textual format
 func (g *Game) ResultText() string {
 	outcome := g.Outcome()
@@ -205,19 +254,50 @@ func (g *Game) LastMove() *chess.Move {
 	return moves[len(moves)-1]
 }
 
+// Moves returns all moves played so far
+func (g *Game) Moves() []*chess.Move {
+	return g.game.Moves()
+}
+
+// Positions returns every position of the game, starting with the initial one
+func (g *Game) Positions() []*chess.Position {
+	return g.game.Positions()
+}
+
+// Version is the number of times the game was stored, storages refuse to overwrite a newer version of the game
+func (g *Game) Version() int {
+	return g.version
+}
+
 // LastMoved is the last time a move was made
 func (g *Game) LastMoved() time.Time {
 	return g.lastMoved
 }
 
+// ErrNotPlayersTurn is an error representing a move attempted by a member of the player that is not to move.
+var ErrNotPlayersTurn = errors.New("Please wait for your turn.")
+
+// MoveAs moves a Chess piece on behalf of a player's member, ensuring it is that player's turn
+func (g *Game) MoveAs(memberID string, san string) (*chess.Move, error) {
+	if !g.TurnPlayer().HasMember(memberID) {
+		return nil, ErrNotPlayersTurn
+	}
+	return g.move(memberID, san)
+}
+
 // Move a Chess piece based on standard algabreic notation (d2d4, etc)
 func (g *Game) Move(san string) (*chess.Move, error) {
+	return g.move("", san)
+}
+
+func (g *Game) move(actorID string, san string) (*chess.Move, error) {
 	err := g.game.MoveStr(san)
 	if err != nil {
 		return nil, err
 	}
 	g.started = true
 	g.lastMoved = g.timeProvider()
+	g.record(EventMove, actorID, g.LastMove().String())
 	return g.LastMove(), nil
 }
 
@@ -231,6 +311,11 @@ func (g *Game) Started() bool {
 	return g.started
 }
 
+// Board returns the board of the current position
+func (g *Game) Board() *chess.Board {
+	return g.game.Position().Board()
+}
+
 // ValidMoves returns a list of all moves available to the current player's turn
 func (g *Game) ValidMoves() []*chess.Move {
 	return g.game.ValidMoves()
@@ -263,6 +348,19 @@ var ErrPastTimeThreshold = fmt.Errorf("exceeded threshold of %v", TakebackThresh
 // Takeback reverts the game to the previous move prior to the last move.
 // Note: If the first move of the game is taken back, the resulting move will be nil
 func (g *Game) Takeback(requestingPlayer *Player) (*chess.Move, error) {
+	return g.takeback(requestingPlayer, requestingPlayer.ID)
+}
+
+// TakebackAs reverts the last move on behalf of a member, recording which member asked for it
+func (g *Game) TakebackAs(memberID string) (*chess.Move, error) {
+	player, err := g.PlayerByID(memberID)
+	if err != nil {
+		return nil, err
+	}
+	return g.takeback(player, memberID)
+}
+
+func (g *Game) takeback(requestingPlayer *Player, actorID string) (*chess.Move, error) {
 	if g.LastMove() == nil {
 		return nil, ErrGameHasNoMoves
 	}
@@ -276,16 +374,24 @@ func (g *Game) Takeback(requestingPlayer *Player) (*chess.Move, error) {
 	if elapsed := g.timeProvider().Sub(g.LastMoved()); elapsed > TakebackThreshold {
 		return nil, ErrPastTimeThreshold
 	}
-	newGame := chess.NewGame(chess.UseNotation(chess.LongAlgebraicNotation{}))
+	takenBack := g.LastMove().String()
+	g.undo()
+	// Prevent cascading takebacks
+	g.lastMoved = time.Time{}
+	g.record(EventTakeback, actorID, takenBack)
+	return g.LastMove(), nil
+}
+
+// undo replays every move but the last one from the starting position
+func (g *Game) undo() {
+	start, _ := chess.FEN(g.game.Positions()[0].String())
+	newGame := chess.NewGame(start, chess.UseNotation(chess.LongAlgebraicNotation{}))
 	moves := g.game.Moves()
 	withoutLast := moves[:len(moves)-1]
 	for _, move := range withoutLast {
 		newGame.Move(move)
 	}
 	g.game = newGame
-	// Prevent cascading takebacks
-	g.lastMoved = time.Time{}
-	return g.LastMove(), nil
 }
 
 // SetTimeProvider allows the time provider to be overwritten (exclusively for testing)
@@ -2,12 +2,17 @@ package game
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 )
 
 // MemoryStore implements the Game and Challenge storage interfaces and holds all state in memory
 // Once the MemoryStore instance is released, all data in that storage is lost
 type MemoryStore struct {
+	mu         sync.Mutex
 	games      map[string]*Game
+	versions   map[string]int
+	events     map[string][]Event
 	challenges map[string]*Challenge
 }
 
@@ -15,26 +20,59 @@ type MemoryStore struct {
 func NewMemoryStore() *MemoryStore {
 	store := MemoryStore{
 		games:      make(map[string]*Game, 10),
+		versions:   make(map[string]int, 10),
+		events:     make(map[string][]Event, 10),
 		challenges: make(map[string]*Challenge, 10),
 	}
 	return &store
 }
 
-// RetrieveGame will get a game from storage by its ID
+// RetrieveGame will get a copy of a game from storage by its ID, changes to it are only kept once it is stored
 func (m *MemoryStore) RetrieveGame(ID string) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	gm, ok := m.games[ID]
 	if !ok {
 		return nil, fmt.Errorf("Game by %v not found", ID)
 	}
-	return gm, nil
+	return gm.copy(), nil
 }
 
-// StoreGame persists a game into memory
+// StoreGame persists a game into memory, unless another game was stored by the ID since it was retrieved
 func (m *MemoryStore) StoreGame(ID string, game *Game) error {
-	m.games[ID] = game
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.versions[ID] != game.version {
+		return &ConflictError{ID: ID, Version: game.version}
+	}
+	m.events[ID] = append(m.events[ID], game.unsavedEvents()...)
+	game.stored()
+	m.games[ID] = game.copy()
+	m.versions[ID] = game.version
 	return nil
 }
 
+// Events returns the history of a game
+func (m *MemoryStore) Events(gameID string) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Event{}, m.events[gameID]...), nil
+}
+
+// ListGames returns all games of a workspace
+func (m *MemoryStore) ListGames(workspaceID string) ([]*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	games := []*Game{}
+	for _, gm := range m.games {
+		if gm.WorkspaceID == workspaceID {
+			games = append(games, gm.copy())
+		}
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+	return games, nil
+}
+
 // RetrieveChallenge will get a challenge request by challenger ID and challenged ID
 func (m *MemoryStore) RetrieveChallenge(challengerID string, challengedID string) (*Challenge, error) {
 	challenge, ok := m.challenges[challengerID+challengedID]
@@ -0,0 +1,64 @@
+package game
+
+import (
+	"errors"
+	"sync"
+)
+
+// GameObserver is notified whenever a game has been persisted
+type GameObserver interface {
+	GameStored(gm *Game)
+}
+
+// ObservedStore wraps a GameStorage and notifies all observers once a game is stored
+type ObservedStore struct {
+	GameStorage
+	mu        sync.RWMutex
+	observers []GameObserver
+}
+
+// NewObservedStore returns an ObservedStore pointer wrapping the given storage
+func NewObservedStore(store GameStorage, observers ...GameObserver) *ObservedStore {
+	return &ObservedStore{
+		GameStorage: store,
+		observers:   observers,
+	}
+}
+
+// Observe registers an observer to be notified of every stored game
+func (o *ObservedStore) Observe(observer GameObserver) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observers = append(o.observers, observer)
+}
+
+// StoreGame persists the game in the wrapped storage and notifies the observers on success
+func (o *ObservedStore) StoreGame(ID string, gm *Game) error {
+	if err := o.GameStorage.StoreGame(ID, gm); err != nil {
+		return err
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, observer := range o.observers {
+		observer.GameStored(gm)
+	}
+	return nil
+}
+
+// ListGames lists the games of a workspace if the wrapped storage supports it
+func (o *ObservedStore) ListGames(workspaceID string) ([]*Game, error) {
+	lister, ok := o.GameStorage.(GameLister)
+	if !ok {
+		return nil, errors.New("game storage does not support listing games")
+	}
+	return lister.ListGames(workspaceID)
+}
+
+// Events returns the history of a game if the wrapped storage keeps it
+func (o *ObservedStore) Events(gameID string) ([]Event, error) {
+	events, ok := o.GameStorage.(EventStorage)
+	if !ok {
+		return nil, errors.New("game storage does not keep the history of games")
+	}
+	return events.Events(gameID)
+}
@@ -0,0 +1,222 @@
+package game
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cjsaylor/chessbot/migration"
+
+	// import postgres package for use with the sql interface
+	_ "github.com/lib/pq"
+)
+
+// PostgresMigrations creates and evolves the game and challenge tables on Postgres
+var PostgresMigrations = migration.Set{
+	Component: "game",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create games and challenges",
+			Up: `
+				CREATE TABLE games (
+					id text PRIMARY KEY,
+					player_white_id text NOT NULL,
+					player_black_id text NOT NULL,
+					last_moved timestamptz NOT NULL,
+					pgn text NOT NULL,
+					workspace_id text NOT NULL DEFAULT ''
+				);
+				CREATE INDEX games_workspace_id ON games (workspace_id);
+				CREATE TABLE challenges (
+					challenger_id text NOT NULL,
+					challenged_id text NOT NULL,
+					channel_id text NOT NULL,
+					game_id text NOT NULL UNIQUE,
+					PRIMARY KEY (challenger_id, challenged_id)
+				);
+			`,
+		},
+		{
+			Version:     2,
+			Description: "add game versions",
+			Up:          "ALTER TABLE games ADD COLUMN version integer NOT NULL DEFAULT 0;",
+		},
+		{
+			Version:     3,
+			Description: "create game events",
+			Up: `
+				CREATE TABLE game_events (
+					id bigserial PRIMARY KEY,
+					game_id text NOT NULL,
+					type text NOT NULL,
+					actor_id text NOT NULL,
+					move text NOT NULL,
+					white_id text NOT NULL,
+					black_id text NOT NULL,
+					workspace_id text NOT NULL,
+					fen text NOT NULL,
+					created_at timestamptz NOT NULL
+				);
+				CREATE INDEX game_events_game_id ON game_events (game_id, id);
+			`,
+		},
+		{
+			Version:     4,
+			Description: "add game channels",
+			Up:          "ALTER TABLE games ADD COLUMN channel_id text NOT NULL DEFAULT '';",
+		},
+	},
+}
+
+// PostgresStore is an implementation of GameStorage and ChallengeStorage interfaces that persists using Postgres.
+// Unlike the sqlite store it can be shared by several instances of the bot, concurrent updates of a game are
+// detected with its version.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore migrates the game and challenge tables of the database to the latest version.
+// The connection pool is shared with the other stores and configured by the caller.
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	if err := migration.Apply(db, migration.Postgres, PostgresMigrations); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// StoreGame stores a game by ID along with the events recorded since it was retrieved.
+// If a game is already established, only the PGN log is updated, unless it was stored by someone else since it was
+// retrieved.
+func (s *PostgresStore) StoreGame(ID string, gm *Game) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec("update games set pgn = $1, last_moved = $2, version = version + 1 where id = $3 and version = $4", gm.PGN(), gm.LastMoved(), ID, gm.version)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		if gm.version != 0 {
+			return &ConflictError{ID: ID, Version: gm.version}
+		}
+		result, err := tx.Exec(`
+			insert into games (id, player_white_id, player_black_id, last_moved, pgn, workspace_id, channel_id, version)
+			values ($1, $2, $3, $4, $5, $6, $7, 1) on conflict (id) do nothing
+		`, ID, gm.Players[White].ID, gm.Players[Black].ID, gm.LastMoved(), gm.PGN(), gm.WorkspaceID, gm.ChannelID)
+		if err != nil {
+			return err
+		}
+		if inserted, err := result.RowsAffected(); err != nil {
+			return err
+		} else if inserted == 0 {
+			return &ConflictError{ID: ID, Version: gm.version}
+		}
+	}
+	for _, event := range gm.unsavedEvents() {
+		_, err := tx.Exec(`
+			insert into game_events (game_id, type, actor_id, move, white_id, black_id, workspace_id, fen, created_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, ID, string(event.Type), event.ActorID, event.Move, event.White, event.Black, event.WorkspaceID, event.FEN, event.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	gm.stored()
+	return nil
+}
+
+// Events returns the history of a game, oldest event first
+func (s *PostgresStore) Events(gameID string) ([]Event, error) {
+	rows, err := s.db.Query(`
+		select type, actor_id, move, white_id, black_id, workspace_id, fen, created_at
+		from game_events where game_id = $1 order by id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(gameID, rows)
+}
+
+// RetrieveGame retrieves a game by ID
+func (s *PostgresStore) RetrieveGame(ID string) (*Game, error) {
+	var player1, player2, pgn, workspaceID, channelID string
+	var lastMoved time.Time
+	var version int
+	row := s.db.QueryRow("select player_white_id, player_black_id, last_moved, pgn, workspace_id, channel_id, version from games where id = $1", ID)
+	if err := row.Scan(&player1, &player2, &lastMoved, &pgn, &workspaceID, &channelID, &version); err != nil {
+		return nil, err
+	}
+	gm, err := NewGameFromPGN(ID, pgn, Player{
+		ID: player1,
+	}, Player{
+		ID: player2,
+	})
+	if err == nil {
+		gm.lastMoved = lastMoved
+		gm.WorkspaceID = workspaceID
+		gm.ChannelID = channelID
+		gm.version = version
+	}
+	return gm, err
+}
+
+// ListGames retrieves all games of a workspace
+func (s *PostgresStore) ListGames(workspaceID string) ([]*Game, error) {
+	rows, err := s.db.Query("select id, player_white_id, player_black_id, last_moved, pgn, channel_id, version from games where workspace_id = $1 order by id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	games := []*Game{}
+	for rows.Next() {
+		var ID, player1, player2, pgn, channelID string
+		var lastMoved time.Time
+		var version int
+		if err := rows.Scan(&ID, &player1, &player2, &lastMoved, &pgn, &channelID, &version); err != nil {
+			return nil, err
+		}
+		gm, err := NewGameFromPGN(ID, pgn, Player{ID: player1}, Player{ID: player2})
+		if err != nil {
+			return nil, err
+		}
+		gm.lastMoved = lastMoved
+		gm.WorkspaceID = workspaceID
+		gm.ChannelID = channelID
+		gm.version = version
+		games = append(games, gm)
+	}
+	return games, rows.Err()
+}
+
+// StoreChallenge only supports inserting new challenges. Challenges should not be updated only inserted/removed
+func (s *PostgresStore) StoreChallenge(challenge *Challenge) error {
+	_, err := s.db.Exec(
+		"insert into challenges (challenger_id, challenged_id, game_id, channel_id) values ($1, $2, $3, $4)",
+		challenge.ChallengerID, challenge.ChallengedID, challenge.GameID, challenge.ChannelID)
+	return err
+}
+
+// RetrieveChallenge retrives a challenge by the challenger and challenged ID
+func (s *PostgresStore) RetrieveChallenge(challengerID string, challengedID string) (*Challenge, error) {
+	challenge := Challenge{
+		ChallengerID: challengerID,
+		ChallengedID: challengedID,
+	}
+	row := s.db.QueryRow("select game_id, channel_id from challenges where challenger_id = $1 and challenged_id = $2", challengerID, challengedID)
+	err := row.Scan(&challenge.GameID, &challenge.ChannelID)
+	return &challenge, err
+}
+
+// RemoveChallenge removes a challenge from the DB
+func (s *PostgresStore) RemoveChallenge(challengerID string, challengedID string) error {
+	_, err := s.db.Exec("delete from challenges where challenger_id = $1 and challenged_id = $2", challengerID, challengedID)
+	return err
+}
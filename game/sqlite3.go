@@ -4,122 +4,162 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"github.com/cjsaylor/chessbot/migration"
+
 	// import sqlite package for use with the sql interface
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const gameTabelCreation = `
-	CREATE TABLE IF NOT EXISTS games (
-		id text PRIMARY KEY,
-		player_white_id text,
-		player_black_id text,
-		last_moved datetime,
-		pgn text
-	);
-`
-
-const challengeTableCreation = `
-	CREATE TABLE IF NOT EXISTS challenges (
-		challenger_id text NOT NULL,
-		challenged_id text NOT NULL,
-		channel_id text NOT NULL,
-		game_id text NOT NULL UNIQUE,
-		PRIMARY KEY (challenger_id, challenged_id)
-	);
-`
+// SqliteMigrations creates and evolves the game, challenge and event tables on sqlite.
+// The first migrations tolerate databases created before the schema was versioned.
+var SqliteMigrations = migration.Set{
+	Component: "game",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create games and challenges",
+			Up: `
+				CREATE TABLE IF NOT EXISTS games (
+					id text PRIMARY KEY,
+					player_white_id text,
+					player_black_id text,
+					last_moved datetime,
+					pgn text
+				);
+				CREATE TABLE IF NOT EXISTS challenges (
+					challenger_id text NOT NULL,
+					challenged_id text NOT NULL,
+					channel_id text NOT NULL,
+					game_id text NOT NULL UNIQUE,
+					PRIMARY KEY (challenger_id, challenged_id)
+				);
+			`,
+		},
+		{
+			Version:     2,
+			Description: "add game workspaces",
+			Run:         migration.AddSqliteColumn("games", "workspace_id", "text"),
+		},
+		{
+			Version:     3,
+			Description: "add game versions",
+			Run:         migration.AddSqliteColumn("games", "version", "integer NOT NULL DEFAULT 0"),
+		},
+		{
+			Version:     4,
+			Description: "create game events",
+			Up: `
+				CREATE TABLE IF NOT EXISTS game_events (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					game_id text NOT NULL,
+					type text NOT NULL,
+					actor_id text NOT NULL,
+					move text NOT NULL,
+					white_id text NOT NULL,
+					black_id text NOT NULL,
+					workspace_id text NOT NULL,
+					fen text NOT NULL,
+					created_at timestamp NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS game_events_game_id ON game_events (game_id, id);
+			`,
+		},
+		{
+			Version:     5,
+			Description: "add game channels",
+			Run:         migration.AddSqliteColumn("games", "channel_id", "text NOT NULL DEFAULT ''"),
+		},
+	},
+}
 
 // SqliteStore is an implementation of GameStorage and ChallengeStorage interfaces that persists using sqlite3
 type SqliteStore struct {
-	path       string
-	db         *sql.DB
-	s3uploader *s3manager.Uploader
-	s3bucket   string
-	s3key      string
+	path string
+	db   *sql.DB
 }
 
-// NewSqliteStore creates (if not exists) the DB file and structure at the path specified
+// NewSqliteStore creates (if not exists) the DB file at the path specified and migrates its structure
 // It implements the GameStorage and ChallengeStorage interface and is intended as a suitable
 // perminent storage of games and challenges
-func NewSqliteStore(path string, s3uploader *s3manager.Uploader, s3bucket string, s3key string) (*SqliteStore, error) {
+func NewSqliteStore(path string) (*SqliteStore, error) {
 	store := SqliteStore{
-		path:       path,
-		s3uploader: s3uploader,
-		s3bucket:   s3bucket,
-		s3key:      s3key,
+		path: path,
 	}
 	db, err := sql.Open("sqlite3", fmt.Sprintf("%v?parseTime=1", path))
 	if err != nil {
 		return nil, err
 	}
-	if _, err = db.Exec(gameTabelCreation); err != nil {
-		return nil, err
-	}
-	if _, err = db.Exec(challengeTableCreation); err != nil {
+	if err = migration.Apply(db, migration.Sqlite, SqliteMigrations); err != nil {
 		return nil, err
 	}
 	store.db = db
 	return &store, nil
 }
 
-// StoreGame stores a game by ID.
-// If a game is already established, only the PGN log is updated
+// StoreGame stores a game by ID along with the events recorded since it was retrieved.
+// If a game is already established, only the PGN log is updated, unless it was stored by someone else since it was
+// retrieved.
 func (s *SqliteStore) StoreGame(ID string, gm *Game) error {
 	log.Printf("SGameId = %v", ID)
-	if _, err := s.RetrieveGame(ID); err == nil {
-		stmt, _ := s.db.Prepare("update games set pgn = ?, last_moved = ? where id = ?")
-		defer stmt.Close()
-		_, err := stmt.Exec(gm.PGN(), gm.LastMoved(), ID)
-		if err != nil {
-			log.Println(err)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec("update games set pgn = ?, last_moved = ?, version = version + 1 where id = ? and version = ?", gm.PGN(), gm.LastMoved(), ID, gm.version)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		var exists bool
+		if err := tx.QueryRow("select exists(select 1 from games where id = ?)", ID).Scan(&exists); err != nil {
 			return err
 		}
-	} else {
-		stmt, _ := s.db.Prepare("insert into games (id, player_white_id, player_black_id, last_moved, pgn) values (?, ?, ?, ?, ?)")
-		defer stmt.Close()
-		_, err := stmt.Exec(ID, gm.Players[White].ID, gm.Players[Black].ID, gm.LastMoved(), gm.PGN())
+		if exists || gm.version != 0 {
+			return &ConflictError{ID: ID, Version: gm.version}
+		}
+		_, err := tx.Exec(
+			"insert into games (id, player_white_id, player_black_id, last_moved, pgn, workspace_id, channel_id, version) values (?, ?, ?, ?, ?, ?, ?, 1)",
+			ID, gm.Players[White].ID, gm.Players[Black].ID, gm.LastMoved(), gm.PGN(), gm.WorkspaceID, gm.ChannelID)
 		if err != nil {
 			return err
 		}
 	}
-
-	// upload the DB file to S3
-	file, ferr := os.Open("./chessbot.db")
-	if ferr != nil {
-		log.Println(ferr)
-		return ferr
-	}
-
-	upParams := &s3manager.UploadInput{
-		Bucket: &s.s3bucket,
-		Key:    &s.s3key,
-		Body:   file,
+	for _, event := range gm.unsavedEvents() {
+		_, err := tx.Exec(`
+			insert into game_events (game_id, type, actor_id, move, white_id, black_id, workspace_id, fen, created_at)
+			values (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, ID, string(event.Type), event.ActorID, event.Move, event.White, event.Black, event.WorkspaceID, event.FEN, event.CreatedAt)
+		if err != nil {
+			return err
+		}
 	}
-	upResult, upErr := s.s3uploader.Upload(upParams)
-	if upErr != nil {
-		log.Println(upErr)
-		return upErr
+	if err := tx.Commit(); err != nil {
+		return err
 	}
-	log.Printf("Uploaded to S3 %v", upResult)
-
+	gm.stored()
 	return nil
 }
 
 // RetrieveGame retrieves a game by ID
 func (s *SqliteStore) RetrieveGame(ID string) (*Game, error) {
 	log.Printf("RGameId = %v", ID)
-	stmt, err := s.db.Prepare("select player_white_id, player_black_id, last_moved, pgn from games where id = ?")
+	stmt, err := s.db.Prepare("select player_white_id, player_black_id, last_moved, pgn, workspace_id, channel_id, version from games where id = ?")
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
-	var player1, player2, pgn string
+	var player1, player2, pgn, channelID string
+	var workspaceID sql.NullString
 	var lastMoved time.Time
+	var version int
 	row := stmt.QueryRow(ID)
-	err = row.Scan(&player1, &player2, &lastMoved, &pgn)
+	err = row.Scan(&player1, &player2, &lastMoved, &pgn, &workspaceID, &channelID, &version)
 	if err != nil {
 		return nil, err
 	}
@@ -130,11 +170,54 @@ func (s *SqliteStore) RetrieveGame(ID string) (*Game, error) {
 	})
 	if err == nil {
 		gm.lastMoved = lastMoved
+		gm.WorkspaceID = workspaceID.String
+		gm.ChannelID = channelID
+		gm.version = version
 	}
 
 	return gm, err
 }
 
+// Events returns the history of a game, oldest event first
+func (s *SqliteStore) Events(gameID string) ([]Event, error) {
+	rows, err := s.db.Query(`
+		select type, actor_id, move, white_id, black_id, workspace_id, fen, created_at
+		from game_events where game_id = ? order by id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEvents(gameID, rows)
+}
+
+// ListGames retrieves all games of a workspace
+func (s *SqliteStore) ListGames(workspaceID string) ([]*Game, error) {
+	rows, err := s.db.Query("select id from games where workspace_id = ? order by id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		var ID string
+		if err := rows.Scan(&ID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, ID)
+	}
+	rows.Close()
+	games := []*Game{}
+	for _, ID := range ids {
+		gm, err := s.RetrieveGame(ID)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, gm)
+	}
+	return games, nil
+}
+
 // StoreChallenge only supports inserting new challenges. Challenges should not be updated only inserted/removed
 func (s *SqliteStore) StoreChallenge(challenge *Challenge) error {
 	stmt, _ := s.db.Prepare("insert into challenges (challenger_id, challenged_id, game_id, channel_id) values (?, ?, ?, ?) ")
@@ -163,3 +246,77 @@ func (s *SqliteStore) RemoveChallenge(challengerID string, challengedID string)
 	_, err := stmt.Exec(challengerID, challengedID)
 	return err
 }
+
+// gameRecords returns every stored game, for copying them into a bolt store
+func (s *SqliteStore) gameRecords() ([]gameRecord, error) {
+	rows, err := s.db.Query("select id, player_white_id, player_black_id, last_moved, pgn, workspace_id, channel_id, version from games order by id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []gameRecord{}
+	for rows.Next() {
+		record := gameRecord{}
+		var workspaceID sql.NullString
+		if err := rows.Scan(&record.ID, &record.White, &record.Black, &record.LastMoved, &record.PGN, &workspaceID, &record.ChannelID, &record.Version); err != nil {
+			return nil, err
+		}
+		record.WorkspaceID = workspaceID.String
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// putGameRecord replaces a game and its history with one copied from a bolt store
+func (s *SqliteStore) putGameRecord(record gameRecord, events []Event) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(
+		"insert or replace into games (id, player_white_id, player_black_id, last_moved, pgn, workspace_id, channel_id, version) values (?, ?, ?, ?, ?, ?, ?, ?)",
+		record.ID, record.White, record.Black, record.LastMoved, record.PGN, record.WorkspaceID, record.ChannelID, record.Version)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("delete from game_events where game_id = ?", record.ID); err != nil {
+		return err
+	}
+	for _, event := range events {
+		_, err := tx.Exec(`
+			insert into game_events (game_id, type, actor_id, move, white_id, black_id, workspace_id, fen, created_at)
+			values (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, record.ID, string(event.Type), event.ActorID, event.Move, event.White, event.Black, event.WorkspaceID, event.FEN, event.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// challenges returns every stored challenge, for copying them into a bolt store
+func (s *SqliteStore) challenges() ([]Challenge, error) {
+	rows, err := s.db.Query("select challenger_id, challenged_id, game_id, channel_id from challenges")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	challenges := []Challenge{}
+	for rows.Next() {
+		challenge := Challenge{}
+		if err := rows.Scan(&challenge.ChallengerID, &challenge.ChallengedID, &challenge.GameID, &challenge.ChannelID); err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges, rows.Err()
+}
+
+// putChallenge replaces a challenge with one copied from a bolt store
+func (s *SqliteStore) putChallenge(challenge Challenge) error {
+	_, err := s.db.Exec(
+		"insert or replace into challenges (challenger_id, challenged_id, game_id, channel_id) values (?, ?, ?, ?)",
+		challenge.ChallengerID, challenge.ChallengedID, challenge.GameID, challenge.ChannelID)
+	return err
+}
@@ -0,0 +1,74 @@
+package game_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/migration"
+)
+
+// copyOldDatabase creates a database file laid out like one written by an earlier release of the store
+func copyOldDatabase(t *testing.T, fixture string) string {
+	schema, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "chessbot.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSqliteStoreMigratesOldDatabases(t *testing.T) {
+	for _, fixture := range []string{"baseline.sql", "unversioned.sql"} {
+		t.Run(fixture, func(t *testing.T) {
+			path := copyOldDatabase(t, fixture)
+			store, err := game.NewSqliteStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gm, err := store.RetrieveGame("old")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gm.Moves()) != 2 || gm.Players[game.White].ID != "U1" {
+				t.Errorf("expected the stored game to survive the migration, got %v", gm.PGN())
+			}
+			if _, err := gm.MoveAs("U1", "c2c4"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.StoreGame("old", gm); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Events("old"); err != nil {
+				t.Error(err)
+			}
+			if challenge, err := store.RetrieveChallenge("U1", "U2"); err != nil || challenge.GameID != "old" {
+				t.Errorf("expected the stored challenge to survive the migration, got %v (%v)", challenge, err)
+			}
+
+			db, err := sql.Open("sqlite3", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			version, err := migration.Version(db, migration.Sqlite, game.SqliteMigrations.Component)
+			if err != nil || version != game.SqliteMigrations.Latest() {
+				t.Errorf("expected schema version %v, got %v (%v)", game.SqliteMigrations.Latest(), version, err)
+			}
+		})
+	}
+}
@@ -1,7 +1,80 @@
 package game
 
+import "fmt"
+
 // GameStorage is an interface to be implemented for persisting a game
 type GameStorage interface {
 	RetrieveGame(ID string) (*Game, error)
+	// StoreGame returns a ConflictError when the game was stored by someone else since it was retrieved
 	StoreGame(ID string, game *Game) error
 }
+
+// GameLister is implemented by storages able to list all games of a workspace
+type GameLister interface {
+	ListGames(workspaceID string) ([]*Game, error)
+}
+
+// stored marks the game and the events recorded since it was retrieved as saved by a storage
+func (g *Game) stored() {
+	g.storedEvents = g.unsavedEvents()
+	g.version++
+	g.events = nil
+}
+
+// copy returns a game independent of this one, as a storage keeping games in memory must hand out.
+// Events not stored yet are copied along, the events of the last store are not.
+func (g *Game) copy() *Game {
+	c := *g
+	c.game = g.game.Clone()
+	c.Players = map[Color]Player{White: g.Players[White], Black: g.Players[Black]}
+	if g.checkedTile != nil {
+		checkedTile := *g.checkedTile
+		c.checkedTile = &checkedTile
+	}
+	c.events = append([]Event(nil), g.events...)
+	c.storedEvents = nil
+	return &c
+}
+
+// StoredEvents are the events saved the last time the game was stored, e.g. for observers to react to a move
+func (g *Game) StoredEvents() []Event {
+	return g.storedEvents
+}
+
+// ConflictError is returned when storing a game that was changed by someone else since it was retrieved
+type ConflictError struct {
+	ID string
+	// Version is the version of the game the rejected change was made to
+	Version int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("someone already moved in game %v, please check the board and try again", e.ID)
+}
+
+// IsConflict reports whether the error is a ConflictError
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+// updateAttempts is how many times a change is applied before a conflict is given up on
+const updateAttempts = 3
+
+// Update applies the change to the game and stores it. When someone else stored the game in the meantime, the change
+// is applied again to a freshly retrieved copy, which lets the change reject the new state (e.g. it is no longer the
+// player's turn). Errors of the change are returned as is, the stored game is returned on success.
+func Update(storage GameStorage, gm *Game, change func(gm *Game) error) (*Game, error) {
+	for attempt := 1; ; attempt++ {
+		if err := change(gm); err != nil {
+			return gm, err
+		}
+		err := storage.StoreGame(gm.ID, gm)
+		if !IsConflict(err) || attempt == updateAttempts {
+			return gm, err
+		}
+		if gm, err = storage.RetrieveGame(gm.ID); err != nil {
+			return gm, err
+		}
+	}
+}
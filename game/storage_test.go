@@ -1,10 +1,15 @@
 package game_test
 
 import (
-	"errors"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cjsaylor/chessbot/game"
+	bolt "go.etcd.io/bbolt"
 )
 
 type dbTest struct {
@@ -13,12 +18,44 @@ type dbTest struct {
 }
 
 func dbTestTable() ([]dbTest, error) {
-	/*sqlite, err := game.NewSqliteStore("../chessbot.db")
+	dir, err := ioutil.TempDir("", "chessbot")
 	if err != nil {
-		return []dbTest{}, nil
+		return []dbTest{}, err
 	}
-	memory := game.NewMemoryStore()*/
-	return []dbTest{}, errors.New("err")
+	sqlite, err := game.NewSqliteStore(filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		return []dbTest{}, err
+	}
+	memory := game.NewMemoryStore()
+	boltDB, err := bolt.Open(filepath.Join(dir, "chessbot.bolt"), 0600, nil)
+	if err != nil {
+		return []dbTest{}, err
+	}
+	boltStore, err := game.NewBoltStore(boltDB)
+	if err != nil {
+		return []dbTest{}, err
+	}
+	tests := []dbTest{
+		{name: "sqlite", db: sqlite},
+		{name: "memory", db: memory},
+		{name: "bolt", db: boltStore},
+	}
+	// Postgres is only tested when a disposable database is provided
+	if url := os.Getenv("TEST_POSTGRESURL"); url != "" {
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			return []dbTest{}, err
+		}
+		postgres, err := game.NewPostgresStore(db)
+		if err != nil {
+			return []dbTest{}, err
+		}
+		if _, err := db.Exec("truncate games, challenges, game_events"); err != nil {
+			return []dbTest{}, err
+		}
+		tests = append(tests, dbTest{name: "postgres", db: postgres})
+	}
+	return tests, nil
 }
 
 func TestGameSavesAndIsRetrievable(t *testing.T) {
@@ -29,12 +66,16 @@ func TestGameSavesAndIsRetrievable(t *testing.T) {
 	for _, tt := range dbSet {
 		t.Run(tt.name, func(t *testing.T) {
 			gm := game.NewGame("1234", game.Player{ID: "1"}, game.Player{ID: "2"})
+			gm.ChannelID = "C1"
 			if err := tt.db.StoreGame("1234", gm); err != nil {
 				t.Error(err)
 			}
 			gm, err = tt.db.RetrieveGame("1234")
 			if err != nil {
-				t.Error(err)
+				t.Fatal(err)
+			}
+			if gm.ChannelID != "C1" {
+				t.Errorf("expected the channel of the game to be stored, got %q", gm.ChannelID)
 			}
 		})
 	}
@@ -77,3 +118,139 @@ func TestRetrieveGameThatDoesNotExist(t *testing.T) {
 	}
 
 }
+
+func TestStoringStaleGameConflicts(t *testing.T) {
+	dbSet, err := dbTestTable()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, tt := range dbSet {
+		t.Run(tt.name, func(t *testing.T) {
+			gm := game.NewGame("conflict", game.Player{ID: "1"}, game.Player{ID: "2"})
+			if err := tt.db.StoreGame(gm.ID, gm); err != nil {
+				t.Fatal(err)
+			}
+			stale := game.NewGame("conflict", game.Player{ID: "1"}, game.Player{ID: "2"})
+			if err := tt.db.StoreGame(stale.ID, stale); !game.IsConflict(err) {
+				t.Errorf("expected a conflict, got %v", err)
+			}
+			gm.Move("e2e4")
+			if err := tt.db.StoreGame(gm.ID, gm); err != nil {
+				t.Errorf("expected the current game to be stored, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateReappliesChangeAfterConflict(t *testing.T) {
+	dbSet, err := dbTestTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range dbSet {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.db
+			gm := game.NewGame("1234", game.Player{ID: " U1 U2 "}, game.Player{ID: " U3 "})
+			store.StoreGame(gm.ID, gm)
+			first, _ := store.RetrieveGame(gm.ID)
+			second, _ := store.RetrieveGame(gm.ID)
+			white := first.TurnPlayer().Members()
+			if _, err := game.Update(store, first, func(gm *game.Game) error {
+				_, err := gm.MoveAs(white[0], "e2e4")
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+			attempts := 0
+			_, err = game.Update(store, second, func(gm *game.Game) error {
+				attempts++
+				_, err := gm.MoveAs(white[len(white)-1], "d2d4")
+				return err
+			})
+			if err != game.ErrNotPlayersTurn || attempts != 2 {
+				t.Errorf("expected the second move to be retried and rejected, got %v after %v attempts", err, attempts)
+			}
+			stored, _ := store.RetrieveGame(gm.ID)
+			if len(stored.Moves()) != 1 || stored.Moves()[0].String() != "e2e4" {
+				t.Errorf("expected only the first move to be stored, got %v", stored.Moves())
+			}
+		})
+	}
+}
+
+func TestMemoryStoreHandsOutCopies(t *testing.T) {
+	store := game.NewMemoryStore()
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.WorkspaceID = "T1"
+	gm.CreatedBy = "U1"
+	gm.ChannelID = "C1"
+	store.StoreGame(gm.ID, gm)
+	gm.Move("e2e4")
+	retrieved, _ := store.RetrieveGame(gm.ID)
+	if len(retrieved.Moves()) != 0 {
+		t.Errorf("expected changes to be invisible until stored, got %v", retrieved.Moves())
+	}
+	if retrieved.WorkspaceID != "T1" || retrieved.CreatedBy != "U1" || retrieved.ChannelID != "C1" {
+		t.Errorf("expected the copy to keep the game details, got %+v", retrieved)
+	}
+	retrieved.Move("d2d4")
+	listed, _ := store.ListGames("T1")
+	if len(listed) != 1 || len(listed[0].Moves()) != 0 {
+		t.Errorf("expected listed games to be copies as well, got %v", listed)
+	}
+	if err := store.StoreGame(retrieved.ID, retrieved); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreGame(gm.ID, gm); !game.IsConflict(err) {
+		t.Errorf("expected the game changed by someone else to conflict, got %v", err)
+	}
+}
+
+func TestGameHistoryIsStoredAndReplayable(t *testing.T) {
+	dbSet, err := dbTestTable()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, tt := range dbSet {
+		t.Run(tt.name, func(t *testing.T) {
+			gm := game.NewGame("history", game.Player{ID: " U1 U2 "}, game.Player{ID: " U3 "})
+			gm.CreatedBy = "U1"
+			if err := tt.db.StoreGame(gm.ID, gm); err != nil {
+				t.Fatal(err)
+			}
+			gm, _ = tt.db.RetrieveGame(gm.ID)
+			white := gm.TurnPlayer().Members()[0]
+			black := gm.Players[game.Black].Members()[0]
+			gm.MoveAs(white, "e2e4")
+			gm.MoveAs(black, "e7e5")
+			gm.TakebackAs(black)
+			gm.MoveAs(black, "d7d5")
+			gm.ResignAs(white)
+			if err := tt.db.StoreGame(gm.ID, gm); err != nil {
+				t.Fatal(err)
+			}
+			events, err := tt.db.(game.EventStorage).Events(gm.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			types := []game.EventType{}
+			for _, event := range events {
+				types = append(types, event.Type)
+			}
+			expected := []game.EventType{game.EventChallenge, game.EventMove, game.EventMove, game.EventTakeback, game.EventMove, game.EventResign}
+			if fmt.Sprint(types) != fmt.Sprint(expected) {
+				t.Fatalf("expected events %v, got %v", expected, types)
+			}
+			if events[0].ActorID != "U1" || events[2].ActorID != black || events[3].ActorID != black || events[3].Move != "e7e5" {
+				t.Errorf("expected the actors and moves to be recorded, got %+v", events)
+			}
+			replayed, err := game.Replay(events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replayed.PGN() != gm.PGN() || replayed.Players[game.White].ID != gm.Players[game.White].ID {
+				t.Errorf("expected the replayed game to match, got %v instead of %v", replayed.PGN(), gm.PGN())
+			}
+		})
+	}
+}
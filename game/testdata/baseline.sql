@@ -0,0 +1,17 @@
+-- Schema of the first released sqlite store, before games had workspaces or versions
+CREATE TABLE games (
+	id text PRIMARY KEY,
+	player_white_id text,
+	player_black_id text,
+	last_moved datetime,
+	pgn text
+);
+CREATE TABLE challenges (
+	challenger_id text NOT NULL,
+	challenged_id text NOT NULL,
+	channel_id text NOT NULL,
+	game_id text NOT NULL UNIQUE,
+	PRIMARY KEY (challenger_id, challenged_id)
+);
+INSERT INTO games VALUES ('old', 'U1', 'U2', '2019-04-01 12:00:00+00:00', '1. d2d4 d7d5 *');
+INSERT INTO challenges VALUES ('U1', 'U2', 'C1', 'old');
@@ -0,0 +1,32 @@
+-- Schema of the sqlite store right before migrations were versioned, it has every table but no schema_version
+CREATE TABLE games (
+	id text PRIMARY KEY,
+	player_white_id text,
+	player_black_id text,
+	last_moved datetime,
+	pgn text,
+	workspace_id text,
+	version integer NOT NULL DEFAULT 0
+);
+CREATE TABLE challenges (
+	challenger_id text NOT NULL,
+	challenged_id text NOT NULL,
+	channel_id text NOT NULL,
+	game_id text NOT NULL UNIQUE,
+	PRIMARY KEY (challenger_id, challenged_id)
+);
+CREATE TABLE game_events (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	game_id text NOT NULL,
+	type text NOT NULL,
+	actor_id text NOT NULL,
+	move text NOT NULL,
+	white_id text NOT NULL,
+	black_id text NOT NULL,
+	workspace_id text NOT NULL,
+	fen text NOT NULL,
+	created_at timestamp NOT NULL
+);
+CREATE INDEX game_events_game_id ON game_events (game_id, id);
+INSERT INTO games VALUES ('old', 'U1', 'U2', '2019-04-01 12:00:00+00:00', '1. d2d4 d7d5 *', 'T1', 2);
+INSERT INTO challenges VALUES ('U1', 'U2', 'C1', 'old');
@@ -5,15 +5,16 @@ go 1.12
 require (
 	github.com/aws/aws-sdk-go v1.30.11
 	github.com/caarlos0/env v3.3.0+incompatible
-	github.com/cjsaylor/chessimage v0.0.0-20190107020940-8abad33612f4
 	github.com/flopp/go-findfont v0.0.0-20180308170802-e788239e52bc // indirect
-	github.com/fogleman/gg v1.1.0 // indirect
+	github.com/fogleman/gg v1.1.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/gorilla/websocket v1.4.0 // indirect
+	github.com/gorilla/websocket v1.4.0
+	github.com/lib/pq v1.3.0
 	github.com/lusis/go-slackbot v0.0.0-20180109053408-401027ccfef5 // indirect
 	github.com/lusis/slack-test v0.0.0-20180109053238-3c758769bfa6 // indirect
 	github.com/mattn/go-sqlite3 v1.9.0
 	github.com/nlopes/slack v0.5.0
 	github.com/notnil/chess v0.0.0-20181214160432-429595102215
-	golang.org/x/image v0.0.0-20180708004352-c73c2afc3b81 // indirect
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/image v0.0.0-20180708004352-c73c2afc3b81
 )
@@ -66,6 +66,10 @@ func (s SlackActionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		s.SlackClient = slack.New(botToken)
 	}
+	if event.CallbackID == browseCallbackID {
+		s.handleBrowseAction(w, event)
+		return
+	}
 	if event.Type != "interactive_message" && event.CallbackID != "challenge_response" {
 		s.sendResponse(w, event.OriginalMessage, "Invalid action.")
 		return
@@ -0,0 +1,71 @@
+package integration_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/nlopes/slack"
+)
+
+func TestSlackBrowseButtonsShowEarlierPosition(t *testing.T) {
+	store := game.NewMemoryStore()
+	gm := game.NewGame("1234.5678", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	for _, move := range []string{"e2e4", "e7e5", "g1f3"} {
+		gm.Move(move)
+	}
+	store.StoreGame(gm.ID, gm)
+	linkRenderer := rendering.NewRenderLink("http://localhost", "secret")
+	current, _ := linkRenderer.CreateGameLink(gm, 3, rendering.LinkOptions{Inverted: true})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type":        "interactive_message",
+		"callback_id": "browse_board",
+		"team":        map[string]string{"id": "T1"},
+		"actions":     []map[string]string{{"name": "ply", "type": "button", "value": "1234.5678 1"}},
+		"original_message": map[string]interface{}{
+			"text":        "Black to move",
+			"attachments": []map[string]string{{"callback_id": "browse_board", "image_url": current.String()}},
+		},
+	})
+	body := "payload=" + url.QueryEscape(string(payload))
+	timestamp := fmt.Sprint(time.Now().Unix())
+	mac := hmac.New(sha256.New, []byte("slack-secret"))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	req := httptest.NewRequest(http.MethodPost, "/slack/action", bytes.NewBufferString(body))
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	integration.SlackActionHandler{
+		SigningKey:   "slack-secret",
+		SlackClient:  slack.New("token"),
+		GameStorage:  store,
+		LinkRenderer: linkRenderer,
+	}.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", rec.Code)
+	}
+	var updated slack.Message
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	attachment := updated.Attachments[0]
+	link, _ := url.Parse(attachment.ImageURL)
+	if link.Query().Get("ply") != "1" || link.Query().Get("inverted") != "true" || !linkRenderer.ValidateLink(*link) {
+		t.Errorf("expected a valid link to the first move keeping the orientation, got %v", link)
+	}
+	if !strings.HasPrefix(attachment.Text, "e2e4") || len(attachment.Actions) != 4 {
+		t.Errorf("expected the first move with all browse buttons, got %q and %v buttons", attachment.Text, len(attachment.Actions))
+	}
+}
@@ -0,0 +1,33 @@
+package integration
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+var annotationSquarePattern = regexp.MustCompile("^([a-h][1-8])([a-h][1-8])?$")
+
+// showAnnotations reads the arguments of a show command such as "e2e4 d7d5 arrows" or "red e4 d5".
+// Moves are drawn as arrows and single squares are highlighted, a color name applies to the annotations following it.
+func showAnnotations(args string) rendering.Annotations {
+	annotations := rendering.Annotations{}
+	color := rendering.DefaultAnnotationColor
+	for _, field := range strings.Fields(strings.ToLower(args)) {
+		if rendering.IsAnnotationColor(field) {
+			color = field
+			continue
+		}
+		squares := annotationSquarePattern.FindStringSubmatch(field)
+		if squares == nil {
+			continue
+		}
+		if squares[2] == "" {
+			annotations.Marks = append(annotations.Marks, rendering.Mark{Square: squares[1], Color: color})
+		} else {
+			annotations.Arrows = append(annotations.Arrows, rendering.Arrow{From: squares[1], To: squares[2], Color: color})
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/nlopes/slack"
+)
+
+// BoardFormat is how a workspace wants boards posted
+type BoardFormat string
+
+// BoardImage posts boards as rendered images, which requires the public HOSTNAME to be reachable by the chat client.
+// BoardText posts boards as monospace Unicode text.
+const (
+	BoardImage BoardFormat = "image"
+	BoardText  BoardFormat = "text"
+)
+
+// textBoardBlock wraps the text board of the game after the given number of plies in a code block
+func textBoardBlock(gm *game.Game, ply int, inverted bool) string {
+	return "```\n" + rendering.RenderText(gm, ply, inverted) + "```"
+}
+
+// Browse button views of text boards, image boards keep their orientation in the image link
+const (
+	textView         = "text"
+	textViewInverted = "text-inverted"
+)
+
+// boardFormat is the board format chosen by the workspace, images unless it asked for text
+func (s SlackHandler) boardFormat(workspaceID string) BoardFormat {
+	if s.PreferenceStorage == nil {
+		return BoardImage
+	}
+	if format, err := s.PreferenceStorage.GetBoardFormat(workspaceID); err == nil && format == BoardText {
+		return BoardText
+	}
+	return BoardImage
+}
+
+// withBoard shows the current position of the game in the attachment, as an image or as a text board when the
+// workspace chose text. Browsable boards get the buttons to step through the earlier positions.
+func (s SlackHandler) withBoard(attachment slack.Attachment, gm *game.Game, options rendering.LinkOptions, browsable bool) slack.Attachment {
+	ply := len(gm.Moves())
+	attachment.Fallback = rendering.AltText(gm, ply)
+	view := ""
+	if s.boardFormat(gm.WorkspaceID) == BoardText {
+		view = textView
+		if options.Inverted {
+			view = textViewInverted
+		}
+		attachment.Text = strings.TrimSpace(attachment.Text + "\n" + textBoardBlock(gm, ply, options.Inverted))
+		attachment.MarkdownIn = []string{"text"}
+	} else if link, err := s.LinkRenderer.CreateGameLink(gm, ply, options); err != nil {
+		log.Println(err)
+	} else {
+		attachment.ImageURL = link.String()
+	}
+	if browsable {
+		attachment = withBrowseActions(attachment, gm, view)
+	}
+	return attachment
+}
+
+// handleBoardFormatCommand stores whether boards are posted as images or as text in the workspace
+func (s SlackHandler) handleBoardFormatCommand(teamID string, format BoardFormat, channel string, threadTS string) {
+	if s.PreferenceStorage == nil {
+		return
+	}
+	text := fmt.Sprintf("Boards will now be posted as %v in this workspace.", format)
+	if err := s.PreferenceStorage.StoreBoardFormat(teamID, format); err != nil {
+		log.Println(err)
+		text = "Sorry, I couldn't save the board format."
+	}
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	s.SlackClient.PostMessage(channel, options...)
+}
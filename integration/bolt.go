@@ -0,0 +1,220 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cjsaylor/chessbot/rendering"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	authorizationsBucket = []byte("authorizations")
+	orientationsBucket   = []byte("orientations")
+	stylesBucket         = []byte("styles")
+	boardFormatsBucket   = []byte("board_formats")
+)
+
+// BoltStore implements the Auth and Preference storage interfaces and persists in an embedded bolt database.
+// Unlike the sqlite store it does not need cgo.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore creates (if not exists) the buckets of oauth tokens and preferences in the database.
+// The database can be shared with the bolt stores of other packages.
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{authorizationsBucket, orientationsBucket, stylesBucket, boardFormatsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) put(bucket []byte, key string, value string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), []byte(value))
+	})
+}
+
+// get returns the value of the key, or false when there is none
+func (b *BoltStore) get(bucket []byte, key string) (string, bool, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if stored := tx.Bucket(bucket).Get([]byte(key)); stored != nil {
+			value = append([]byte{}, stored...)
+		}
+		return nil
+	})
+	return string(value), value != nil, err
+}
+
+// StoreAuthToken stores the oauth token granted by slack user for a given team ID
+func (b *BoltStore) StoreAuthToken(teamID string, oauthToken string) error {
+	return b.put(authorizationsBucket, teamID, oauthToken)
+}
+
+// GetAuthToken retrieves an oauth token for use with slack given a team ID
+func (b *BoltStore) GetAuthToken(teamID string) (string, error) {
+	token, ok, err := b.get(authorizationsBucket, teamID)
+	if err == nil && !ok {
+		err = fmt.Errorf("Auth token not found for team %v", teamID)
+	}
+	return token, err
+}
+
+// StoreOrientation stores the preferred board orientation of a user
+func (b *BoltStore) StoreOrientation(userID string, orientation Orientation) error {
+	return b.put(orientationsBucket, userID, string(orientation))
+}
+
+// GetOrientation retrieves the preferred board orientation of a user
+func (b *BoltStore) GetOrientation(userID string) (Orientation, error) {
+	orientation, ok, err := b.get(orientationsBucket, userID)
+	if err == nil && !ok {
+		err = fmt.Errorf("No orientation preference for user %v", userID)
+	}
+	return Orientation(orientation), err
+}
+
+// StoreStyle stores the board style chosen by a user or workspace
+func (b *BoltStore) StoreStyle(ID string, style rendering.Style) error {
+	value, err := json.Marshal(style)
+	if err != nil {
+		return err
+	}
+	return b.put(stylesBucket, ID, string(value))
+}
+
+// GetStyle retrieves the board style chosen by a user or workspace
+func (b *BoltStore) GetStyle(ID string) (rendering.Style, error) {
+	style := rendering.Style{}
+	value, ok, err := b.get(stylesBucket, ID)
+	if err == nil && !ok {
+		err = fmt.Errorf("No style chosen by %v", ID)
+	}
+	if ok {
+		err = json.Unmarshal([]byte(value), &style)
+	}
+	return style, err
+}
+
+// StoreBoardFormat stores how boards are posted in a workspace
+func (b *BoltStore) StoreBoardFormat(workspaceID string, format BoardFormat) error {
+	return b.put(boardFormatsBucket, workspaceID, string(format))
+}
+
+// GetBoardFormat retrieves how boards are posted in a workspace
+func (b *BoltStore) GetBoardFormat(workspaceID string) (BoardFormat, error) {
+	format, ok, err := b.get(boardFormatsBucket, workspaceID)
+	if err == nil && !ok {
+		err = fmt.Errorf("No board format chosen for workspace %v", workspaceID)
+	}
+	return BoardFormat(format), err
+}
+
+// dump returns every oauth token and preference of the store
+func (b *BoltStore) dump() (storedPreferences, error) {
+	preferences := newStoredPreferences()
+	err := b.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(authorizationsBucket).ForEach(func(key []byte, value []byte) error {
+			preferences.tokens[string(key)] = string(value)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(orientationsBucket).ForEach(func(key []byte, value []byte) error {
+			preferences.orientations[string(key)] = Orientation(value)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(stylesBucket).ForEach(func(key []byte, value []byte) error {
+			style := rendering.Style{}
+			if err := json.Unmarshal(value, &style); err != nil {
+				return err
+			}
+			preferences.styles[string(key)] = style
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boardFormatsBucket).ForEach(func(key []byte, value []byte) error {
+			preferences.boardFormats[string(key)] = BoardFormat(value)
+			return nil
+		})
+	})
+	return preferences, err
+}
+
+// ImportSqlite copies every oauth token and preference of the sqlite store into the bolt store
+func (b *BoltStore) ImportSqlite(store *SqliteStore) error {
+	preferences, err := store.dump()
+	if err != nil {
+		return err
+	}
+	return preferences.copyTo(b)
+}
+
+// ExportSqlite copies every oauth token and preference of the bolt store into the sqlite store
+func (b *BoltStore) ExportSqlite(store *SqliteStore) error {
+	preferences, err := b.dump()
+	if err != nil {
+		return err
+	}
+	return preferences.copyTo(store)
+}
+
+// storedPreferences is everything kept by an auth and preference store, as copied between the bolt and sqlite stores
+type storedPreferences struct {
+	tokens       map[string]string
+	orientations map[string]Orientation
+	styles       map[string]rendering.Style
+	boardFormats map[string]BoardFormat
+}
+
+func newStoredPreferences() storedPreferences {
+	return storedPreferences{
+		tokens:       make(map[string]string),
+		orientations: make(map[string]Orientation),
+		styles:       make(map[string]rendering.Style),
+		boardFormats: make(map[string]BoardFormat),
+	}
+}
+
+func (p storedPreferences) copyTo(store interface {
+	AuthStorage
+	PreferenceStorage
+}) error {
+	for teamID, token := range p.tokens {
+		if err := store.StoreAuthToken(teamID, token); err != nil {
+			return err
+		}
+	}
+	for userID, orientation := range p.orientations {
+		if err := store.StoreOrientation(userID, orientation); err != nil {
+			return err
+		}
+	}
+	for ID, style := range p.styles {
+		if err := store.StoreStyle(ID, style); err != nil {
+			return err
+		}
+	}
+	for workspaceID, format := range p.boardFormats {
+		if err := store.StoreBoardFormat(workspaceID, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
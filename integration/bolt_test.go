@@ -0,0 +1,70 @@
+package integration_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/rendering"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStoreImportsAndExportsSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := integration.NewSqliteStore(filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	style := rendering.Style{Theme: "dark", Pieces: "contrast"}
+	sqlite.StoreAuthToken("T1", "xoxb-token")
+	sqlite.StoreOrientation("U1", integration.OrientationBlack)
+	sqlite.StoreStyle("U1", style)
+	sqlite.StoreBoardFormat("T1", integration.BoardText)
+
+	db, err := bolt.Open(filepath.Join(dir, "chessbot.bolt"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, err := integration.NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetAuthToken("T1"); err == nil {
+		t.Error("expected an error for a missing token")
+	}
+	if err := store.ImportSqlite(sqlite); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := store.GetAuthToken("T1"); err != nil || token != "xoxb-token" {
+		t.Errorf("expected the token to be imported, got %v (%v)", token, err)
+	}
+	if orientation, _ := store.GetOrientation("U1"); orientation != integration.OrientationBlack {
+		t.Errorf("expected the orientation to be imported, got %v", orientation)
+	}
+	if imported, _ := store.GetStyle("U1"); imported != style {
+		t.Errorf("expected the style to be imported, got %v", imported)
+	}
+
+	store.StoreBoardFormat("T2", integration.BoardImage)
+	exported, err := integration.NewSqliteStore(filepath.Join(dir, "exported.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ExportSqlite(exported); err != nil {
+		t.Fatal(err)
+	}
+	if format, err := exported.GetBoardFormat("T1"); err != nil || format != integration.BoardText {
+		t.Errorf("expected the board format to be exported, got %v (%v)", format, err)
+	}
+	if format, err := exported.GetBoardFormat("T2"); err != nil || format != integration.BoardImage {
+		t.Errorf("expected the board format to be exported, got %v (%v)", format, err)
+	}
+	if exportedStyle, _ := exported.GetStyle("U1"); exportedStyle != style {
+		t.Errorf("expected the style to be exported, got %v", exportedStyle)
+	}
+}
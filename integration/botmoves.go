@@ -0,0 +1,50 @@
+package integration
+
+import (
+	"log"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/nlopes/slack"
+)
+
+// SlackBotMoves posts the board to the thread of a Slack game after every move of a bot account.
+// Bots play through the bot API instead of mentioning the chessbot, so nothing else tells the thread they moved.
+// It must be registered as an observer of the game storage.
+type SlackBotMoves struct {
+	handler SlackHandler
+}
+
+// NewSlackBotMoves returns an observer posting the moves of the bots of the handler's bot storage
+func NewSlackBotMoves(handler SlackHandler) *SlackBotMoves {
+	return &SlackBotMoves{handler: handler}
+}
+
+// GameStored posts the board when the stored events contain a move of a registered bot
+func (b *SlackBotMoves) GameStored(gm *game.Game) {
+	if gm.ChannelID == "" || b.handler.BotStorage == nil {
+		return
+	}
+	var botMove *game.Event
+	for _, event := range gm.StoredEvents() {
+		if event.Type != game.EventMove {
+			continue
+		}
+		if _, err := b.handler.BotStorage.RetrieveBot(gm.WorkspaceID, event.ActorID); err == nil {
+			event := event
+			botMove = &event
+		}
+	}
+	if botMove == nil || gm.LastMove() == nil || gm.LastMove().String() != botMove.Move {
+		return
+	}
+	handler := b.handler
+	if handler.SlackClient == nil {
+		botToken, err := handler.AuthStorage.GetAuthToken(gm.WorkspaceID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		handler.SlackClient = slack.New(botToken)
+	}
+	handler.postMove(gm, gm.LastMove(), "", gm.ChannelID, gm.ID)
+}
@@ -0,0 +1,71 @@
+package integration_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/api"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/nlopes/slack"
+)
+
+func TestSlackPostsBotMoves(t *testing.T) {
+	posts := []url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posts = append(posts, r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+	defaultURL := slack.APIURL
+	slack.APIURL = server.URL + "/"
+	defer func() { slack.APIURL = defaultURL }()
+
+	bots := api.NewMemoryStore()
+	if _, err := api.RegisterBot(bots, api.BotAccount{ID: "stockfish", WorkspaceID: "T1", OwnerID: "U2"}); err != nil {
+		t.Fatal(err)
+	}
+	store := game.NewObservedStore(game.NewMemoryStore())
+	store.Observe(integration.NewSlackBotMoves(integration.SlackHandler{
+		SlackClient:  slack.New("token"),
+		GameStorage:  store,
+		BotStorage:   bots,
+		LinkRenderer: rendering.NewRenderLink("http://localhost", "secret"),
+	}))
+	// players are not shuffled, so the member plays white
+	gm, err := game.NewGameFromPGN("1234.5678", "*", game.Player{ID: " U1 "}, game.Player{ID: " stockfish "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.WorkspaceID = "T1"
+	gm.ChannelID = "C1"
+	if err := store.StoreGame(gm.ID, gm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gm.MoveAs("U1", "e2e4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreGame(gm.ID, gm); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 0 {
+		t.Fatalf("expected moves of members to be posted by the command handler only, got %v posts", len(posts))
+	}
+	if _, err := gm.MoveAs("stockfish", "e7e5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreGame(gm.ID, gm); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("expected the bot move to be posted, got %v posts", len(posts))
+	}
+	if posts[0].Get("channel") != "C1" || posts[0].Get("thread_ts") != "1234.5678" {
+		t.Errorf("expected the board in the thread of the game, got %v", posts[0])
+	}
+}
@@ -0,0 +1,133 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+)
+
+// browseCallbackID identifies board attachments with buttons to browse the earlier positions of a game
+const browseCallbackID = "browse_board"
+
+// browseActions are the buttons moving a board attachment to the first, previous, next and last position of the
+// game. Buttons which would not change the position are left out. The view is carried along for text boards,
+// which have no image link to take the orientation from.
+func browseActions(gm *game.Game, ply int, view string) []slack.AttachmentAction {
+	last := len(gm.Moves())
+	actions := []slack.AttachmentAction{}
+	for _, button := range []struct {
+		text   string
+		target int
+	}{
+		{"⏮", 0},
+		{"◀", ply - 1},
+		{"▶", ply + 1},
+		{"⏭", last},
+	} {
+		if button.target < 0 || button.target > last || button.target == ply {
+			continue
+		}
+		value := fmt.Sprintf("%v %v", gm.ID, button.target)
+		if view != "" {
+			value += " " + view
+		}
+		actions = append(actions, slack.AttachmentAction{
+			Name:  "ply",
+			Text:  button.text,
+			Type:  "button",
+			Value: value,
+		})
+	}
+	return actions
+}
+
+// browseText describes the position shown after the given number of plies
+func browseText(gm *game.Game, ply int) string {
+	if ply == 0 {
+		return "Starting position"
+	}
+	moves := gm.Moves()
+	return fmt.Sprintf("%v (%v/%v)", moves[ply-1], ply, len(moves))
+}
+
+// withBrowseActions adds the browse buttons to a board attachment showing the current position of the game
+func withBrowseActions(attachment slack.Attachment, gm *game.Game, view string) slack.Attachment {
+	attachment.CallbackID = browseCallbackID
+	attachment.Actions = browseActions(gm, len(gm.Moves()), view)
+	return attachment
+}
+
+// handleBrowseAction replaces the board of the original message with the position picked by the button,
+// keeping the orientation and style of the original board
+func (s SlackActionHandler) handleBrowseAction(w http.ResponseWriter, event slackevents.MessageAction) {
+	if len(event.Actions) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	fields := strings.Fields(event.Actions[0].Value)
+	if len(fields) < 2 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ply, err := strconv.Atoi(fields[1])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	view := ""
+	if len(fields) > 2 {
+		view = fields[2]
+	}
+	gm, err := s.GameStorage.RetrieveGame(fields[0])
+	if err != nil {
+		s.sendResponse(w, event.OriginalMessage, "This game is no longer available.")
+		return
+	}
+	original := event.OriginalMessage
+	for i, attachment := range original.Attachments {
+		if attachment.CallbackID != browseCallbackID {
+			continue
+		}
+		if ply < 0 || ply > len(gm.Moves()) {
+			s.sendResponse(w, event.OriginalMessage, "That position is not part of this game.")
+			return
+		}
+		original.Attachments[i].Fallback = rendering.AltText(gm, ply)
+		original.Attachments[i].Actions = browseActions(gm, ply, view)
+		if view == textView || view == textViewInverted {
+			original.Attachments[i].Text = browseText(gm, ply) + "\n" + textBoardBlock(gm, ply, view == textViewInverted)
+			original.Attachments[i].MarkdownIn = []string{"text"}
+			continue
+		}
+		options := rendering.LinkOptions{}
+		if imageURL, err := url.Parse(attachment.ImageURL); err == nil {
+			options = rendering.ParseLinkOptions(*imageURL)
+		}
+		// Annotations were drawn for the original position and a fresh expiry is picked for the new link
+		options.Annotations = rendering.Annotations{}
+		options.Expires = time.Time{}
+		link, err := s.LinkRenderer.CreateGameLink(gm, ply, options)
+		if err != nil {
+			s.sendResponse(w, event.OriginalMessage, "That position is not part of this game.")
+			return
+		}
+		original.Attachments[i].ImageURL = link.String()
+		original.Attachments[i].Text = browseText(gm, ply)
+	}
+	original.ReplaceOriginal = true
+	w.Header().Add("Content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(&original); err != nil {
+		log.Println(err)
+	}
+}
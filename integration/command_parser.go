@@ -59,6 +59,23 @@ func (c *CommandMatch) ToChallenge() (*ChallengeCommand, error) {
 	}, nil
 }
 
+// Teams splits the challenge parameters into the challenging and the challenged team.
+// Teams are separated by ":" and each is returned as a space padded list of members (" U1 U2 ").
+func (c *ChallengeCommand) Teams() (string, string) {
+	challenger := " "
+	current := ""
+	for _, param := range c.ChallengeParams {
+		current = current + " "
+		if strings.Contains(param, ":") {
+			challenger = current
+			current = ""
+		} else {
+			current = current + param
+		}
+	}
+	return challenger, current + " "
+}
+
 // ToMove converts this command match to a proper move command
 func (c *CommandMatch) ToMove() (*MoveCommand, error) {
 	if c.Type != Move || len(c.Params) < 1 {
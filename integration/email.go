@@ -0,0 +1,486 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// EmailGateway plays correspondence games by email
+// Incoming messages are read from an IMAP inbox and replies are sent over SMTP.
+// Games are keyed by the Message-ID of the challenge that started the email thread, similar to a Slack thread timestamp.
+// Commands are only applied when the receiving server authenticated the domain of the sender (see AuthServID).
+type EmailGateway struct {
+	Address string
+	// AuthServID identifies the Authentication-Results header added by the server receiving the bot's mail.
+	// The server has to remove headers claiming its ID from incoming messages.
+	AuthServID   string
+	SMTPAddr     string
+	SMTPAuth     smtp.Auth
+	IMAPAddr     string
+	IMAPTLS      bool
+	IMAPUser     string
+	IMAPPassword string
+	GameStorage  game.GameStorage
+	RenderBoard  func(w io.Writer, gm *game.Game) error
+}
+
+var emailCommandPatterns = []CommandPattern{
+	{
+		Type:    Challenge,
+		Pattern: regexp.MustCompile("^new_game (.*)$"),
+	},
+	{
+		Type:    Move,
+		Pattern: regexp.MustCompile("^([a-h][1-8][a-h][1-8][qnrb]?)\\b"),
+	},
+	{
+		Type:    Resign,
+		Pattern: regexp.MustCompile("^resign\\b"),
+	},
+	{
+		Type:    Takeback,
+		Pattern: regexp.MustCompile("^take\\s?back\\b"),
+	},
+	{
+		Type:    Help,
+		Pattern: regexp.MustCompile("^help\\b"),
+	},
+}
+
+var emailCommandParser = NewCommandParser(emailCommandPatterns)
+
+const emailHelpText = `You can use ChessBot to play Chess with other colleagues by email.
+
+To start a new game, send "new_game alice@example.com bob@example.com : carol@example.com", the two teams are separated by ":".
+To make a move, reply to the latest board with the grid position of the piece you wish to move and its destination (e.g. "d2d4") on the first line.
+Reply with "resign" to resign or "takeback" to take back your last move.`
+
+// incomingEmail is the part of a received message relevant to playing a game
+type incomingEmail struct {
+	from string
+	// verified is set when the receiving server authenticated the domain of the From address
+	verified bool
+	// automatic is set for auto-replies and list or bulk mail, which are never answered
+	automatic  bool
+	messageID  string
+	references []string
+	subject    string
+	text       string
+}
+
+// Poll fetches all unseen messages from the inbox and applies the commands found in them
+func (e EmailGateway) Poll() error {
+	client, err := dialIMAP(e.IMAPAddr, e.IMAPTLS)
+	if err != nil {
+		return err
+	}
+	defer client.logout()
+	if err := client.login(e.IMAPUser, e.IMAPPassword); err != nil {
+		return err
+	}
+	if err := client.selectMailbox("INBOX"); err != nil {
+		return err
+	}
+	uids, err := client.unseen()
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		raw, err := client.fetch(uid)
+		if err != nil {
+			return err
+		}
+		if email, err := parseIncomingEmail(raw, e.AuthServID); err != nil {
+			log.Println(err)
+		} else {
+			e.handleEmail(email)
+		}
+		if err := client.markSeen(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseIncomingEmail(raw []byte, authServID string) (*incomingEmail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, err
+	}
+	text, err := plainText(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	email := &incomingEmail{
+		from:      strings.ToLower(from.Address),
+		verified:  verifiedSender(msg.Header, authServID, from.Address),
+		automatic: automaticEmail(msg.Header),
+		messageID: strings.TrimSpace(msg.Header.Get("Message-ID")),
+		subject:   msg.Header.Get("Subject"),
+		text:      text,
+	}
+	email.references = strings.Fields(msg.Header.Get("References"))
+	if inReplyTo := strings.TrimSpace(msg.Header.Get("In-Reply-To")); inReplyTo != "" && len(email.references) == 0 {
+		email.references = []string{inReplyTo}
+	}
+	return email, nil
+}
+
+// automaticEmail reports whether a message was sent by a program (RFC 3834), like a vacation responder or a mailing list.
+// Answering those could make the bot and the program reply to each other forever.
+func automaticEmail(header mail.Header) bool {
+	autoSubmitted := strings.ToLower(strings.TrimSpace(header.Get("Auto-Submitted")))
+	if autoSubmitted != "" && autoSubmitted != "no" {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(header.Get("Precedence"))) {
+	case "bulk", "auto_reply", "list", "junk":
+		return true
+	}
+	return false
+}
+
+var authenticationComment = regexp.MustCompile(`\([^()]*\)`)
+
+// verifiedSender reports whether the server identified by authServID authenticated the domain of the sender:
+// a DMARC pass, or a DKIM or SPF pass aligned with the domain, in the topmost Authentication-Results header it added.
+func verifiedSender(header mail.Header, authServID string, from string) bool {
+	if authServID == "" {
+		return false
+	}
+	domain := strings.ToLower(from[strings.LastIndex(from, "@")+1:])
+	for _, value := range header["Authentication-Results"] {
+		for authenticationComment.MatchString(value) {
+			value = authenticationComment.ReplaceAllString(value, " ")
+		}
+		results := strings.Split(value, ";")
+		if fields := strings.Fields(results[0]); len(fields) == 0 || !strings.EqualFold(fields[0], authServID) {
+			continue
+		}
+		for _, result := range results[1:] {
+			fields := strings.Fields(strings.ToLower(result))
+			if len(fields) == 0 {
+				continue
+			}
+			properties := map[string]string{}
+			for _, field := range fields[1:] {
+				if parts := strings.SplitN(field, "=", 2); len(parts) == 2 {
+					properties[parts[0]] = strings.Trim(parts[1], "\"")
+				}
+			}
+			mailFrom := properties["smtp.mailfrom"]
+			switch fields[0] {
+			case "dmarc=pass":
+				if properties["header.from"] == domain {
+					return true
+				}
+			case "dkim=pass":
+				if properties["header.d"] == domain {
+					return true
+				}
+			case "spf=pass":
+				if mailFrom[strings.LastIndex(mailFrom, "@")+1:] == domain {
+					return true
+				}
+			}
+		}
+		// only the topmost header is added by the server, the ones below came with the message
+		return false
+	}
+	return false
+}
+
+// plainText extracts the first text/plain part of a message body
+func plainText(header textproto.MIMEHeader, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			if text, err := plainText(part.Header, part); err != nil || text != "" {
+				return text, err
+			}
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	text, err := ioutil.ReadAll(body)
+	return string(text), err
+}
+
+// command finds the first line of the reply that is a known command, ignoring quoted text and signatures
+func (i *incomingEmail) command() CommandMatch {
+	scanner := bufio.NewScanner(strings.NewReader(i.text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "--" {
+			break
+		}
+		if line == "" || strings.HasPrefix(line, ">") {
+			continue
+		}
+		if matched := emailCommandParser.ParseInput(strings.ToLower(line)); matched.Type != Unknown {
+			return matched
+		}
+	}
+	return emailCommandParser.ParseInput("")
+}
+
+// threadRoot is the Message-ID of the first message of the thread
+func (i *incomingEmail) threadRoot() string {
+	if len(i.references) > 0 {
+		return i.references[0]
+	}
+	return i.messageID
+}
+
+// handleEmail applies the command found in a received message
+func (e EmailGateway) handleEmail(email *incomingEmail) {
+	if email.from == strings.ToLower(e.Address) {
+		return
+	}
+	if email.automatic {
+		log.Printf("Ignoring %v, it was sent automatically\n", email.messageID)
+		return
+	}
+	if !email.verified {
+		// replying would send mail to an address that may be forged
+		log.Printf("Ignoring %v, the sender %v could not be verified\n", email.messageID, email.from)
+		return
+	}
+	gameID := "email:" + email.threadRoot()
+	matched := email.command()
+	switch matched.Type {
+	case Challenge:
+		challengeCommand, _ := matched.ToChallenge()
+		e.handleChallengeCommand(gameID, email, challengeCommand)
+	case Move:
+		moveCommand, _ := matched.ToMove()
+		e.handleMoveCommand(gameID, email, moveCommand)
+	case Resign:
+		e.handleResignCommand(gameID, email)
+	case Takeback:
+		e.handleTakebackCommand(gameID, email)
+	default:
+		e.reply(email, []string{email.from}, emailHelpText, nil)
+	}
+}
+
+func (e EmailGateway) handleChallengeCommand(gameID string, email *incomingEmail, command *ChallengeCommand) {
+	if _, err := e.GameStorage.RetrieveGame(gameID); err == nil {
+		e.reply(email, []string{email.from}, "A game already exists in this thread. Try sending a new email.", nil)
+		return
+	}
+	challengerID, challengedID := command.Teams()
+	if strings.TrimSpace(challengerID) == "" || strings.TrimSpace(challengedID) == "" {
+		e.reply(email, []string{email.from}, emailHelpText, nil)
+		return
+	}
+	invalid := []string{}
+	for _, member := range append(strings.Fields(challengerID), strings.Fields(challengedID)...) {
+		if address, err := mail.ParseAddress(member); err != nil || address.Address != member {
+			invalid = append(invalid, member)
+		}
+	}
+	if len(invalid) > 0 {
+		e.reply(email, []string{email.from}, fmt.Sprintf("%v is not an email address, players are invited by their address (e.g. bob@example.com).", strings.Join(invalid, ", ")), nil)
+		return
+	}
+	gm := game.NewGame(gameID, game.Player{
+		ID: strings.ToLower(challengerID),
+	}, game.Player{
+		ID: strings.ToLower(challengedID),
+	})
+	gm.CreatedBy = email.from
+	gm.Start()
+	if err := e.GameStorage.StoreGame(gameID, gm); err != nil {
+		e.reply(email, []string{email.from}, err.Error(), nil)
+		return
+	}
+	e.sendBoard(email, gm, fmt.Sprintf("Game %v vs. %v started, here is the opening.", emailMentions(gm.Players[game.White]), emailMentions(gm.Players[game.Black])))
+}
+
+func (e EmailGateway) handleMoveCommand(gameID string, email *incomingEmail, moveCommand *MoveCommand) {
+	gm, err := e.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		e.reply(email, []string{email.from}, "I couldn't find a game in this thread.\n\n"+emailHelpText, nil)
+		return
+	}
+	var chessMove *chess.Move
+	gm, err = game.Update(e.GameStorage, gm, func(gm *game.Game) error {
+		var err error
+		chessMove, err = gm.MoveAs(email.from, moveCommand.LAN)
+		return err
+	})
+	if err != nil {
+		e.reply(email, []string{email.from}, err.Error(), nil)
+		return
+	}
+	if outcome := gm.Outcome(); outcome != chess.NoOutcome {
+		e.displayEndGame(email, gm)
+		return
+	}
+	e.sendBoard(email, gm, fmt.Sprintf("%v played %v.", email.from, chessMove))
+}
+
+func (e EmailGateway) handleResignCommand(gameID string, email *incomingEmail) {
+	gm, err := e.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := gm.PlayerByID(email.from); err != nil {
+		e.reply(email, []string{email.from}, "I couldn't find you as part of this game.", nil)
+		return
+	}
+	gm, err = game.Update(e.GameStorage, gm, func(gm *game.Game) error {
+		return gm.ResignAs(email.from)
+	})
+	if err != nil {
+		e.reply(email, []string{email.from}, err.Error(), nil)
+		return
+	}
+	e.displayEndGame(email, gm)
+}
+
+func (e EmailGateway) handleTakebackCommand(gameID string, email *incomingEmail) {
+	gm, err := e.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := gm.PlayerByID(email.from); err != nil {
+		e.reply(email, []string{email.from}, "I couldn't find you as part of this game.", nil)
+		return
+	}
+	gm, err = game.Update(e.GameStorage, gm, func(gm *game.Game) error {
+		if _, err := gm.TakebackAs(email.from); err != nil {
+			return fmt.Errorf("Take back request failed: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		e.reply(email, []string{email.from}, err.Error(), nil)
+		return
+	}
+	e.sendBoard(email, gm, fmt.Sprintf("%v requested a take back.", email.from))
+}
+
+func (e EmailGateway) displayEndGame(email *incomingEmail, gm *game.Game) {
+	result := strings.Replace(strings.Replace(gm.ResultText(), "<@", "", 1), ">", "", 1)
+	e.sendBoard(email, gm, result+"\n\n"+gm.Export())
+}
+
+func emailMentions(player game.Player) string {
+	return strings.Join(player.Members(), ", ")
+}
+
+func emailRecipients(gm *game.Game) []string {
+	return append(gm.Players[game.White].Members(), gm.Players[game.Black].Members()...)
+}
+
+// sendBoard sends every player the board image along with the legal moves of the player to move
+func (e EmailGateway) sendBoard(email *incomingEmail, gm *game.Game, text string) {
+	if gm.Outcome() == chess.NoOutcome {
+		moves := []string{}
+		for _, move := range gm.ValidMoves() {
+			moves = append(moves, move.String())
+		}
+		text += fmt.Sprintf("\n\n%v to move (%v).\nLegal moves: %v\n\nReply to this email with your move on the first line (e.g. \"%v\").",
+			gm.Turn(), emailMentions(gm.TurnPlayer()), strings.Join(moves, " "), moves[0])
+	}
+	image := new(bytes.Buffer)
+	if err := e.RenderBoard(image, gm); err != nil {
+		log.Println(err)
+		image = nil
+	}
+	var attachment []byte
+	if image != nil {
+		attachment = image.Bytes()
+	}
+	e.reply(email, emailRecipients(gm), text, attachment)
+}
+
+// reply sends a message in the thread of the given email, with an optional PNG board attachment
+func (e EmailGateway) reply(email *incomingEmail, to []string, text string, board []byte) {
+	references := append(append([]string{}, email.references...), email.messageID)
+	subject := email.subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	fmt.Fprintf(body, "From: %v\r\n", e.Address)
+	fmt.Fprintf(body, "To: %v\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(body, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(body, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(body, "Message-ID: <%v.%v>\r\n", time.Now().UnixNano(), e.Address)
+	fmt.Fprintf(body, "In-Reply-To: %v\r\n", email.messageID)
+	fmt.Fprintf(body, "References: %v\r\n", strings.Join(references, " "))
+	// marks the reply as automatic, so vacation responders do not answer it
+	fmt.Fprintf(body, "Auto-Submitted: auto-replied\r\n")
+	fmt.Fprintf(body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(body, "Content-Type: multipart/mixed; boundary=%v\r\n\r\n", writer.Boundary())
+
+	textPart, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	qp := quotedprintable.NewWriter(textPart)
+	qp.Write([]byte(text))
+	qp.Close()
+	if board != nil {
+		imagePart, _ := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {"inline; filename=\"board.png\""},
+		})
+		encoded := base64.StdEncoding.EncodeToString(board)
+		for len(encoded) > 76 {
+			imagePart.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		imagePart.Write([]byte(encoded + "\r\n"))
+	}
+	writer.Close()
+	if err := smtp.SendMail(e.SMTPAddr, e.SMTPAuth, e.Address, to, body.Bytes()); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,293 @@
+package integration_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+)
+
+type sentMail struct {
+	recipients []string
+	data       string
+}
+
+// fakeSMTPServer accepts every message and records it
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	sent     []sentMail
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost")
+	mail := sentMail{}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+		case "RCPT":
+			mail.recipients = append(mail.recipients, strings.Trim(line[strings.Index(line, ":")+1:], "<> "))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, _ := text.ReadDotBytes()
+			mail.data = string(data)
+			f.mu.Lock()
+			f.sent = append(f.sent, mail)
+			f.mu.Unlock()
+			mail = sentMail{}
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+// fakeIMAPServer serves a single inbox to every connection
+type fakeIMAPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []string
+	seen     map[int]bool
+}
+
+func newFakeIMAPServer(t *testing.T) *fakeIMAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeIMAPServer{listener: listener, seen: map[int]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeIMAPServer) deliver(message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, strings.ReplaceAll(message, "\n", "\r\n"))
+}
+
+func (f *fakeIMAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	io.WriteString(conn, "* OK IMAP4rev1 ready\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		tag, command := fields[0], strings.ToUpper(strings.Join(fields[1:], " "))
+		f.mu.Lock()
+		switch {
+		case strings.HasPrefix(command, "UID SEARCH UNSEEN"):
+			uids := []string{}
+			for i := range f.messages {
+				if !f.seen[i+1] {
+					uids = append(uids, fmt.Sprint(i+1))
+				}
+			}
+			fmt.Fprintf(conn, "* SEARCH %v\r\n", strings.Join(uids, " "))
+		case strings.HasPrefix(command, "UID FETCH"):
+			var uid int
+			fmt.Sscanf(fields[3], "%d", &uid)
+			message := f.messages[uid-1]
+			fmt.Fprintf(conn, "* %v FETCH (UID %v BODY[] {%v}\r\n%v)\r\n", uid, uid, len(message), message)
+		case strings.HasPrefix(command, "UID STORE"):
+			var uid int
+			fmt.Sscanf(fields[3], "%d", &uid)
+			f.seen[uid] = true
+		case strings.HasPrefix(command, "LOGOUT"):
+			io.WriteString(conn, "* BYE\r\n")
+		}
+		f.mu.Unlock()
+		fmt.Fprintf(conn, "%v OK done\r\n", tag)
+	}
+}
+
+func TestEmailCorrespondenceGame(t *testing.T) {
+	smtpServer := newFakeSMTPServer(t)
+	defer smtpServer.listener.Close()
+	imapServer := newFakeIMAPServer(t)
+	defer imapServer.listener.Close()
+	store := game.NewMemoryStore()
+	gateway := integration.EmailGateway{
+		Address:     "chessbot@example.com",
+		AuthServID:  "mx.example.com",
+		SMTPAddr:    smtpServer.listener.Addr().String(),
+		IMAPAddr:    imapServer.listener.Addr().String(),
+		GameStorage: store,
+		RenderBoard: func(w io.Writer, gm *game.Game) error {
+			_, err := w.Write([]byte("png"))
+			return err
+		},
+	}
+
+	imapServer.deliver(`Authentication-Results: mx.example.com; dkim=pass (good signature) header.d=example.com header.s=mail; spf=pass smtp.mailfrom=alice@example.com
+From: Alice <alice@example.com>
+To: chessbot@example.com
+Subject: Lunch break game
+Message-ID: <root@example.com>
+
+new_game alice@example.com : bob@example.com
+`)
+	if err := gateway.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(smtpServer.sent) != 1 {
+		t.Fatalf("expected the opening board to be sent, got %v emails", len(smtpServer.sent))
+	}
+	opening := smtpServer.sent[0]
+	if len(opening.recipients) != 2 || !strings.Contains(opening.data, "Legal moves:") || !strings.Contains(opening.data, "Content-Type: image/png") {
+		t.Errorf("expected both players to receive the board and legal moves, got %v", opening)
+	}
+	if !strings.Contains(opening.data, "References: <root@example.com>") {
+		t.Errorf("expected the board to be sent in the challenge thread, got %v", opening.data)
+	}
+
+	gm, err := store.RetrieveGame("email:<root@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := gm.Players[game.White].Members()[0]
+	// a forged sender is ignored, even when the message carries its own Authentication-Results header
+	imapServer.deliver(fmt.Sprintf(`Authentication-Results: mx.example.com; spf=fail smtp.mailfrom=mallory@attacker.example
+Authentication-Results: mx.example.com; dmarc=pass header.from=example.com
+From: %v
+To: chessbot@example.com
+Subject: Re: Lunch break game
+Message-ID: <forged@attacker.example>
+References: <root@example.com>
+
+d2d4
+`, white))
+	if err := gateway.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	gm, _ = store.RetrieveGame("email:<root@example.com>")
+	if gm.LastMove() != nil || len(smtpServer.sent) != 1 {
+		t.Fatalf("expected the forged move to be ignored, got %v and %v emails", gm.PGN(), len(smtpServer.sent))
+	}
+
+	imapServer.deliver(fmt.Sprintf(`Authentication-Results: mx.example.com; dmarc=pass header.from=example.com
+From: %v
+To: chessbot@example.com
+Subject: Re: Lunch break game
+Message-ID: <reply@example.com>
+In-Reply-To: <board@example.com>
+References: <root@example.com> <board@example.com>
+
+E2E4
+
+> Legal moves: a2a3 a2a4
+`, white))
+	if err := gateway.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	gm, _ = store.RetrieveGame("email:<root@example.com>")
+	if gm.LastMove() == nil || gm.LastMove().String() != "e2e4" {
+		t.Errorf("expected e2e4 to be played, got %v", gm.PGN())
+	}
+	if len(smtpServer.sent) != 2 {
+		t.Errorf("expected only the new move to be answered, got %v emails", len(smtpServer.sent))
+	}
+}
+
+func TestEmailIgnoresAutomaticReplies(t *testing.T) {
+	smtpServer := newFakeSMTPServer(t)
+	defer smtpServer.listener.Close()
+	imapServer := newFakeIMAPServer(t)
+	defer imapServer.listener.Close()
+	store := game.NewMemoryStore()
+	gateway := integration.EmailGateway{
+		Address:     "chessbot@example.com",
+		AuthServID:  "mx.example.com",
+		SMTPAddr:    smtpServer.listener.Addr().String(),
+		IMAPAddr:    imapServer.listener.Addr().String(),
+		GameStorage: store,
+	}
+
+	for i, header := range []string{"Auto-Submitted: auto-replied", "Precedence: bulk", "Precedence: list"} {
+		imapServer.deliver(fmt.Sprintf(`Authentication-Results: mx.example.com; dmarc=pass header.from=example.com
+From: alice@example.com
+To: chessbot@example.com
+%v
+Subject: Out of office
+Message-ID: <automatic-%v@example.com>
+
+I am on vacation until Monday.
+`, header, i))
+	}
+	if err := gateway.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(smtpServer.sent) != 0 {
+		t.Fatalf("expected automatic messages to be ignored, got %v emails", len(smtpServer.sent))
+	}
+
+	imapServer.deliver(`Authentication-Results: mx.example.com; dmarc=pass header.from=example.com
+From: alice@example.com
+To: chessbot@example.com
+Subject: Lunch break game
+Message-ID: <root@example.com>
+
+new_game alice@example.com : bob
+`)
+	if err := gateway.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(smtpServer.sent) != 1 {
+		t.Fatalf("expected the challenger to be answered, got %v emails", len(smtpServer.sent))
+	}
+	reply := smtpServer.sent[0]
+	if len(reply.recipients) != 1 || reply.recipients[0] != "alice@example.com" || !strings.Contains(reply.data, "bob is not an email address") {
+		t.Errorf("expected the invalid address to be reported to the challenger only, got %v", reply)
+	}
+	if !strings.Contains(reply.data, "Auto-Submitted: auto-replied") {
+		t.Errorf("expected replies to be marked as automatic, got %v", reply.data)
+	}
+	if _, err := store.RetrieveGame("email:<root@example.com>"); err == nil {
+		t.Error("expected no game to be started with an invalid address")
+	}
+}
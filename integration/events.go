@@ -9,9 +9,13 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/cjsaylor/chessbot/api"
 	"github.com/cjsaylor/chessbot/game"
 	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/cjsaylor/chessbot/spectator"
+	"github.com/cjsaylor/chessbot/webhook"
 	"github.com/nlopes/slack"
 	"github.com/nlopes/slack/slackevents"
 	"github.com/notnil/chess"
@@ -25,6 +29,11 @@ type SlackHandler struct {
 	AuthStorage       AuthStorage
 	GameStorage       game.GameStorage
 	LinkRenderer      rendering.RenderLink
+	WatchLink         spectator.WatchLink
+	APITokenStorage   api.TokenStorage
+	BotStorage        api.BotStorage
+	WebhookStorage    webhook.WebhookStorage
+	PreferenceStorage PreferenceStorage
 	DbFileSizeInBytes int64
 }
 
@@ -32,12 +41,47 @@ const requestVersion = "v0"
 
 type command uint8
 
+// apiToken represents a request for a new API token scoped to the workspace.
+// botToken represents the registration of a bot account able to play as a team member.
+// watch represents a request for a live spectator link of the game.
+// webhookAdd, webhookRemove and webhookList manage the webhooks of the workspace.
+// replay represents a request for an animated replay of the game.
+// orientation represents a change of the preferred board orientation of a user.
+// theme represents a preview or change of the board theme of a user or workspace.
+// show represents a request for the current board with arrows and highlighted squares drawn on it.
+// summary represents a request for the current board with a side panel of captures and recent moves.
+// describe represents a request for a textual description of the board for screen reader users.
+// boardFormat represents a change of how boards are posted in the workspace.
+// history represents a request for who did what and when in the game.
+const (
+	apiToken = Help + 1 + iota
+	botToken
+	watch
+	webhookAdd
+	webhookRemove
+	webhookList
+	replay
+	orientation
+	theme
+	show
+	summary
+	describe
+	boardFormat
+	history
+)
+
+var botNamePattern = regexp.MustCompile("^[a-z0-9_-]+$")
+
 // SlackCommandPatterns is a list of patterns specific to how text is transmitted in the Slack platform.
 var slackCommandPatterns = []CommandPattern{
 	{
 		Type:    Challenge,
 		Pattern: regexp.MustCompile("^<@[\\w|\\d]+> new_game (.*)$"),
 	},
+	{
+		Type:    show,
+		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>\\s+show\\b\\s*(.*)$"),
+	},
 	{
 		Type:    Move,
 		Pattern: regexp.MustCompile("^<@[\\w|\\d]+> .*([a-h][1-8][a-h][1-8][qnrb]?).*$"),
@@ -50,6 +94,58 @@ var slackCommandPatterns = []CommandPattern{
 		Type:    Takeback,
 		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>.*take\\s?back.*$"),
 	},
+	{
+		Type:    theme,
+		Pattern: regexp.MustCompile("^(?:<@[\\w|\\d]+>\\s+)?theme\\b\\s*(.*)$"),
+	},
+	{
+		Type:    orientation,
+		Pattern: regexp.MustCompile("^.*orientation\\s+(turn|white|black|player).*$"),
+	},
+	{
+		Type:    boardFormat,
+		Pattern: regexp.MustCompile("^.*board_format\\s+(text|image).*$"),
+	},
+	{
+		Type:    describe,
+		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>\\s+describe\\s*$"),
+	},
+	{
+		Type:    history,
+		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>\\s+history\\s*$"),
+	},
+	{
+		Type:    summary,
+		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>\\s+(?:panel|summary)\\s*$"),
+	},
+	{
+		Type:    replay,
+		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>.*replay.*$"),
+	},
+	{
+		Type:    watch,
+		Pattern: regexp.MustCompile("^<@[\\w|\\d]+>.*watch.*$"),
+	},
+	{
+		Type:    botToken,
+		Pattern: regexp.MustCompile("^.*bot_token\\s+(\\S+).*$"),
+	},
+	{
+		Type:    webhookAdd,
+		Pattern: regexp.MustCompile("^.*webhook_add\\s+<?([^|>\\s]+).*$"),
+	},
+	{
+		Type:    webhookRemove,
+		Pattern: regexp.MustCompile("^.*webhook_remove\\s+<?([^|>\\s]+).*$"),
+	},
+	{
+		Type:    webhookList,
+		Pattern: regexp.MustCompile(".*webhooks.*"),
+	},
+	{
+		Type:    apiToken,
+		Pattern: regexp.MustCompile(".*api_token.*"),
+	},
 	{
 		Type:    Help,
 		Pattern: regexp.MustCompile(".*help.*"),
@@ -118,6 +214,30 @@ func (s SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					slack.MsgOptionText("You can use ChessBot to play Chess with other teammates.", false),
 					slack.MsgOptionAttachments(getHelpAttachments()...))
 			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == apiToken {
+				s.handleAPITokenCommand(event.TeamID, ev.User, ev)
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == botToken {
+				s.handleBotTokenCommand(event.TeamID, matched.Params[0], ev)
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == orientation {
+				s.handleOrientationCommand(ev.User, Orientation(matched.Params[0]), ev.Channel, "")
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == boardFormat {
+				s.handleBoardFormatCommand(event.TeamID, BoardFormat(matched.Params[0]), ev.Channel, "")
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == theme {
+				s.handleThemeCommand(ev.User, event.TeamID, matched.Params[0], ev.Channel, "")
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == webhookAdd {
+				s.handleWebhookAddCommand(event.TeamID, matched.Params[0], ev)
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == webhookRemove {
+				s.handleWebhookRemoveCommand(event.TeamID, matched.Params[0], ev)
+			}
+			if ev.ChannelType == "im" && ev.BotID == "" && matched.Type == webhookList {
+				s.handleWebhookListCommand(event.TeamID, ev)
+			}
 		case *slackevents.AppMentionEvent:
 			var gameID string
 			if ev.ThreadTimeStamp == "" {
@@ -131,7 +251,7 @@ func (s SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				s.sendErrorWithHelp(gameID, ev.Channel, "Sorry, I don't understand what you said.")
 			case Challenge:
 				challengeCommand, _ := matched.ToChallenge()
-				s.handleChallengeCommand(gameID, challengeCommand, ev)
+				s.handleChallengeCommand(gameID, event.TeamID, challengeCommand, ev)
 			case Move:
 				moveCommand, _ := matched.ToMove()
 				s.handleMoveCommand(gameID, moveCommand, ev)
@@ -139,6 +259,24 @@ func (s SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				s.handleResignCommand(gameID, ev)
 			case Takeback:
 				s.handleTakebackCommand(gameID, ev)
+			case watch:
+				s.handleWatchCommand(gameID, ev)
+			case replay:
+				s.handleReplayCommand(gameID, ev)
+			case orientation:
+				s.handleOrientationCommand(ev.User, Orientation(matched.Params[0]), ev.Channel, gameID)
+			case theme:
+				s.handleThemeCommand(ev.User, event.TeamID, matched.Params[0], ev.Channel, gameID)
+			case show:
+				s.handleShowCommand(gameID, matched.Params[0], ev)
+			case summary:
+				s.handleSummaryCommand(gameID, ev)
+			case describe:
+				s.handleDescribeCommand(gameID, ev)
+			case history:
+				s.handleHistoryCommand(gameID, ev)
+			case boardFormat:
+				s.handleBoardFormatCommand(event.TeamID, BoardFormat(matched.Params[0]), ev.Channel, gameID)
 			case Help:
 				s.handleHelpCommand(gameID, ev)
 			}
@@ -152,27 +290,24 @@ func (s SlackHandler) handleMoveCommand(gameID string, moveCommand *MoveCommand,
 		log.Println(err)
 		return
 	}
-	player := gm.TurnPlayer()
-	if !strings.Contains(player.ID, " "+ev.User+" ") {
-		s.sendError(gameID, ev.Channel, "Please wait for your turn.")
-		return
-	}
-	chessMove, err := gm.Move(moveCommand.LAN)
+	var chessMove *chess.Move
+	gm, err = game.Update(s.GameStorage, gm, func(gm *game.Game) error {
+		chessMove, err = gm.MoveAs(ev.User, moveCommand.LAN)
+		return err
+	})
 	if err != nil {
 		s.sendError(gameID, ev.Channel, err.Error())
 		return
 	}
-	if err := s.GameStorage.StoreGame(gameID, gm); err != nil {
-		s.sendError(gameID, ev.Channel, err.Error())
-		return
-	}
+	s.postMove(gm, chessMove, ev.User, ev.Channel, ev.TimeStamp)
+}
 
-	link, _ := s.LinkRenderer.CreateLink(gm)
-	boardAttachment := slack.Attachment{
-		Text:     chessMove.String(),
-		ImageURL: link.String(),
-		Color:    colorToHex[gm.Turn()],
-	}
+// postMove posts the board after a move to the thread of the game, or the end of the game when the move finished it
+func (s SlackHandler) postMove(gm *game.Game, chessMove *chess.Move, viewerID string, channel string, threadTS string) {
+	boardAttachment := s.withBoard(slack.Attachment{
+		Text:  chessMove.String(),
+		Color: colorToHex[gm.Turn()],
+	}, gm, s.boardLinkOptions(gm, viewerID, channel), true)
 	pgnAttachment := slack.Attachment{
 		Title:     "Analysis",
 		TitleLink: s.Hostname + "/analyze?game_id=" + gm.ID,
@@ -180,61 +315,55 @@ func (s SlackHandler) handleMoveCommand(gameID string, moveCommand *MoveCommand,
 	}
 
 	if outcome := gm.Outcome(); outcome != chess.NoOutcome {
-		s.displayEndGame(gm, ev)
+		s.displayEndGame(gm, viewerID, channel, threadTS)
 	} else {
 		var fileSizeWarning = ""
 		if s.DbFileSizeInBytes > 1024*1024*3 {
-			fileSizeWarning = "Warning: DBFileSize=" + string(s.DbFileSizeInBytes)
+			fileSizeWarning = fmt.Sprintf("Warning: DBFileSize=%v", s.DbFileSizeInBytes)
 		}
 		s.SlackClient.PostMessage(
-			ev.Channel,
+			channel,
 			slack.MsgOptionText(fmt.Sprintf("%v to move (%v) %v", gm.Turn(), strings.Trim(strings.ReplaceAll(gm.TurnPlayer().ID, " ", "> <@"), "<>@"), fileSizeWarning), false),
 			slack.MsgOptionAttachments(boardAttachment, pgnAttachment),
-			slack.MsgOptionTS(ev.TimeStamp))
+			slack.MsgOptionTS(threadTS))
 	}
 }
 
-func (s SlackHandler) displayEndGame(gm *game.Game, ev *slackevents.AppMentionEvent) {
+func (s SlackHandler) displayEndGame(gm *game.Game, viewerID string, channel string, threadTS string) {
 	pgnAttachment := slack.Attachment{
 		Title:     "Analysis",
 		TitleLink: s.Hostname + "/analyze?game_id=" + gm.ID,
 		Text:      gm.Export(),
 	}
-	link, _ := s.LinkRenderer.CreateLink(gm)
-	boardAttachment := slack.Attachment{
-		Text:     gm.LastMove().String(),
-		ImageURL: link.String(),
+	boardAttachment := s.withBoard(slack.Attachment{
+		Text: gm.LastMove().String(),
+	}, gm, s.boardLinkOptions(gm, viewerID, channel), true)
+	replayLink, _ := s.LinkRenderer.CreateReplayLink(gm)
+	replayAttachment := slack.Attachment{
+		Title:    "Replay",
+		ImageURL: replayLink.String(),
+	}
+	evaluationLink, _ := s.LinkRenderer.CreateEvaluationLink(gm)
+	evaluationAttachment := slack.Attachment{
+		Title:    "Evaluation",
+		Text:     "Mistakes are marked in orange and blunders in red.",
+		ImageURL: evaluationLink.String(),
 	}
 	s.SlackClient.PostMessage(
-		ev.Channel,
+		channel,
 		slack.MsgOptionText(gm.ResultText(), false),
-		slack.MsgOptionTS(ev.TimeStamp),
-		slack.MsgOptionAttachments(boardAttachment, pgnAttachment))
+		slack.MsgOptionTS(threadTS),
+		slack.MsgOptionAttachments(boardAttachment, evaluationAttachment, pgnAttachment, replayAttachment))
 	// @todo persist record to some incremental storage (redis, etc)
 }
 
-func (s SlackHandler) handleChallengeCommand(gameID string, command *ChallengeCommand, ev *slackevents.AppMentionEvent) {
+func (s SlackHandler) handleChallengeCommand(gameID string, teamID string, command *ChallengeCommand, ev *slackevents.AppMentionEvent) {
 	if _, err := s.GameStorage.RetrieveGame(gameID); err == nil {
 		s.sendErrorWithHelp(gameID, ev.Channel, "A game already exists in this thread. Try making a new thread.")
 		return
 	}
 
-	var challengerId = " "
-	var challengedId = " "
-	var current = ""
-	for _, param := range command.ChallengeParams {
-		log.Printf("Param: %s\n", param)
-		log.Printf("Current: %s\n", current)
-		current = current + " "
-		if strings.Contains(param, ":") {
-			challengerId = current
-			current = ""
-		} else {
-			current = current + param
-		}
-	}
-
-	challengedId = current + " "
+	challengerId, challengedId := command.Teams()
 
 	log.Printf("challengerId: %s\n", challengerId)
 	log.Printf("challengedId: %s\n", challengedId)
@@ -245,20 +374,18 @@ func (s SlackHandler) handleChallengeCommand(gameID string, command *ChallengeCo
 	}, game.Player{
 		ID: challengedId,
 	})
+	gm.WorkspaceID = teamID
+	gm.ChannelID = ev.Channel
+	gm.CreatedBy = ev.User
 	s.GameStorage.StoreGame(gameID, gm)
 	gm.Start()
-	// Repeated call to fix font resolve issue
-	link, _ := s.LinkRenderer.CreateLink(gm)
-	link, _ = s.LinkRenderer.CreateLink(gm)
-	log.Printf("Image link: %s\n", link.String())
 	s.SlackClient.PostMessage(
 		ev.Channel,
 		slack.MsgOptionText(fmt.Sprintf("%v to move (%v)", gm.Turn(), strings.Trim(strings.ReplaceAll(gm.TurnPlayer().ID, " ", "> <@"), "<>@")), false),
 		slack.MsgOptionTS(gameID),
-		slack.MsgOptionAttachments(slack.Attachment{
-			Text:     fmt.Sprintf("Game '%v' vs. '%v' started, here is the opening.", strings.Trim(strings.ReplaceAll(challengerId, " ", "> <@"), "<>@"), strings.Trim(strings.ReplaceAll(challengedId, " ", "> <@"), "<>@")),
-			ImageURL: link.String(),
-		}))
+		slack.MsgOptionAttachments(s.withBoard(slack.Attachment{
+			Text: fmt.Sprintf("Game '%v' vs. '%v' started, here is the opening.", strings.Trim(strings.ReplaceAll(challengerId, " ", "> <@"), "<>@"), strings.Trim(strings.ReplaceAll(challengedId, " ", "> <@"), "<>@")),
+		}, gm, s.boardLinkOptions(gm, ev.User, ev.Channel), false)))
 }
 
 func (s SlackHandler) handleResignCommand(gameID string, ev *slackevents.AppMentionEvent) {
@@ -267,13 +394,18 @@ func (s SlackHandler) handleResignCommand(gameID string, ev *slackevents.AppMent
 		log.Println(err)
 		return
 	}
-	player, err := gm.PlayerByID(ev.User)
-	if err != nil {
+	if _, err := gm.PlayerByID(ev.User); err != nil {
 		s.sendError(gameID, ev.Channel, "I couldn't find you as part of this game.")
 		return
 	}
-	gm.Resign(*player)
-	s.displayEndGame(gm, ev)
+	gm, err = game.Update(s.GameStorage, gm, func(gm *game.Game) error {
+		return gm.ResignAs(ev.User)
+	})
+	if err != nil {
+		s.sendError(gameID, ev.Channel, err.Error())
+		return
+	}
+	s.displayEndGame(gm, ev.User, ev.Channel, ev.TimeStamp)
 }
 
 func (s SlackHandler) handleTakebackCommand(gameID string, ev *slackevents.AppMentionEvent) {
@@ -282,35 +414,336 @@ func (s SlackHandler) handleTakebackCommand(gameID string, ev *slackevents.AppMe
 		log.Println(err)
 		return
 	}
-	player, err := gm.PlayerByID(ev.User)
-	if err != nil {
+	if _, err := gm.PlayerByID(ev.User); err != nil {
 		s.sendError(gameID, ev.Channel, "I couldn't find you as part of this game.")
 		return
 	}
-	chessMove, err := gm.Takeback(player)
+	var chessMove *chess.Move
+	gm, err = game.Update(s.GameStorage, gm, func(gm *game.Game) error {
+		var err error
+		if chessMove, err = gm.TakebackAs(ev.User); err != nil {
+			return fmt.Errorf("Take back request failed: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		s.sendError(gameID, ev.Channel, fmt.Sprintf("Take back request failed: %v", err))
+		s.sendError(gameID, ev.Channel, err.Error())
 		return
 	}
-	link, _ := s.LinkRenderer.CreateLink(gm)
 	boardAttachment := slack.Attachment{
-		ImageURL: link.String(),
-		Color:    colorToHex[gm.Turn()],
+		Color: colorToHex[gm.Turn()],
 	}
 	if chessMove != nil {
 		boardAttachment.Text = chessMove.String()
 	}
-	if err := s.GameStorage.StoreGame(gameID, gm); err != nil {
-		s.sendError(gameID, ev.Channel, err.Error())
-		return
-	}
+	boardAttachment = s.withBoard(boardAttachment, gm, s.boardLinkOptions(gm, ev.User, ev.Channel), false)
 	s.SlackClient.PostMessage(
 		ev.Channel,
-		slack.MsgOptionText(fmt.Sprintf("<@%v> requested a take back, it is now <@%v>'s turn again.", player.ID, gm.TurnPlayer().ID), false),
+		slack.MsgOptionText(fmt.Sprintf("<@%v> requested a take back, it is now <@%v>'s turn again.", ev.User, gm.TurnPlayer().ID), false),
 		slack.MsgOptionAttachments(boardAttachment),
 		slack.MsgOptionTS(ev.TimeStamp))
 }
 
+func (s SlackHandler) handleWatchCommand(gameID string, ev *slackevents.AppMentionEvent) {
+	if _, err := s.GameStorage.RetrieveGame(gameID); err != nil {
+		s.sendError(gameID, ev.Channel, "There is no game in this thread to watch.")
+		return
+	}
+	link, err := s.WatchLink.CreateLink(gameID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionText(fmt.Sprintf("Follow this game live at %v", link), false),
+		slack.MsgOptionTS(gameID))
+}
+
+// boardLinkOptions orients and styles the current position of the game for the viewer. Boards in direct messages
+// are shown from the recipient's side unless the viewer prefers otherwise, the style of the viewer takes precedence
+// over the workspace one.
+func (s SlackHandler) boardLinkOptions(gm *game.Game, viewerID string, channel string) rendering.LinkOptions {
+	preference := OrientationTurn
+	if strings.HasPrefix(channel, "D") {
+		preference = OrientationPlayer
+	}
+	style := rendering.Style{}
+	if s.PreferenceStorage != nil {
+		if stored, err := s.PreferenceStorage.GetOrientation(viewerID); err == nil && stored != "" {
+			preference = stored
+		}
+		if stored, err := s.PreferenceStorage.GetStyle(viewerID); err == nil {
+			style = stored
+		} else if stored, err := s.PreferenceStorage.GetStyle(gm.WorkspaceID); err == nil {
+			style = stored
+		}
+	}
+	return rendering.LinkOptions{
+		Inverted: boardInverted(gm, viewerID, preference),
+		Style:    style,
+	}
+}
+
+// handleThemeCommand previews the available themes when no arguments are given, otherwise it stores
+// "<theme> [<piece set>] [workspace]" for the user or the whole workspace.
+func (s SlackHandler) handleThemeCommand(userID string, teamID string, args string, channel string, threadTS string) {
+	if s.PreferenceStorage == nil {
+		return
+	}
+	options := []slack.MsgOption{}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) == 0 {
+		gm, err := s.GameStorage.RetrieveGame(threadTS)
+		if err != nil {
+			gm = game.NewGame("preview", game.Player{}, game.Player{})
+			gm.Move("e2e4")
+		}
+		attachments := []slack.Attachment{}
+		for _, theme := range rendering.Themes() {
+			link, _ := s.LinkRenderer.CreateLinkWithOptions(gm, rendering.LinkOptions{Style: rendering.Style{Theme: theme.Name}})
+			attachments = append(attachments, slack.Attachment{
+				Title:    theme.Name,
+				Text:     theme.Description,
+				ImageURL: link.String(),
+				Fallback: rendering.AltText(gm, len(gm.Moves())),
+			})
+		}
+		text := fmt.Sprintf("Choose a theme with \"theme <name>\", optionally followed by a piece set (%v) and \"workspace\" to make it the default for everyone.", strings.Join(rendering.PieceSets(), ", "))
+		options = append(options, slack.MsgOptionText(text, false), slack.MsgOptionAttachments(attachments...))
+		s.SlackClient.PostMessage(channel, options...)
+		return
+	}
+	style := rendering.Style{Theme: fields[0]}
+	ID, scope := userID, "you"
+	if _, ok := rendering.LookupTheme(style.Theme); !ok {
+		s.SlackClient.PostMessage(channel, append(options, slack.MsgOptionText(fmt.Sprintf("I don't know the theme \"%v\", say \"theme\" to see all of them.", style.Theme), false))...)
+		return
+	}
+	for _, field := range fields[1:] {
+		switch {
+		case field == "workspace":
+			ID, scope = teamID, "everyone in this workspace"
+		case rendering.ValidPieceSet(field):
+			style.Pieces = field
+		default:
+			s.SlackClient.PostMessage(channel, append(options, slack.MsgOptionText(fmt.Sprintf("I don't know the piece set \"%v\".", field), false))...)
+			return
+		}
+	}
+	text := fmt.Sprintf("Boards will now be shown to %v with the `%v` theme.", scope, style.Theme)
+	if err := s.PreferenceStorage.StoreStyle(ID, style); err != nil {
+		log.Println(err)
+		text = "Sorry, I couldn't save the theme."
+	}
+	s.SlackClient.PostMessage(channel, append(options, slack.MsgOptionText(text, false))...)
+}
+
+func (s SlackHandler) handleOrientationCommand(userID string, preference Orientation, channel string, threadTS string) {
+	if s.PreferenceStorage == nil {
+		return
+	}
+	text := fmt.Sprintf("Boards will now be shown to you with the `%v` orientation.", preference)
+	if err := s.PreferenceStorage.StoreOrientation(userID, preference); err != nil {
+		log.Println(err)
+		text = "Sorry, I couldn't save your preference."
+	}
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	s.SlackClient.PostMessage(channel, options...)
+}
+
+func (s SlackHandler) handleShowCommand(gameID string, args string, ev *slackevents.AppMentionEvent) {
+	gm, err := s.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		s.sendError(gameID, ev.Channel, "There is no game in this thread to show.")
+		return
+	}
+	annotations := showAnnotations(args)
+	if len(annotations.Arrows) == 0 && len(annotations.Marks) == 0 {
+		s.sendError(gameID, ev.Channel, "Tell me which moves or squares to show, e.g. \"show e2e4 d7d5 arrows\".")
+		return
+	}
+	options := s.boardLinkOptions(gm, ev.User, ev.Channel)
+	options.Annotations = annotations
+	link, _ := s.LinkRenderer.CreateGameLink(gm, len(gm.Moves()), options)
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionAttachments(slack.Attachment{
+			Text:     strings.TrimSpace(args),
+			ImageURL: link.String(),
+			Fallback: rendering.AltText(gm, len(gm.Moves())),
+		}),
+		slack.MsgOptionTS(gameID))
+}
+
+func (s SlackHandler) handleSummaryCommand(gameID string, ev *slackevents.AppMentionEvent) {
+	gm, err := s.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		s.sendError(gameID, ev.Channel, "There is no game in this thread to summarize.")
+		return
+	}
+	options := s.boardLinkOptions(gm, ev.User, ev.Channel)
+	options.Panel = true
+	link, err := s.LinkRenderer.CreateGameLink(gm, len(gm.Moves()), options)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionAttachments(withBrowseActions(slack.Attachment{
+			Title:    "Game summary",
+			ImageURL: link.String(),
+			Fallback: rendering.AltText(gm, len(gm.Moves())),
+		}, gm, "")),
+		slack.MsgOptionTS(gameID))
+}
+
+func (s SlackHandler) handleDescribeCommand(gameID string, ev *slackevents.AppMentionEvent) {
+	gm, err := s.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		s.sendError(gameID, ev.Channel, "There is no game in this thread to describe.")
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionText(rendering.AltText(gm, len(gm.Moves())), false),
+		slack.MsgOptionTS(gameID))
+}
+
+func (s SlackHandler) handleReplayCommand(gameID string, ev *slackevents.AppMentionEvent) {
+	gm, err := s.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		s.sendError(gameID, ev.Channel, "There is no game in this thread to replay.")
+		return
+	}
+	link, _ := s.LinkRenderer.CreateReplayLink(gm)
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionAttachments(slack.Attachment{
+			Title:    "Replay",
+			ImageURL: link.String(),
+		}),
+		slack.MsgOptionTS(gameID))
+}
+
+func (s SlackHandler) handleAPITokenCommand(teamID string, userID string, ev *slackevents.MessageEvent) {
+	if s.APITokenStorage == nil {
+		return
+	}
+	token, err := api.GenerateToken(s.APITokenStorage, teamID, userID)
+	if err != nil {
+		log.Println(err)
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText("Sorry, I couldn't create an API token.", false))
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionText(fmt.Sprintf("Here is your API token for this workspace, moves made with it are made as you. Keep it secret: `%v`\nUse it as a bearer token with %v/api/games", token, s.Hostname), false))
+}
+
+func (s SlackHandler) handleBotTokenCommand(teamID string, name string, ev *slackevents.MessageEvent) {
+	if s.BotStorage == nil {
+		return
+	}
+	name = strings.ToLower(name)
+	if !botNamePattern.MatchString(name) {
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText("Bot names may only contain letters, digits, \"-\" and \"_\".", false))
+		return
+	}
+	token, err := api.RegisterBot(s.BotStorage, api.BotAccount{ID: name, WorkspaceID: teamID, OwnerID: ev.User})
+	if err == api.ErrBotNameTaken {
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText(fmt.Sprintf("The bot name `%v` is already taken by another member, please pick another one.", name), false))
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText("Sorry, I couldn't register the bot.", false))
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionText(fmt.Sprintf("Bot `%v` registered, keep its token secret: `%v`\nAsking again gives the bot a new token and revokes this one. Point a Lichess Bot API client at %v with this token, and add `%v` to a team when starting a game.", name, token, s.Hostname, name), false))
+}
+
+func (s SlackHandler) handleWebhookAddCommand(teamID string, hookURL string, ev *slackevents.MessageEvent) {
+	if s.WebhookStorage == nil {
+		return
+	}
+	if err := webhook.ValidateURL(hookURL); err == webhook.ErrInvalidURL || err == webhook.ErrPrivateAddress {
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText(fmt.Sprintf("Sorry, %v.", err), false))
+		return
+	} else if err != nil {
+		log.Println(err)
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText(fmt.Sprintf("Sorry, I couldn't resolve %v.", hookURL), false))
+		return
+	}
+	secret, err := webhook.NewSecret()
+	if err == nil {
+		err = s.WebhookStorage.StoreWebhook(webhook.Webhook{WorkspaceID: teamID, URL: hookURL, Secret: secret})
+	}
+	if err != nil {
+		log.Println(err)
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText("Sorry, I couldn't register the webhook.", false))
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionText(fmt.Sprintf("Game events will be posted to %v\nVerify the `%v` header with this secret: `%v`", hookURL, webhook.SignatureHeader, secret), false))
+}
+
+func (s SlackHandler) handleWebhookRemoveCommand(teamID string, hookURL string, ev *slackevents.MessageEvent) {
+	if s.WebhookStorage == nil {
+		return
+	}
+	if err := s.WebhookStorage.RemoveWebhook(teamID, hookURL); err != nil {
+		log.Println(err)
+		s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText("Sorry, I couldn't remove the webhook.", false))
+		return
+	}
+	s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText(fmt.Sprintf("Game events will no longer be posted to %v", hookURL), false))
+}
+
+func (s SlackHandler) handleWebhookListCommand(teamID string, ev *slackevents.MessageEvent) {
+	if s.WebhookStorage == nil {
+		return
+	}
+	hooks, err := s.WebhookStorage.WebhooksByWorkspace(teamID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	deliveries, err := s.WebhookStorage.Deliveries(teamID, 10)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	text := "No webhooks are registered, add one with `webhook_add <url>`."
+	if len(hooks) > 0 {
+		text = "Registered webhooks:"
+		for _, hook := range hooks {
+			text += "\n• " + hook.URL
+		}
+	}
+	if len(deliveries) > 0 {
+		text += "\nRecent deliveries:"
+		for _, delivery := range deliveries {
+			result := "delivered"
+			if !delivery.Succeeded() {
+				result = delivery.Error
+			}
+			text += fmt.Sprintf("\n• %v %v of game %v to %v (attempt %v): %v", delivery.CreatedAt.Format(time.RFC3339), delivery.Event, delivery.GameID, delivery.URL, delivery.Attempt, result)
+		}
+	}
+	s.SlackClient.PostMessage(ev.Channel, slack.MsgOptionText(text, false))
+}
+
 func getHelpAttachments() []slack.Attachment {
 	return []slack.Attachment{
 		slack.Attachment{
@@ -321,6 +754,42 @@ func getHelpAttachments() []slack.Attachment {
 			Title: "Making a move",
 			Text:  "To make a move playing, mention @chessbot and say \"d2d4\" which are the grid position of the piece you wish to move and the destination. For more advanced moves like castling etc. check out the help link below.",
 		},
+		slack.Attachment{
+			Title: "Board orientation",
+			Text:  "Boards are shown from the side of the player to move. Say \"orientation white\", \"orientation black\" or \"orientation player\" (your own side) to change how boards requested by you are shown, or \"orientation turn\" to go back.",
+		},
+		slack.Attachment{
+			Title: "Board themes",
+			Text:  "Say \"theme\" to preview the board themes, including dark mode, high contrast and color-blind safe ones, then \"theme <name>\" to pick one.",
+		},
+		slack.Attachment{
+			Title: "Illustrating ideas",
+			Text:  "To draw on the current board, mention @chessbot in the game thread and say \"show\" followed by moves to draw as arrows and squares to highlight, e.g. \"show e2e4 d7d5 arrows\" or \"show red f7 blue d1h5\".",
+		},
+		slack.Attachment{
+			Title: "Describing the board",
+			Text:  "Every board comes with a text description for screen readers. To read it on demand, mention @chessbot in the game thread and say \"describe\".",
+		},
+		slack.Attachment{
+			Title: "Text boards",
+			Text:  "If board images can't be loaded in your workspace, say \"board_format text\" to have boards posted as text instead, or \"board_format image\" to go back.",
+		},
+		slack.Attachment{
+			Title: "Game history",
+			Text:  "To see who made every move, take back and resignation and when, mention @chessbot in the game thread and say \"history\".",
+		},
+		slack.Attachment{
+			Title: "Game summary",
+			Text:  "To see the board next to the captured pieces, material balance and last moves, mention @chessbot in the game thread and say \"summary\".",
+		},
+		slack.Attachment{
+			Title: "Replaying a game",
+			Text:  "To see an animation of every move so far, mention @chessbot in the game thread and say \"replay\".",
+		},
+		slack.Attachment{
+			Title: "Watching a game",
+			Text:  "To follow a game live, mention @chessbot in its thread and say \"watch\" to get a link to a page which updates with every move.",
+		},
 		slack.Attachment{
 			Pretext:   "For additional help visit our website.",
 			Title:     "ChessBot Help",
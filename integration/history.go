@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+)
+
+// slackMentions mentions every member of a player or team, falling back to "someone" for unknown actors
+func slackMentions(ID string) string {
+	members := strings.Fields(ID)
+	if len(members) == 0 {
+		return "someone"
+	}
+	for i, member := range members {
+		members[i] = "<@" + member + ">"
+	}
+	return strings.Join(members, " ")
+}
+
+// gameHistory lists who did what and when in a game, one event per line
+func gameHistory(events []game.Event) string {
+	lines := []string{}
+	ply := 0
+	for _, event := range events {
+		when := fmt.Sprintf("<!date^%v^{date_short} {time}|%v>", event.CreatedAt.Unix(), event.CreatedAt.UTC().Format("2006-01-02 15:04 UTC"))
+		actor := slackMentions(event.ActorID)
+		switch event.Type {
+		case game.EventChallenge:
+			if fields := strings.Fields(event.FEN); len(fields) > 1 && fields[1] == "b" {
+				ply = 1
+			}
+			lines = append(lines, fmt.Sprintf("%v %v started %v (White) vs. %v (Black)", when, actor, slackMentions(event.White), slackMentions(event.Black)))
+		case game.EventMove:
+			number := fmt.Sprintf("%v.", ply/2+1)
+			if ply%2 == 1 {
+				number += ".."
+			}
+			ply++
+			lines = append(lines, fmt.Sprintf("%v %v played `%v %v`", when, actor, number, event.Move))
+		case game.EventTakeback:
+			ply--
+			lines = append(lines, fmt.Sprintf("%v %v took back `%v`", when, actor, event.Move))
+		case game.EventResign:
+			lines = append(lines, fmt.Sprintf("%v %v resigned", when, actor))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s SlackHandler) handleHistoryCommand(gameID string, ev *slackevents.AppMentionEvent) {
+	storage, ok := s.GameStorage.(game.EventStorage)
+	if !ok {
+		s.sendError(gameID, ev.Channel, "The history of games is not kept.")
+		return
+	}
+	events, err := storage.Events(gameID)
+	if err != nil || len(events) == 0 {
+		s.sendError(gameID, ev.Channel, "There is no history of a game in this thread.")
+		return
+	}
+	s.SlackClient.PostMessage(
+		ev.Channel,
+		slack.MsgOptionText(gameHistory(events), false),
+		slack.MsgOptionTS(gameID))
+}
@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// imapClient is a minimal IMAP4rev1 client, only supporting what is required to read an inbox
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+var imapLiteralPattern = regexp.MustCompile("\\{(\\d+)\\}$")
+
+// imapResponse holds the untagged lines and literals received before the tagged completion of a command
+type imapResponse struct {
+	lines    []string
+	literals [][]byte
+}
+
+func dialIMAP(addr string, useTLS bool) (*imapClient, error) {
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = tls.Dial("tcp", addr, &tls.Config{})
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client := &imapClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	greeting, err := client.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting: %v", strings.TrimSpace(greeting))
+	}
+	return client, nil
+}
+
+func imapQuote(value string) string {
+	return "\"" + strings.ReplaceAll(strings.ReplaceAll(value, "\\", "\\\\"), "\"", "\\\"") + "\""
+}
+
+func (c *imapClient) command(format string, args ...interface{}) (*imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%v %v\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	response := &imapResponse{}
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, tag+" ") {
+			if status := strings.TrimPrefix(line, tag+" "); !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("IMAP command failed: %v", status)
+			}
+			return response, nil
+		}
+		for {
+			match := imapLiteralPattern.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			size, _ := strconv.Atoi(match[1])
+			literal := make([]byte, size)
+			if _, err := io.ReadFull(c.reader, literal); err != nil {
+				return nil, err
+			}
+			response.literals = append(response.literals, literal)
+			rest, err := c.reader.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+			line = strings.TrimRight(rest, "\r\n")
+		}
+		response.lines = append(response.lines, line)
+	}
+}
+
+func (c *imapClient) login(user string, password string) error {
+	_, err := c.command("LOGIN %v %v", imapQuote(user), imapQuote(password))
+	return err
+}
+
+func (c *imapClient) selectMailbox(mailbox string) error {
+	_, err := c.command("SELECT %v", imapQuote(mailbox))
+	return err
+}
+
+// unseen returns the UIDs of all messages without the \Seen flag
+func (c *imapClient) unseen() ([]string, error) {
+	response, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	uids := []string{}
+	for _, line := range response.lines {
+		if strings.HasPrefix(line, "* SEARCH") {
+			uids = append(uids, strings.Fields(strings.TrimPrefix(line, "* SEARCH"))...)
+		}
+	}
+	return uids, nil
+}
+
+func (c *imapClient) fetch(uid string) ([]byte, error) {
+	response, err := c.command("UID FETCH %v BODY.PEEK[]", uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.literals) == 0 {
+		return nil, fmt.Errorf("message %v not found", uid)
+	}
+	return response.literals[0], nil
+}
+
+func (c *imapClient) markSeen(uid string) error {
+	_, err := c.command("UID STORE %v +FLAGS (\\Seen)", uid)
+	return err
+}
+
+func (c *imapClient) logout() error {
+	defer c.conn.Close()
+	_, err := c.command("LOGOUT")
+	return err
+}
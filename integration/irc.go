@@ -0,0 +1,374 @@
+package integration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/notnil/chess"
+)
+
+// IRCBot plays games over an IRC connection
+// Games are listed by channel, a channel playing one game at a time. Games challenged in a private message are
+// played by a pair of nicks, each nick playing one private game at a time.
+// The game storage has to be able to list games (see game.GameLister).
+type IRCBot struct {
+	Nick         string
+	Channels     []string
+	GameStorage  game.GameStorage
+	LinkRenderer *rendering.RenderLink
+
+	conn io.ReadWriter
+}
+
+// NewIRCBot returns an IRC bot that will join the given channels once registered
+// The link renderer is optional, if provided signed board links are posted next to the text boards.
+func NewIRCBot(nick string, channels []string, store game.GameStorage, linkRenderer *rendering.RenderLink) *IRCBot {
+	return &IRCBot{
+		Nick:         nick,
+		Channels:     channels,
+		GameStorage:  store,
+		LinkRenderer: linkRenderer,
+	}
+}
+
+// ircBoard represents a request to show the current board of the game.
+const ircBoard = Help + 1
+
+var ircCommandPatterns = []CommandPattern{
+	{
+		Type:    Challenge,
+		Pattern: regexp.MustCompile("^!new_game (.*)$"),
+	},
+	{
+		Type:    Move,
+		Pattern: regexp.MustCompile("^!move ([a-h][1-8][a-h][1-8][qnrb]?)$"),
+	},
+	{
+		Type:    Resign,
+		Pattern: regexp.MustCompile("^!resign$"),
+	},
+	{
+		Type:    Takeback,
+		Pattern: regexp.MustCompile("^!take\\s?back$"),
+	},
+	{
+		Type:    ircBoard,
+		Pattern: regexp.MustCompile("^!board$"),
+	},
+	{
+		Type:    Help,
+		Pattern: regexp.MustCompile("^!help$"),
+	},
+}
+
+var ircCommandParser = NewCommandParser(ircCommandPatterns)
+
+var ircHelpText = []string{
+	"You can use ChessBot to play Chess with other teammates.",
+	"Start a game in a channel with \"!new_game p1 p2 : p3 p4\", the two teams are separated by \":\". In a private message, \"!new_game nick\" challenges a single player.",
+	"Make a move with \"!move d2d4\", show the board with \"!board\", resign with \"!resign\" or take back your last move with \"!takeback\".",
+}
+
+type ircMessage struct {
+	nick    string
+	command string
+	params  []string
+}
+
+func parseIRCMessage(line string) ircMessage {
+	message := ircMessage{}
+	if strings.HasPrefix(line, ":") {
+		prefix := line[1:]
+		if i := strings.Index(prefix, " "); i >= 0 {
+			line = prefix[i+1:]
+			prefix = prefix[:i]
+		} else {
+			line = ""
+		}
+		if i := strings.Index(prefix, "!"); i >= 0 {
+			prefix = prefix[:i]
+		}
+		message.nick = prefix
+	}
+	var trailing string
+	hasTrailing := false
+	if i := strings.Index(line, " :"); i >= 0 {
+		trailing = line[i+2:]
+		line = line[:i]
+		hasTrailing = true
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		message.command = strings.ToUpper(fields[0])
+		message.params = fields[1:]
+	}
+	if hasTrailing {
+		message.params = append(message.params, trailing)
+	}
+	return message
+}
+
+// Run registers with the server and processes messages until the connection is closed
+func (b *IRCBot) Run(conn io.ReadWriter) error {
+	b.conn = conn
+	b.send("NICK %v", b.Nick)
+	b.send("USER %v 0 * :Chess Bot", b.Nick)
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		message := parseIRCMessage(strings.TrimRight(line, "\r\n"))
+		switch message.command {
+		case "PING":
+			b.send("PONG :%v", strings.Join(message.params, " "))
+		case "001":
+			for _, channel := range b.Channels {
+				b.send("JOIN %v", channel)
+			}
+		case "PRIVMSG":
+			if len(message.params) == 2 {
+				b.handlePrivmsg(message.nick, message.params[0], strings.TrimSpace(message.params[1]))
+			}
+		}
+	}
+}
+
+func (b *IRCBot) send(format string, args ...interface{}) {
+	if _, err := fmt.Fprintf(b.conn, format+"\r\n", args...); err != nil {
+		log.Println(err)
+	}
+}
+
+func (b *IRCBot) say(targets []string, text string) {
+	for _, target := range targets {
+		b.send("PRIVMSG %v :%v", target, text)
+	}
+}
+
+// ircPrivateWorkspace lists the games challenged in private messages
+const ircPrivateWorkspace = "irc:private"
+
+func privateGameID(nicks ...string) string {
+	sort.Strings(nicks)
+	return "irc:" + strings.Join(nicks, ",")
+}
+
+// newIRCGameID returns a fresh game ID, so every game of a channel (or pair of nicks) has its own record
+func newIRCGameID(prefix string) string {
+	return fmt.Sprintf("%v:%v", prefix, time.Now().UnixNano())
+}
+
+// currentGame returns the unfinished game of a workspace, otherwise the game started last.
+// When a nick is given only the games the nick plays in are considered.
+func (b *IRCBot) currentGame(workspaceID string, nick string) *game.Game {
+	lister, ok := b.GameStorage.(game.GameLister)
+	if !ok {
+		log.Println("IRC games need a game storage able to list games")
+		return nil
+	}
+	games, err := lister.ListGames(workspaceID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	var current *game.Game
+	for _, gm := range games {
+		if nick != "" && !gm.Players[game.White].HasMember(nick) && !gm.Players[game.Black].HasMember(nick) {
+			continue
+		}
+		// games are listed by ID, which orders them by the time they were started
+		if current == nil || gm.Outcome() == chess.NoOutcome || current.Outcome() != chess.NoOutcome {
+			current = gm
+		}
+	}
+	return current
+}
+
+func (b *IRCBot) handlePrivmsg(nick string, target string, text string) {
+	matched := ircCommandParser.ParseInput(text)
+	if matched.Type == Unknown {
+		return
+	}
+	if matched.Type == Help {
+		for _, line := range ircHelpText {
+			b.say([]string{nick}, line)
+		}
+		return
+	}
+	private := !strings.HasPrefix(target, "#") && !strings.HasPrefix(target, "&")
+	workspaceID := "irc:" + target
+	replyTo := []string{target}
+	player := ""
+	if private {
+		workspaceID = ircPrivateWorkspace
+		replyTo = []string{nick}
+		player = nick
+	}
+	if matched.Type == Challenge {
+		challengeCommand, _ := matched.ToChallenge()
+		b.handleChallengeCommand(workspaceID, nick, private, challengeCommand, replyTo)
+		return
+	}
+	gm := b.currentGame(workspaceID, player)
+	if gm == nil {
+		b.say(replyTo, "There is no game here yet, try !help.")
+		return
+	}
+	if private {
+		replyTo = append(gm.Players[game.White].Members(), gm.Players[game.Black].Members()...)
+	}
+	switch matched.Type {
+	case Move:
+		moveCommand, _ := matched.ToMove()
+		b.handleMoveCommand(gm, nick, moveCommand, replyTo)
+	case Resign:
+		b.handleResignCommand(gm, nick, replyTo)
+	case Takeback:
+		b.handleTakebackCommand(gm, nick, replyTo)
+	case ircBoard:
+		b.sayBoard(replyTo, gm, ircTurnText(gm))
+	}
+}
+
+func (b *IRCBot) handleChallengeCommand(workspaceID string, nick string, private bool, command *ChallengeCommand, replyTo []string) {
+	var gameID, challengerID, challengedID string
+	if private {
+		opponent := strings.Join(command.ChallengeParams, " ")
+		challengerID, challengedID = " "+nick+" ", " "+opponent+" "
+		replyTo = []string{nick, opponent}
+		for _, member := range replyTo {
+			if gm := b.currentGame(workspaceID, member); gm != nil && gm.Outcome() == chess.NoOutcome {
+				b.say(replyTo, fmt.Sprintf("%v is already playing a private game.", member))
+				return
+			}
+		}
+		gameID = newIRCGameID(privateGameID(nick, opponent))
+	} else {
+		if gm := b.currentGame(workspaceID, ""); gm != nil && gm.Outcome() == chess.NoOutcome {
+			b.say(replyTo, "A game is already in progress here.")
+			return
+		}
+		challengerID, challengedID = command.Teams()
+		gameID = newIRCGameID(workspaceID)
+	}
+	if strings.TrimSpace(challengerID) == "" || strings.TrimSpace(challengedID) == "" {
+		b.say(replyTo, ircHelpText[1])
+		return
+	}
+	gm := game.NewGame(gameID, game.Player{
+		ID: challengerID,
+	}, game.Player{
+		ID: challengedID,
+	})
+	gm.WorkspaceID = workspaceID
+	gm.CreatedBy = nick
+	gm.Start()
+	if err := b.GameStorage.StoreGame(gameID, gm); err != nil {
+		b.say(replyTo, err.Error())
+		return
+	}
+	b.sayBoard(replyTo, gm, fmt.Sprintf("Game %v vs. %v started. %v", ircMentions(gm.Players[game.White]), ircMentions(gm.Players[game.Black]), ircTurnText(gm)))
+}
+
+func (b *IRCBot) handleMoveCommand(gm *game.Game, nick string, moveCommand *MoveCommand, replyTo []string) {
+	var chessMove *chess.Move
+	gm, err := game.Update(b.GameStorage, gm, func(gm *game.Game) error {
+		var err error
+		chessMove, err = gm.MoveAs(nick, moveCommand.LAN)
+		return err
+	})
+	if err != nil {
+		b.say(replyTo, err.Error())
+		return
+	}
+	if outcome := gm.Outcome(); outcome != chess.NoOutcome {
+		b.displayEndGame(gm, replyTo)
+		return
+	}
+	b.sayBoard(replyTo, gm, fmt.Sprintf("%v - %v", chessMove, ircTurnText(gm)))
+}
+
+func (b *IRCBot) handleResignCommand(gm *game.Game, nick string, replyTo []string) {
+	if _, err := gm.PlayerByID(nick); err != nil {
+		b.say(replyTo, "I couldn't find you as part of this game.")
+		return
+	}
+	gm, err := game.Update(b.GameStorage, gm, func(gm *game.Game) error {
+		return gm.ResignAs(nick)
+	})
+	if err != nil {
+		b.say(replyTo, err.Error())
+		return
+	}
+	b.displayEndGame(gm, replyTo)
+}
+
+func (b *IRCBot) handleTakebackCommand(gm *game.Game, nick string, replyTo []string) {
+	if _, err := gm.PlayerByID(nick); err != nil {
+		b.say(replyTo, "I couldn't find you as part of this game.")
+		return
+	}
+	gm, err := game.Update(b.GameStorage, gm, func(gm *game.Game) error {
+		if _, err := gm.TakebackAs(nick); err != nil {
+			return fmt.Errorf("Take back request failed: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		b.say(replyTo, err.Error())
+		return
+	}
+	b.sayBoard(replyTo, gm, fmt.Sprintf("%v requested a take back - %v", nick, ircTurnText(gm)))
+}
+
+func (b *IRCBot) displayEndGame(gm *game.Game, replyTo []string) {
+	b.sayBoard(replyTo, gm, strings.Replace(strings.Replace(gm.ResultText(), "<@", "", 1), ">", "", 1))
+	for _, line := range strings.Split(gm.Export(), "\n") {
+		if line != "" {
+			b.say(replyTo, line)
+		}
+	}
+}
+
+func (b *IRCBot) sayBoard(replyTo []string, gm *game.Game, text string) {
+	for _, line := range strings.Split(gm.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			b.say(replyTo, line)
+		}
+	}
+	b.say(replyTo, text)
+	if b.LinkRenderer == nil {
+		return
+	}
+	for _, target := range replyTo {
+		orientation := OrientationTurn
+		// Private messages show the board from the recipient's side
+		if !strings.HasPrefix(target, "#") {
+			orientation = OrientationPlayer
+		}
+		if link, err := b.LinkRenderer.CreateOrientedLink(gm, boardInverted(gm, target, orientation)); err == nil {
+			b.say([]string{target}, link.String())
+		}
+	}
+}
+
+func ircMentions(player game.Player) string {
+	return strings.Join(player.Members(), " ")
+}
+
+func ircTurnText(gm *game.Game) string {
+	return fmt.Sprintf("%v to move (%v)", gm.Turn(), ircMentions(gm.TurnPlayer()))
+}
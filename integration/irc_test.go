@@ -0,0 +1,115 @@
+package integration_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/notnil/chess"
+)
+
+type fakeIRCServer struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (f *fakeIRCServer) send(line string) {
+	fmt.Fprintf(f.conn, "%v\r\n", line)
+}
+
+// expect reads lines sent by the bot until one contains the given text
+func (f *fakeIRCServer) expect(text string) string {
+	f.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		line, err := f.reader.ReadString('\n')
+		if err != nil {
+			f.t.Fatalf("expected %q from the bot: %v", text, err)
+		}
+		if strings.Contains(line, text) {
+			return line
+		}
+	}
+}
+
+func TestIRCChannelGame(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	store := game.NewMemoryStore()
+	linkRenderer := rendering.NewRenderLink("http://localhost", "key")
+	bot := integration.NewIRCBot("chessbot", []string{"#chess"}, store, &linkRenderer)
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bot.Run(conn)
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server := &fakeIRCServer{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	server.expect("NICK chessbot")
+	server.send(":irc.local 001 chessbot :Welcome")
+	server.expect("JOIN #chess")
+	server.send("PING :irc.local")
+	server.expect("PONG :irc.local")
+
+	server.send(":alice!a@localhost PRIVMSG #chess :!new_game alice : bob")
+	server.expect("PRIVMSG #chess : A B C D E F G H")
+	server.expect("to move")
+	server.expect("PRIVMSG #chess :http://localhost/board?")
+	games, err := store.ListGames("irc:#chess")
+	if err != nil || len(games) != 1 {
+		t.Fatalf("expected a game of the channel, got %v %v", games, err)
+	}
+	gm := games[0]
+	white := gm.Players[game.White].Members()[0]
+	black := gm.Players[game.Black].Members()[0]
+
+	server.send(fmt.Sprintf(":%v!b@localhost PRIVMSG #chess :!move e7e5", black))
+	server.expect("Please wait for your turn.")
+	server.send(fmt.Sprintf(":%v!a@localhost PRIVMSG #chess :!move e2e4", white))
+	line := server.expect("e2e4 - Black to move")
+	if !strings.Contains(line, black) {
+		t.Errorf("expected the black player to be mentioned, got %v", line)
+	}
+
+	server.send(":carol!c@localhost PRIVMSG #chess :!new_game carol : dave")
+	server.expect("A game is already in progress here.")
+	server.send(fmt.Sprintf(":%v!b@localhost PRIVMSG #chess :!resign", black))
+	server.expect("Congratulations")
+	events, err := store.Events(gm.ID)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("expected the challenge, the move and the resignation, got %v %v", events, err)
+	}
+	for i, actorID := range []string{"alice", white, black} {
+		if events[i].ActorID != actorID {
+			t.Errorf("expected %v to be recorded as the actor of %v, got %v", actorID, events[i].Type, events[i].ActorID)
+		}
+	}
+	server.send(":carol!c@localhost PRIVMSG #chess :!new_game carol : dave")
+	server.expect("started")
+	server.send(":carol!c@localhost PRIVMSG #chess :!board")
+	server.expect("to move")
+	games, _ = store.ListGames("irc:#chess")
+	if len(games) != 2 || games[0].ID == games[1].ID {
+		t.Fatalf("expected a second game of the channel, got %v", games)
+	}
+	if games[0].Outcome() == chess.NoOutcome || games[1].Outcome() != chess.NoOutcome {
+		t.Errorf("expected the first game to be over and the second to be played, got %v and %v", games[0].Outcome(), games[1].Outcome())
+	}
+}
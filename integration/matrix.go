@@ -0,0 +1,414 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// MatrixHandler will respond to all Matrix application service transactions
+// Games are tied to a room thread (m.thread relation), the message that started the game being the thread root.
+// Encrypted rooms are not supported, events in them are ignored.
+type MatrixHandler struct {
+	HomeserverURL string
+	ASToken       string
+	HSToken       string
+	UserID        string
+	HTTPClient    *http.Client
+	GameStorage   game.GameStorage
+	RenderBoard   func(w io.Writer, gm *game.Game) error
+	// TransactionTTL is how long a transaction ID is remembered to ignore retries of the homeserver
+	TransactionTTL time.Duration
+
+	mu           sync.Mutex
+	transactions map[string]time.Time
+	// received lists the remembered transaction IDs, oldest first
+	received []string
+}
+
+// maxMatrixTransactions bounds the transaction IDs remembered regardless of their age
+const maxMatrixTransactions = 10000
+
+// NewMatrixHandler returns an application service transaction handler acting as the given bot user
+func NewMatrixHandler(homeserverURL string, asToken string, hsToken string, userID string, store game.GameStorage, renderBoard func(w io.Writer, gm *game.Game) error) *MatrixHandler {
+	return &MatrixHandler{
+		HomeserverURL:  strings.TrimRight(homeserverURL, "/"),
+		ASToken:        asToken,
+		HSToken:        hsToken,
+		UserID:         userID,
+		GameStorage:    store,
+		RenderBoard:    renderBoard,
+		TransactionTTL: time.Hour,
+		transactions:   make(map[string]time.Time, 10),
+	}
+}
+
+// seen reports whether a transaction was already received, remembering it otherwise.
+// Expired transaction IDs are forgotten first.
+func (m *MatrixHandler) seen(txnID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for len(m.received) > 0 && (len(m.received) >= maxMatrixTransactions || now.Sub(m.transactions[m.received[0]]) > m.TransactionTTL) {
+		delete(m.transactions, m.received[0])
+		m.received = m.received[1:]
+	}
+	if _, ok := m.transactions[txnID]; ok {
+		return true
+	}
+	m.transactions[txnID] = now
+	m.received = append(m.received, txnID)
+	return false
+}
+
+var matrixCommandPatterns = []CommandPattern{
+	{
+		Type:    Challenge,
+		Pattern: regexp.MustCompile("^!chess new_game (.*)$"),
+	},
+	{
+		Type:    Move,
+		Pattern: regexp.MustCompile("^!chess .*([a-h][1-8][a-h][1-8][qnrb]?).*$"),
+	},
+	{
+		Type:    Resign,
+		Pattern: regexp.MustCompile("^!chess.*resign.*$"),
+	},
+	{
+		Type:    Takeback,
+		Pattern: regexp.MustCompile("^!chess.*take\\s?back.*$"),
+	},
+	{
+		Type:    Help,
+		Pattern: regexp.MustCompile("^!chess.*help.*$"),
+	},
+}
+
+var matrixCommandParser = NewCommandParser(matrixCommandPatterns)
+
+const matrixHelpText = `You can use ChessBot to play Chess with other teammates.
+Start a new game with "!chess new_game @p1:example.org @p2:example.org vs @p3:example.org", the two teams are separated by "vs".
+Make a move in the game thread with "!chess d2d4", resign with "!chess resign" or take back your last move with "!chess takeback".`
+
+type matrixTransaction struct {
+	Events []matrixEvent `json:"events"`
+}
+
+type matrixEvent struct {
+	Type     string          `json:"type"`
+	EventID  string          `json:"event_id"`
+	RoomID   string          `json:"room_id"`
+	Sender   string          `json:"sender"`
+	StateKey *string         `json:"state_key"`
+	Content  json.RawMessage `json:"content"`
+}
+
+type matrixRelation struct {
+	RelType string `json:"rel_type"`
+	EventID string `json:"event_id"`
+}
+
+type matrixMessageContent struct {
+	MsgType   string          `json:"msgtype"`
+	Body      string          `json:"body"`
+	RelatesTo *matrixRelation `json:"m.relates_to"`
+}
+
+type matrixMemberContent struct {
+	Membership string `json:"membership"`
+}
+
+func (m *MatrixHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("access_token")
+	}
+	if m.HSToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(m.HSToken)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var transaction matrixTransaction
+	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	txnID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if !m.seen(txnID) {
+		for _, event := range transaction.Events {
+			m.handleEvent(event)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
+
+func (m *MatrixHandler) handleEvent(event matrixEvent) {
+	if event.Sender == m.UserID {
+		return
+	}
+	switch event.Type {
+	case "m.room.member":
+		var content matrixMemberContent
+		json.Unmarshal(event.Content, &content)
+		if event.StateKey != nil && *event.StateKey == m.UserID && content.Membership == "invite" {
+			if err := m.call(http.MethodPost, "/_matrix/client/v3/rooms/"+url.PathEscape(event.RoomID)+"/join", "application/json", strings.NewReader("{}"), nil); err != nil {
+				log.Println(err)
+			}
+		}
+	case "m.room.encrypted":
+		log.Printf("Ignoring encrypted event %v in %v", event.EventID, event.RoomID)
+	case "m.room.message":
+		var content matrixMessageContent
+		if err := json.Unmarshal(event.Content, &content); err != nil {
+			log.Println(err)
+			return
+		}
+		threadRoot := event.EventID
+		if content.RelatesTo != nil && content.RelatesTo.RelType == "m.thread" {
+			threadRoot = content.RelatesTo.EventID
+		}
+		m.handleMessage(event, threadRoot, content.Body)
+	}
+}
+
+func matrixGameID(roomID string, threadRoot string) string {
+	return fmt.Sprintf("matrix:%v:%v", roomID, threadRoot)
+}
+
+func (m *MatrixHandler) handleMessage(event matrixEvent, threadRoot string, body string) {
+	gameID := matrixGameID(event.RoomID, threadRoot)
+	matched := matrixCommandParser.ParseInput(strings.TrimSpace(body))
+	switch matched.Type {
+	case Challenge:
+		m.handleChallengeCommand(gameID, event, threadRoot, matched.Params[0])
+	case Move:
+		moveCommand, _ := matched.ToMove()
+		m.handleMoveCommand(gameID, event, threadRoot, moveCommand)
+	case Resign:
+		m.handleResignCommand(gameID, event, threadRoot)
+	case Takeback:
+		m.handleTakebackCommand(gameID, event, threadRoot)
+	case Help:
+		m.sendText(event.RoomID, threadRoot, matrixHelpText)
+	}
+}
+
+// matrixTeams splits the challenge parameters on "vs", as Matrix user IDs already contain ":"
+func matrixTeams(params string) (string, string) {
+	teams := []string{" ", " "}
+	current := 0
+	for _, param := range strings.Fields(params) {
+		if param == "vs" {
+			current = 1
+			continue
+		}
+		teams[current] += param + " "
+	}
+	return teams[0], teams[1]
+}
+
+func (m *MatrixHandler) handleChallengeCommand(gameID string, event matrixEvent, threadRoot string, params string) {
+	if _, err := m.GameStorage.RetrieveGame(gameID); err == nil {
+		m.sendText(event.RoomID, threadRoot, "A game already exists in this thread. Try making a new thread.")
+		return
+	}
+	challengerID, challengedID := matrixTeams(params)
+	if strings.TrimSpace(challengerID) == "" || strings.TrimSpace(challengedID) == "" {
+		m.sendText(event.RoomID, threadRoot, matrixHelpText)
+		return
+	}
+	gm := game.NewGame(gameID, game.Player{
+		ID: challengerID,
+	}, game.Player{
+		ID: challengedID,
+	})
+	gm.CreatedBy = event.Sender
+	gm.Start()
+	if err := m.GameStorage.StoreGame(gameID, gm); err != nil {
+		m.sendText(event.RoomID, threadRoot, err.Error())
+		return
+	}
+	m.sendText(event.RoomID, threadRoot, fmt.Sprintf("Game %v vs. %v started, here is the opening.", matrixMentions(gm.Players[game.White]), matrixMentions(gm.Players[game.Black])))
+	m.sendBoard(event.RoomID, threadRoot, gm, matrixTurnText(gm))
+}
+
+func (m *MatrixHandler) handleMoveCommand(gameID string, event matrixEvent, threadRoot string, moveCommand *MoveCommand) {
+	gm, err := m.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	var chessMove *chess.Move
+	gm, err = game.Update(m.GameStorage, gm, func(gm *game.Game) error {
+		var err error
+		chessMove, err = gm.MoveAs(event.Sender, moveCommand.LAN)
+		return err
+	})
+	if err != nil {
+		m.sendText(event.RoomID, threadRoot, err.Error())
+		return
+	}
+	if outcome := gm.Outcome(); outcome != chess.NoOutcome {
+		m.displayEndGame(event.RoomID, threadRoot, gm)
+		return
+	}
+	m.sendBoard(event.RoomID, threadRoot, gm, fmt.Sprintf("%v - %v", chessMove, matrixTurnText(gm)))
+}
+
+func (m *MatrixHandler) handleResignCommand(gameID string, event matrixEvent, threadRoot string) {
+	gm, err := m.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := gm.PlayerByID(event.Sender); err != nil {
+		m.sendText(event.RoomID, threadRoot, "I couldn't find you as part of this game.")
+		return
+	}
+	gm, err = game.Update(m.GameStorage, gm, func(gm *game.Game) error {
+		return gm.ResignAs(event.Sender)
+	})
+	if err != nil {
+		m.sendText(event.RoomID, threadRoot, err.Error())
+		return
+	}
+	m.displayEndGame(event.RoomID, threadRoot, gm)
+}
+
+func (m *MatrixHandler) handleTakebackCommand(gameID string, event matrixEvent, threadRoot string) {
+	gm, err := m.GameStorage.RetrieveGame(gameID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if _, err := gm.PlayerByID(event.Sender); err != nil {
+		m.sendText(event.RoomID, threadRoot, "I couldn't find you as part of this game.")
+		return
+	}
+	gm, err = game.Update(m.GameStorage, gm, func(gm *game.Game) error {
+		if _, err := gm.TakebackAs(event.Sender); err != nil {
+			return fmt.Errorf("Take back request failed: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		m.sendText(event.RoomID, threadRoot, err.Error())
+		return
+	}
+	m.sendBoard(event.RoomID, threadRoot, gm, fmt.Sprintf("%v requested a take back - %v", event.Sender, matrixTurnText(gm)))
+}
+
+func (m *MatrixHandler) displayEndGame(roomID string, threadRoot string, gm *game.Game) {
+	m.sendBoard(roomID, threadRoot, gm, gm.ResultText())
+	m.sendText(roomID, threadRoot, gm.Export())
+}
+
+func matrixMentions(player game.Player) string {
+	return strings.Join(player.Members(), " ")
+}
+
+func matrixTurnText(gm *game.Game) string {
+	return fmt.Sprintf("%v to move (%v)", gm.Turn(), matrixMentions(gm.TurnPlayer()))
+}
+
+func matrixThreadRelation(threadRoot string) map[string]interface{} {
+	return map[string]interface{}{
+		"rel_type":        "m.thread",
+		"event_id":        threadRoot,
+		"is_falling_back": true,
+		"m.in_reply_to": map[string]string{
+			"event_id": threadRoot,
+		},
+	}
+}
+
+func (m *MatrixHandler) sendText(roomID string, threadRoot string, text string) {
+	m.sendEvent(roomID, map[string]interface{}{
+		"msgtype":      "m.notice",
+		"body":         text,
+		"m.relates_to": matrixThreadRelation(threadRoot),
+	})
+}
+
+func (m *MatrixHandler) sendBoard(roomID string, threadRoot string, gm *game.Game, caption string) {
+	image := new(bytes.Buffer)
+	if err := m.RenderBoard(image, gm); err != nil {
+		log.Println(err)
+		m.sendText(roomID, threadRoot, caption)
+		return
+	}
+	size := image.Len()
+	var upload struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := m.call(http.MethodPost, "/_matrix/media/v3/upload?filename="+url.QueryEscape(gm.ID+".png"), "image/png", image, &upload); err != nil {
+		log.Println(err)
+		m.sendText(roomID, threadRoot, caption)
+		return
+	}
+	m.sendText(roomID, threadRoot, caption)
+	m.sendEvent(roomID, map[string]interface{}{
+		"msgtype": "m.image",
+		"body":    "board.png",
+		"url":     upload.ContentURI,
+		"info": map[string]interface{}{
+			"mimetype": "image/png",
+			"size":     size,
+		},
+		"m.relates_to": matrixThreadRelation(threadRoot),
+	})
+}
+
+func (m *MatrixHandler) sendEvent(roomID string, content map[string]interface{}) {
+	body, err := json.Marshal(content)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	txnID := fmt.Sprintf("chessbot%v", time.Now().UnixNano())
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%v/send/m.room.message/%v", url.PathEscape(roomID), txnID)
+	if err := m.call(http.MethodPut, path, "application/json", bytes.NewReader(body), nil); err != nil {
+		log.Println(err)
+	}
+}
+
+func (m *MatrixHandler) call(method string, path string, contentType string, body io.Reader, result interface{}) error {
+	client := m.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(method, m.HomeserverURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.ASToken)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("homeserver responded with an unexpected status code to %v: %v", path, resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
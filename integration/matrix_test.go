@@ -0,0 +1,134 @@
+package integration_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+)
+
+type fakeHomeserver struct {
+	mu      sync.Mutex
+	uploads int
+	events  []string
+}
+
+func (f *fakeHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer as_token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/_matrix/media/v3/upload"):
+		f.uploads++
+		w.Write([]byte(`{"content_uri":"mxc://localhost/board"}`))
+	case strings.Contains(r.URL.Path, "/send/m.room.message/"):
+		f.events = append(f.events, string(body))
+		w.Write([]byte(`{"event_id":"$reply"}`))
+	default:
+		w.Write([]byte(`{}`))
+	}
+}
+
+func sendTransaction(t *testing.T, handler http.Handler, txnID string, events string) {
+	req := httptest.NewRequest(http.MethodPut, "/_matrix/app/v1/transactions/"+txnID, bytes.NewBufferString(`{"events":[`+events+`]}`))
+	req.Header.Set("Authorization", "Bearer hs_token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", rec.Code)
+	}
+}
+
+func TestMatrixGameInThread(t *testing.T) {
+	homeserver := &fakeHomeserver{}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	store := game.NewMemoryStore()
+	handler := integration.NewMatrixHandler(server.URL, "as_token", "hs_token", "@chessbot:localhost", store, func(w io.Writer, gm *game.Game) error {
+		_, err := w.Write([]byte("png"))
+		return err
+	})
+
+	sendTransaction(t, handler, "1", `{"type":"m.room.message","event_id":"$root","room_id":"!room:localhost","sender":"@alice:localhost","content":{"msgtype":"m.text","body":"!chess new_game @alice:localhost vs @bob:localhost"}}`)
+	gm, err := store.RetrieveGame("matrix:!room:localhost:$root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if homeserver.uploads != 1 {
+		t.Errorf("expected the board to be uploaded to the media repository, got %v uploads", homeserver.uploads)
+	}
+	last := homeserver.events[len(homeserver.events)-1]
+	if !strings.Contains(last, `"url":"mxc://localhost/board"`) || !strings.Contains(last, `"rel_type":"m.thread"`) {
+		t.Errorf("expected a threaded image event, got %v", last)
+	}
+
+	white := gm.Players[game.White].Members()[0]
+	move := `{"type":"m.room.message","event_id":"$move","room_id":"!room:localhost","sender":"%v","content":{"msgtype":"m.text","body":"!chess e2e4","m.relates_to":{"rel_type":"m.thread","event_id":"$root"}}}`
+	sendTransaction(t, handler, "2", fmt.Sprintf(move, white))
+	gm, _ = store.RetrieveGame("matrix:!room:localhost:$root")
+	if gm.LastMove() == nil || gm.LastMove().String() != "e2e4" {
+		t.Errorf("expected e2e4 to be played, got %v", gm.PGN())
+	}
+	// Homeservers retry transactions, a replay must not be processed twice.
+	sent := len(homeserver.events)
+	sendTransaction(t, handler, "2", fmt.Sprintf(move, white))
+	if len(homeserver.events) != sent {
+		t.Errorf("expected the replayed transaction to be ignored, got %v new events", len(homeserver.events)-sent)
+	}
+}
+
+func TestMatrixForgetsExpiredTransactions(t *testing.T) {
+	homeserver := &fakeHomeserver{}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	handler := integration.NewMatrixHandler(server.URL, "as_token", "hs_token", "@chessbot:localhost", game.NewMemoryStore(), nil)
+	handler.TransactionTTL = 100 * time.Millisecond
+	help := `{"type":"m.room.message","event_id":"$help","room_id":"!room:localhost","sender":"@alice:localhost","content":{"msgtype":"m.text","body":"!chess help"}}`
+
+	sendTransaction(t, handler, "1", help)
+	sendTransaction(t, handler, "1", help)
+	if len(homeserver.events) != 1 {
+		t.Fatalf("expected the retry to be ignored, got %v events", len(homeserver.events))
+	}
+	time.Sleep(150 * time.Millisecond)
+	sendTransaction(t, handler, "2", help)
+	sendTransaction(t, handler, "1", help)
+	if len(homeserver.events) != 3 {
+		t.Errorf("expected the expired transaction to be forgotten, got %v events", len(homeserver.events))
+	}
+}
+
+func TestMatrixRejectsInvalidToken(t *testing.T) {
+	for _, test := range []struct {
+		configured string
+		sent       string
+	}{
+		{"hs_token", "wrong"},
+		{"hs_token", ""},
+		{"", ""},
+	} {
+		handler := integration.NewMatrixHandler("http://localhost", "as_token", test.configured, "@chessbot:localhost", game.NewMemoryStore(), nil)
+		req := httptest.NewRequest(http.MethodPut, "/_matrix/app/v1/transactions/1", bytes.NewBufferString(`{"events":[]}`))
+		if test.sent != "" {
+			req.Header.Set("Authorization", "Bearer "+test.sent)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("expected status 403 for token %q with %q configured, got %v", test.sent, test.configured, rec.Code)
+		}
+	}
+}
@@ -2,18 +2,26 @@ package integration
 
 import (
 	"fmt"
+
+	"github.com/cjsaylor/chessbot/rendering"
 )
 
-// MemoryStore implements the Auth storage interfaces and holds all state in memory
+// MemoryStore implements the Auth and Preference storage interfaces and holds all state in memory
 // Once the MemoryStore instance is released, all data in that storage is lost
 type MemoryStore struct {
 	authorizations map[string]string
+	orientations   map[string]Orientation
+	styles         map[string]rendering.Style
+	boardFormats   map[string]BoardFormat
 }
 
 // NewMemoryStore returns a MemoryStore pointer
 func NewMemoryStore() *MemoryStore {
 	store := MemoryStore{
 		authorizations: make(map[string]string, 10),
+		orientations:   make(map[string]Orientation, 10),
+		styles:         make(map[string]rendering.Style, 10),
+		boardFormats:   make(map[string]BoardFormat, 10),
 	}
 	return &store
 }
@@ -32,3 +40,48 @@ func (m *MemoryStore) GetAuthToken(teamID string) (string, error) {
 	}
 	return token, nil
 }
+
+// StoreOrientation stores the preferred board orientation of a user
+func (m *MemoryStore) StoreOrientation(userID string, orientation Orientation) error {
+	m.orientations[userID] = orientation
+	return nil
+}
+
+// GetOrientation retrieves the preferred board orientation of a user
+func (m *MemoryStore) GetOrientation(userID string) (Orientation, error) {
+	orientation, ok := m.orientations[userID]
+	if !ok {
+		return "", fmt.Errorf("No orientation preference for user %v", userID)
+	}
+	return orientation, nil
+}
+
+// StoreStyle stores the board style chosen by a user or workspace
+func (m *MemoryStore) StoreStyle(ID string, style rendering.Style) error {
+	m.styles[ID] = style
+	return nil
+}
+
+// GetStyle retrieves the board style chosen by a user or workspace
+func (m *MemoryStore) GetStyle(ID string) (rendering.Style, error) {
+	style, ok := m.styles[ID]
+	if !ok {
+		return style, fmt.Errorf("No style chosen by %v", ID)
+	}
+	return style, nil
+}
+
+// StoreBoardFormat stores how boards are posted in a workspace
+func (m *MemoryStore) StoreBoardFormat(workspaceID string, format BoardFormat) error {
+	m.boardFormats[workspaceID] = format
+	return nil
+}
+
+// GetBoardFormat retrieves how boards are posted in a workspace
+func (m *MemoryStore) GetBoardFormat(workspaceID string) (BoardFormat, error) {
+	format, ok := m.boardFormats[workspaceID]
+	if !ok {
+		return "", fmt.Errorf("No board format chosen by workspace %v", workspaceID)
+	}
+	return format, nil
+}
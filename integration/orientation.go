@@ -0,0 +1,36 @@
+package integration
+
+import (
+	"github.com/cjsaylor/chessbot/game"
+)
+
+// Orientation is a user's preference for which side of the board is shown at the bottom
+type Orientation string
+
+// OrientationTurn shows the board from the side of the player to move.
+// OrientationWhite and OrientationBlack always show the board from that side.
+// OrientationPlayer shows the board from the viewer's own side when they are part of the game.
+const (
+	OrientationTurn   Orientation = "turn"
+	OrientationWhite  Orientation = "white"
+	OrientationBlack  Orientation = "black"
+	OrientationPlayer Orientation = "player"
+)
+
+// boardInverted determines whether the viewer should see the board with black at the bottom
+func boardInverted(gm *game.Game, viewerID string, orientation Orientation) bool {
+	switch orientation {
+	case OrientationWhite:
+		return false
+	case OrientationBlack:
+		return true
+	case OrientationPlayer:
+		if gm.Players[game.Black].HasMember(viewerID) {
+			return true
+		}
+		if gm.Players[game.White].HasMember(viewerID) {
+			return false
+		}
+	}
+	return gm.Turn() == game.Black
+}
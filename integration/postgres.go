@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"database/sql"
+
+	"github.com/cjsaylor/chessbot/migration"
+	"github.com/cjsaylor/chessbot/rendering"
+
+	// import postgres package for use with the sql interface
+	_ "github.com/lib/pq"
+)
+
+// PostgresMigrations creates and evolves the authorization and preference tables on Postgres
+var PostgresMigrations = migration.Set{
+	Component: "integration",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create authorizations and preferences",
+			Up: `
+				CREATE TABLE authorizations (
+					id text PRIMARY KEY,
+					token text NOT NULL
+				);
+				CREATE TABLE preferences (
+					user_id text PRIMARY KEY,
+					orientation text NOT NULL
+				);
+				CREATE TABLE styles (
+					id text PRIMARY KEY,
+					theme text NOT NULL,
+					pieces text NOT NULL
+				);
+				CREATE TABLE board_formats (
+					workspace_id text PRIMARY KEY,
+					format text NOT NULL
+				);
+			`,
+		},
+	},
+}
+
+// PostgresStore is an implementation of the AuthStorage and PreferenceStorage interfaces that persists using Postgres
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore migrates the authorization and preference tables of the database to the latest version
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	if err := migration.Apply(db, migration.Postgres, PostgresMigrations); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// StoreAuthToken stores the oauth token granted by slack user for a given team ID
+func (s *PostgresStore) StoreAuthToken(teamID string, oauthToken string) error {
+	_, err := s.db.Exec(`
+		insert into authorizations (id, token) values ($1, $2)
+		on conflict(id) do update set token = $2
+	`, teamID, oauthToken)
+	return err
+}
+
+// GetAuthToken retrieves an oauth token for use with slack given a team ID
+func (s *PostgresStore) GetAuthToken(teamID string) (string, error) {
+	var token string
+	err := s.db.QueryRow("select token from authorizations where id = $1", teamID).Scan(&token)
+	return token, err
+}
+
+// StoreOrientation stores the preferred board orientation of a user
+func (s *PostgresStore) StoreOrientation(userID string, orientation Orientation) error {
+	_, err := s.db.Exec(`
+		insert into preferences (user_id, orientation) values ($1, $2)
+		on conflict(user_id) do update set orientation = $2
+	`, userID, string(orientation))
+	return err
+}
+
+// GetOrientation retrieves the preferred board orientation of a user
+func (s *PostgresStore) GetOrientation(userID string) (Orientation, error) {
+	var orientation string
+	err := s.db.QueryRow("select orientation from preferences where user_id = $1", userID).Scan(&orientation)
+	return Orientation(orientation), err
+}
+
+// StoreStyle stores the board style chosen by a user or workspace
+func (s *PostgresStore) StoreStyle(ID string, style rendering.Style) error {
+	_, err := s.db.Exec(`
+		insert into styles (id, theme, pieces) values ($1, $2, $3)
+		on conflict(id) do update set theme = $2, pieces = $3
+	`, ID, style.Theme, style.Pieces)
+	return err
+}
+
+// GetStyle retrieves the board style chosen by a user or workspace
+func (s *PostgresStore) GetStyle(ID string) (rendering.Style, error) {
+	style := rendering.Style{}
+	err := s.db.QueryRow("select theme, pieces from styles where id = $1", ID).Scan(&style.Theme, &style.Pieces)
+	return style, err
+}
+
+// StoreBoardFormat stores how boards are posted in a workspace
+func (s *PostgresStore) StoreBoardFormat(workspaceID string, format BoardFormat) error {
+	_, err := s.db.Exec(`
+		insert into board_formats (workspace_id, format) values ($1, $2)
+		on conflict(workspace_id) do update set format = $2
+	`, workspaceID, string(format))
+	return err
+}
+
+// GetBoardFormat retrieves how boards are posted in a workspace
+func (s *PostgresStore) GetBoardFormat(workspaceID string) (BoardFormat, error) {
+	var format string
+	err := s.db.QueryRow("select format from board_formats where workspace_id = $1", workspaceID).Scan(&format)
+	return BoardFormat(format), err
+}
@@ -3,16 +3,61 @@ package integration
 import (
 	"database/sql"
 
+	"github.com/cjsaylor/chessbot/migration"
+	"github.com/cjsaylor/chessbot/rendering"
+
 	// import sqlite package for use with the sql interface
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const authTableCreation = `
-	CREATE TABLE IF NOT EXISTS authorizations (
-		id text PRIMARY KEY,
-		token text
-	);
-`
+// SqliteMigrations creates and evolves the authorization and preference tables on sqlite.
+// The first migrations tolerate databases created before the schema was versioned.
+var SqliteMigrations = migration.Set{
+	Component: "integration",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create authorizations",
+			Up: `
+				CREATE TABLE IF NOT EXISTS authorizations (
+					id text PRIMARY KEY,
+					token text
+				);
+			`,
+		},
+		{
+			Version:     2,
+			Description: "create orientation preferences",
+			Up: `
+				CREATE TABLE IF NOT EXISTS preferences (
+					user_id text PRIMARY KEY,
+					orientation text
+				);
+			`,
+		},
+		{
+			Version:     3,
+			Description: "create styles",
+			Up: `
+				CREATE TABLE IF NOT EXISTS styles (
+					id text PRIMARY KEY,
+					theme text,
+					pieces text
+				);
+			`,
+		},
+		{
+			Version:     4,
+			Description: "create board formats",
+			Up: `
+				CREATE TABLE IF NOT EXISTS board_formats (
+					workspace_id text PRIMARY KEY,
+					format text
+				);
+			`,
+		},
+	},
+}
 
 // SqliteStore is an implementation of GameStorage and ChallengeStorage interfaces that persists using sqlite3
 type SqliteStore struct {
@@ -20,9 +65,9 @@ type SqliteStore struct {
 	db   *sql.DB
 }
 
-// NewSqliteStore creates (if not exists) the DB file and structure at the path specified
-// It implements the AuthStorage interface and is intended as a suitable
-// perminent storage of oauth tokens
+// NewSqliteStore creates (if not exists) the DB file at the path specified and migrates its structure
+// It implements the AuthStorage and PreferenceStorage interfaces and is intended as a suitable
+// perminent storage of oauth tokens and user preferences
 func NewSqliteStore(path string) (*SqliteStore, error) {
 	store := SqliteStore{
 		path: path,
@@ -31,7 +76,7 @@ func NewSqliteStore(path string) (*SqliteStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err = db.Exec(authTableCreation); err != nil {
+	if err = migration.Apply(db, migration.Sqlite, SqliteMigrations); err != nil {
 		return nil, err
 	}
 	store.db = db
@@ -58,3 +103,118 @@ func (s *SqliteStore) GetAuthToken(teamID string) (string, error) {
 	err := row.Scan(&token)
 	return token, err
 }
+
+// StoreOrientation stores the preferred board orientation of a user
+func (s *SqliteStore) StoreOrientation(userID string, orientation Orientation) error {
+	stmt, _ := s.db.Prepare(`
+		insert into preferences (user_id, orientation) values (?, ?)
+		on conflict(user_id) do update set orientation = ?
+	`)
+	defer stmt.Close()
+	_, err := stmt.Exec(userID, string(orientation), string(orientation))
+	return err
+}
+
+// GetOrientation retrieves the preferred board orientation of a user
+func (s *SqliteStore) GetOrientation(userID string) (Orientation, error) {
+	stmt, _ := s.db.Prepare("select orientation from preferences where user_id = ?")
+	defer stmt.Close()
+	var orientation string
+	row := stmt.QueryRow(userID)
+	err := row.Scan(&orientation)
+	return Orientation(orientation), err
+}
+
+// StoreStyle stores the board style chosen by a user or workspace
+func (s *SqliteStore) StoreStyle(ID string, style rendering.Style) error {
+	stmt, _ := s.db.Prepare(`
+		insert into styles (id, theme, pieces) values (?, ?, ?)
+		on conflict(id) do update set theme = ?, pieces = ?
+	`)
+	defer stmt.Close()
+	_, err := stmt.Exec(ID, style.Theme, style.Pieces, style.Theme, style.Pieces)
+	return err
+}
+
+// GetStyle retrieves the board style chosen by a user or workspace
+func (s *SqliteStore) GetStyle(ID string) (rendering.Style, error) {
+	stmt, _ := s.db.Prepare("select theme, pieces from styles where id = ?")
+	defer stmt.Close()
+	style := rendering.Style{}
+	row := stmt.QueryRow(ID)
+	err := row.Scan(&style.Theme, &style.Pieces)
+	return style, err
+}
+
+// StoreBoardFormat stores how boards are posted in a workspace
+func (s *SqliteStore) StoreBoardFormat(workspaceID string, format BoardFormat) error {
+	stmt, _ := s.db.Prepare(`
+		insert into board_formats (workspace_id, format) values (?, ?)
+		on conflict(workspace_id) do update set format = ?
+	`)
+	defer stmt.Close()
+	_, err := stmt.Exec(workspaceID, string(format), string(format))
+	return err
+}
+
+// GetBoardFormat retrieves how boards are posted in a workspace
+func (s *SqliteStore) GetBoardFormat(workspaceID string) (BoardFormat, error) {
+	stmt, _ := s.db.Prepare("select format from board_formats where workspace_id = ?")
+	defer stmt.Close()
+	var format string
+	row := stmt.QueryRow(workspaceID)
+	err := row.Scan(&format)
+	return BoardFormat(format), err
+}
+
+// dump returns every oauth token and preference of the store, for copying them into a bolt store
+func (s *SqliteStore) dump() (storedPreferences, error) {
+	preferences := newStoredPreferences()
+	err := s.each("select id, token from authorizations", func(values []sql.NullString) {
+		preferences.tokens[values[0].String] = values[1].String
+	})
+	if err != nil {
+		return preferences, err
+	}
+	err = s.each("select user_id, orientation from preferences", func(values []sql.NullString) {
+		preferences.orientations[values[0].String] = Orientation(values[1].String)
+	})
+	if err != nil {
+		return preferences, err
+	}
+	err = s.each("select id, theme, pieces from styles", func(values []sql.NullString) {
+		preferences.styles[values[0].String] = rendering.Style{Theme: values[1].String, Pieces: values[2].String}
+	})
+	if err != nil {
+		return preferences, err
+	}
+	err = s.each("select workspace_id, format from board_formats", func(values []sql.NullString) {
+		preferences.boardFormats[values[0].String] = BoardFormat(values[1].String)
+	})
+	return preferences, err
+}
+
+// each calls row with the columns of every row returned by the query
+func (s *SqliteStore) each(query string, row func(values []sql.NullString)) error {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		destinations := make([]interface{}, len(columns))
+		for i := range values {
+			destinations[i] = &values[i]
+		}
+		if err := rows.Scan(destinations...); err != nil {
+			return err
+		}
+		row(values)
+	}
+	return rows.Err()
+}
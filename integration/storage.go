@@ -1,7 +1,20 @@
 package integration
 
+import "github.com/cjsaylor/chessbot/rendering"
+
 // AuthStorage interface guarentees implemented mmethods for oauth token storage
 type AuthStorage interface {
 	StoreAuthToken(ID string, token string) error
 	GetAuthToken(ID string) (string, error)
 }
+
+// PreferenceStorage interface guarentees implemented methods for per user display preferences
+type PreferenceStorage interface {
+	StoreOrientation(userID string, orientation Orientation) error
+	GetOrientation(userID string) (Orientation, error)
+	// Styles are stored for users as well as for whole workspaces
+	StoreStyle(ID string, style rendering.Style) error
+	GetStyle(ID string) (rendering.Style, error)
+	StoreBoardFormat(workspaceID string, format BoardFormat) error
+	GetBoardFormat(workspaceID string) (BoardFormat, error)
+}
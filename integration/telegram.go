@@ -0,0 +1,624 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// TelegramHandler will respond to all Telegram webhook updates
+// Games are keyed by the message that started them and listed per group chat, or topic thread within that chat.
+// Players are identified by their numeric Telegram user ID.
+// Updates are refused unless they carry the SecretToken the webhook was set with.
+type TelegramHandler struct {
+	Token       string
+	APIURL      string
+	SecretToken string
+	HTTPClient  *http.Client
+	GameStorage game.GameStorage
+	RenderBoard func(w io.Writer, gm *game.Game) error
+	// Users resolves the @usernames of a challenge, a user is only known once they sent an update
+	Users *TelegramDirectory
+}
+
+// TelegramDirectory remembers the Telegram users seen in updates
+type TelegramDirectory struct {
+	mu        sync.Mutex
+	users     map[int64]telegramUser
+	usernames map[string]int64
+}
+
+// NewTelegramDirectory returns an empty TelegramDirectory pointer
+func NewTelegramDirectory() *TelegramDirectory {
+	return &TelegramDirectory{
+		users:     make(map[int64]telegramUser),
+		usernames: make(map[string]int64),
+	}
+}
+
+func (d *TelegramDirectory) remember(user *telegramUser) {
+	if d == nil || user == nil || user.ID == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous, ok := d.users[user.ID]; ok && previous.Username != "" {
+		delete(d.usernames, strings.ToLower(previous.Username))
+	}
+	d.users[user.ID] = *user
+	if user.Username != "" {
+		d.usernames[strings.ToLower(user.Username)] = user.ID
+	}
+}
+
+func (d *TelegramDirectory) lookup(username string) (int64, bool) {
+	if d == nil {
+		return 0, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ID, ok := d.usernames[strings.ToLower(username)]
+	return ID, ok
+}
+
+// name returns how a member is mentioned in messages
+func (d *TelegramDirectory) name(memberID string) string {
+	if d != nil {
+		ID, _ := strconv.ParseInt(memberID, 10, 64)
+		d.mu.Lock()
+		user, ok := d.users[ID]
+		d.mu.Unlock()
+		if ok && user.Username != "" {
+			return "@" + user.Username
+		}
+		if ok && user.FirstName != "" {
+			return user.FirstName
+		}
+	}
+	return "user " + memberID
+}
+
+var telegramCommandPatterns = []CommandPattern{
+	{
+		Type:    Challenge,
+		Pattern: regexp.MustCompile("^/new_game(?:@\\w+)? (.*)$"),
+	},
+	{
+		Type:    Move,
+		Pattern: regexp.MustCompile("^(?:/move(?:@\\w+)? )?([a-h][1-8][a-h][1-8][qnrb]?)$"),
+	},
+	{
+		Type:    Resign,
+		Pattern: regexp.MustCompile("^/resign(?:@\\w+)?$"),
+	},
+	{
+		Type:    Takeback,
+		Pattern: regexp.MustCompile("^/takeback(?:@\\w+)?$"),
+	},
+	{
+		Type:    Help,
+		Pattern: regexp.MustCompile("^/(?:help|start)(?:@\\w+)?$"),
+	},
+}
+
+var telegramCommandParser = NewCommandParser(telegramCommandPatterns)
+
+const (
+	telegramPickPrefix = "pick:"
+	telegramMovePrefix = "move:"
+	telegramBack       = "back"
+	telegramRowSize    = 4
+)
+
+type telegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *telegramMessage       `json:"message"`
+	CallbackQuery *telegramCallbackQuery `json:"callback_query"`
+}
+
+type telegramUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+type telegramChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type telegramMessage struct {
+	MessageID       int64                         `json:"message_id"`
+	MessageThreadID int64                         `json:"message_thread_id"`
+	From            *telegramUser                 `json:"from"`
+	Chat            telegramChat                  `json:"chat"`
+	Text            string                        `json:"text"`
+	Entities        []telegramEntity              `json:"entities"`
+	ReplyToMessage  *telegramMessage              `json:"reply_to_message"`
+	ReplyMarkup     *telegramInlineKeyboardMarkup `json:"reply_markup"`
+}
+
+// telegramEntity marks a part of a message text, offsets and lengths are counted in UTF-16 code units
+type telegramEntity struct {
+	Type   string        `json:"type"`
+	Offset int           `json:"offset"`
+	Length int           `json:"length"`
+	User   *telegramUser `json:"user"`
+}
+
+type telegramCallbackQuery struct {
+	ID      string           `json:"id"`
+	From    telegramUser     `json:"from"`
+	Message *telegramMessage `json:"message"`
+	Data    string           `json:"data"`
+}
+
+type telegramInlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type telegramInlineKeyboardMarkup struct {
+	InlineKeyboard [][]telegramInlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+func (t TelegramHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	secretToken := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if t.SecretToken == "" || subtle.ConstantTimeCompare([]byte(secretToken), []byte(t.SecretToken)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var update telegramUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if update.Message != nil && update.Message.From != nil {
+		t.Users.remember(update.Message.From)
+		for _, entity := range update.Message.Entities {
+			t.Users.remember(entity.User)
+		}
+		t.handleMessage(update.Message)
+	} else if update.CallbackQuery != nil && update.CallbackQuery.Message != nil {
+		t.Users.remember(&update.CallbackQuery.From)
+		t.handleCallbackQuery(update.CallbackQuery)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// telegramWorkspace is the chat, or topic thread within the chat, games are listed by
+func telegramWorkspace(message *telegramMessage) string {
+	return fmt.Sprintf("telegram:%v:%v", message.Chat.ID, message.MessageThreadID)
+}
+
+// telegramGameID keys a game by the ID of the message that started it
+func telegramGameID(message *telegramMessage, ref string) string {
+	return fmt.Sprintf("%v:%v", telegramWorkspace(message), ref)
+}
+
+// telegramGameRef is the ID of the message that started a game, carried by the buttons of its board
+func telegramGameRef(gm *game.Game) string {
+	return gm.ID[strings.LastIndex(gm.ID, ":")+1:]
+}
+
+// splitCallbackData splits the data of a button ("<game ref> <action>") into the game reference and the action
+func splitCallbackData(data string) (string, string) {
+	parts := strings.SplitN(data, " ", 2)
+	if len(parts) != 2 {
+		return "", data
+	}
+	return parts[0], parts[1]
+}
+
+func telegramMemberID(user *telegramUser) string {
+	return strconv.FormatInt(user.ID, 10)
+}
+
+func (t TelegramHandler) handleMessage(message *telegramMessage) {
+	matched := telegramCommandParser.ParseInput(strings.TrimSpace(t.resolveMentions(message)))
+	switch matched.Type {
+	case Challenge:
+		challengeCommand, _ := matched.ToChallenge()
+		t.handleChallengeCommand(challengeCommand, message)
+	case Move:
+		moveCommand, _ := matched.ToMove()
+		if gm := t.gameFor(message); gm != nil {
+			t.handleMoveCommand(gm, moveCommand.LAN, message.From, message)
+		}
+	case Resign:
+		if gm := t.gameFor(message); gm != nil {
+			t.handleResignCommand(gm, message)
+		}
+	case Takeback:
+		if gm := t.gameFor(message); gm != nil {
+			t.handleTakebackCommand(gm, message)
+		}
+	case Help:
+		t.sendMessage(message, telegramHelpText, nil)
+	}
+}
+
+// resolveMentions replaces the mentions in the text of a message with the IDs of the mentioned users.
+// Mentions of users that never sent an update are left as is.
+func (t TelegramHandler) resolveMentions(message *telegramMessage) string {
+	text := utf16.Encode([]rune(message.Text))
+	for i := len(message.Entities) - 1; i >= 0; i-- {
+		entity := message.Entities[i]
+		if entity.Offset < 0 || entity.Length < 1 || entity.Offset+entity.Length > len(text) {
+			continue
+		}
+		var userID int64
+		switch entity.Type {
+		case "text_mention":
+			if entity.User != nil {
+				userID = entity.User.ID
+			}
+		case "mention":
+			username := string(utf16.Decode(text[entity.Offset+1 : entity.Offset+entity.Length]))
+			userID, _ = t.Users.lookup(username)
+		}
+		if userID == 0 {
+			continue
+		}
+		resolved := append([]uint16{}, text[:entity.Offset]...)
+		resolved = append(resolved, utf16.Encode([]rune(strconv.FormatInt(userID, 10)))...)
+		text = append(resolved, text[entity.Offset+entity.Length:]...)
+	}
+	return string(utf16.Decode(text))
+}
+
+// gameFor finds the game a command is meant for: the game whose board or challenge the message replies to,
+// otherwise the only unfinished game of the chat the sender plays in
+func (t TelegramHandler) gameFor(message *telegramMessage) *game.Game {
+	if reply := message.ReplyToMessage; reply != nil {
+		ref := strconv.FormatInt(reply.MessageID, 10)
+		if reply.ReplyMarkup != nil && len(reply.ReplyMarkup.InlineKeyboard) > 0 && len(reply.ReplyMarkup.InlineKeyboard[0]) > 0 {
+			ref, _ = splitCallbackData(reply.ReplyMarkup.InlineKeyboard[0][0].CallbackData)
+		}
+		if gm, err := t.GameStorage.RetrieveGame(telegramGameID(message, ref)); err == nil {
+			return gm
+		}
+	}
+	lister, ok := t.GameStorage.(game.GameLister)
+	if !ok {
+		t.sendMessage(message, "Please reply to the board of your game.", nil)
+		return nil
+	}
+	games, err := lister.ListGames(telegramWorkspace(message))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	memberID := telegramMemberID(message.From)
+	playing := []*game.Game{}
+	for _, gm := range games {
+		if gm.Outcome() != chess.NoOutcome {
+			continue
+		}
+		if gm.Players[game.White].HasMember(memberID) || gm.Players[game.Black].HasMember(memberID) {
+			playing = append(playing, gm)
+		}
+	}
+	switch len(playing) {
+	case 0:
+		t.sendMessage(message, "You are not playing a game here. Start one with /new_game.", nil)
+		return nil
+	case 1:
+		return playing[0]
+	default:
+		t.sendMessage(message, "You are playing several games here, please reply to the board of the game you mean.", nil)
+		return nil
+	}
+}
+
+const telegramHelpText = `You can use ChessBot to play Chess with other teammates.
+Start a new game with "/new_game @p1 @p2 : @p3 @p4", the two teams are separated by ":". Everyone has to have sent me a message (e.g. /help) before they can be challenged.
+Make a move by picking a piece and its destination from the buttons below the board, or by sending "/move d2d4".
+Use /resign to resign and /takeback to take back your last move. When playing several games in a chat, reply to the board of the game you mean.`
+
+func (t TelegramHandler) handleChallengeCommand(command *ChallengeCommand, message *telegramMessage) {
+	challengerID, challengedID := command.Teams()
+	unknown := []string{}
+	for _, member := range append(strings.Fields(challengerID), strings.Fields(challengedID)...) {
+		if _, err := strconv.ParseInt(member, 10, 64); err != nil {
+			unknown = append(unknown, member)
+		}
+	}
+	if len(unknown) > 0 {
+		t.sendMessage(message, fmt.Sprintf("I don't know %v yet, please ask them to send me /help first.", strings.Join(unknown, " ")), nil)
+		return
+	}
+	gameID := telegramGameID(message, strconv.FormatInt(message.MessageID, 10))
+	gm := game.NewGame(gameID, game.Player{
+		ID: challengerID,
+	}, game.Player{
+		ID: challengedID,
+	})
+	gm.WorkspaceID = telegramWorkspace(message)
+	gm.CreatedBy = telegramMemberID(message.From)
+	gm.Start()
+	if err := t.GameStorage.StoreGame(gameID, gm); err != nil {
+		t.sendMessage(message, err.Error(), nil)
+		return
+	}
+	t.sendBoard(message, gm, fmt.Sprintf("Game %v vs. %v started.\n%v", t.mentions(gm.Players[game.White]), t.mentions(gm.Players[game.Black]), t.turnText(gm)))
+}
+
+func (t TelegramHandler) handleMoveCommand(gm *game.Game, lan string, from *telegramUser, message *telegramMessage) error {
+	var chessMove *chess.Move
+	gm, err := game.Update(t.GameStorage, gm, func(gm *game.Game) error {
+		var err error
+		chessMove, err = gm.MoveAs(telegramMemberID(from), lan)
+		return err
+	})
+	if err != nil {
+		t.sendMessage(message, err.Error(), nil)
+		return err
+	}
+	if outcome := gm.Outcome(); outcome != chess.NoOutcome {
+		t.displayEndGame(gm, message)
+		return nil
+	}
+	t.sendBoard(message, gm, fmt.Sprintf("%v\n%v", chessMove, t.turnText(gm)))
+	return nil
+}
+
+func (t TelegramHandler) handleResignCommand(gm *game.Game, message *telegramMessage) {
+	if _, err := gm.PlayerByID(telegramMemberID(message.From)); err != nil {
+		t.sendMessage(message, "I couldn't find you as part of this game.", nil)
+		return
+	}
+	gm, err := game.Update(t.GameStorage, gm, func(gm *game.Game) error {
+		return gm.ResignAs(telegramMemberID(message.From))
+	})
+	if err != nil {
+		t.sendMessage(message, err.Error(), nil)
+		return
+	}
+	t.displayEndGame(gm, message)
+}
+
+func (t TelegramHandler) handleTakebackCommand(gm *game.Game, message *telegramMessage) {
+	if _, err := gm.PlayerByID(telegramMemberID(message.From)); err != nil {
+		t.sendMessage(message, "I couldn't find you as part of this game.", nil)
+		return
+	}
+	gm, err := game.Update(t.GameStorage, gm, func(gm *game.Game) error {
+		if _, err := gm.TakebackAs(telegramMemberID(message.From)); err != nil {
+			return fmt.Errorf("Take back request failed: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.sendMessage(message, err.Error(), nil)
+		return
+	}
+	t.sendBoard(message, gm, fmt.Sprintf("%v requested a take back.\n%v", t.Users.name(telegramMemberID(message.From)), t.turnText(gm)))
+}
+
+func (t TelegramHandler) handleCallbackQuery(query *telegramCallbackQuery) {
+	ref, action := splitCallbackData(query.Data)
+	gm, err := t.GameStorage.RetrieveGame(telegramGameID(query.Message, ref))
+	if err != nil {
+		t.answerCallbackQuery(query, "This game no longer exists.")
+		return
+	}
+	if !gm.TurnPlayer().HasMember(telegramMemberID(&query.From)) {
+		t.answerCallbackQuery(query, "Please wait for your turn.")
+		return
+	}
+	switch {
+	case strings.HasPrefix(action, telegramPickPrefix):
+		t.answerCallbackQuery(query, "")
+		square := strings.TrimPrefix(action, telegramPickPrefix)
+		t.editReplyMarkup(query.Message, destinationKeyboard(gm, square))
+	case action == telegramBack:
+		t.answerCallbackQuery(query, "")
+		t.editReplyMarkup(query.Message, pieceKeyboard(gm))
+	case strings.HasPrefix(action, telegramMovePrefix):
+		lan := strings.TrimPrefix(action, telegramMovePrefix)
+		if err := t.handleMoveCommand(gm, lan, &query.From, query.Message); err != nil {
+			t.answerCallbackQuery(query, err.Error())
+			return
+		}
+		t.answerCallbackQuery(query, "")
+		t.editReplyMarkup(query.Message, nil)
+	default:
+		t.answerCallbackQuery(query, "Invalid action.")
+	}
+}
+
+func (t TelegramHandler) displayEndGame(gm *game.Game, message *telegramMessage) {
+	t.sendBoard(message, gm, gm.ResultText())
+	t.sendMessage(message, gm.Export(), nil)
+}
+
+func (t TelegramHandler) mentions(player game.Player) string {
+	members := player.Members()
+	for i, member := range members {
+		members[i] = t.Users.name(member)
+	}
+	return strings.Join(members, " ")
+}
+
+func (t TelegramHandler) turnText(gm *game.Game) string {
+	return fmt.Sprintf("%v to move (%v)", gm.Turn(), t.mentions(gm.TurnPlayer()))
+}
+
+// pieceKeyboard lists every square holding a piece that can be moved by the current player
+func pieceKeyboard(gm *game.Game) *telegramInlineKeyboardMarkup {
+	board := gm.Board()
+	seen := map[chess.Square]bool{}
+	squares := []chess.Square{}
+	for _, move := range gm.ValidMoves() {
+		if !seen[move.S1()] {
+			seen[move.S1()] = true
+			squares = append(squares, move.S1())
+		}
+	}
+	sort.Slice(squares, func(i, j int) bool { return squares[i] < squares[j] })
+	buttons := []telegramInlineKeyboardButton{}
+	for _, square := range squares {
+		buttons = append(buttons, telegramInlineKeyboardButton{
+			Text:         fmt.Sprintf("%v %v", board.Piece(square), square),
+			CallbackData: fmt.Sprintf("%v %v%v", telegramGameRef(gm), telegramPickPrefix, square),
+		})
+	}
+	return &telegramInlineKeyboardMarkup{InlineKeyboard: keyboardRows(buttons)}
+}
+
+// destinationKeyboard lists every legal move of the piece on the given square
+func destinationKeyboard(gm *game.Game, square string) *telegramInlineKeyboardMarkup {
+	buttons := []telegramInlineKeyboardButton{}
+	for _, move := range gm.ValidMoves() {
+		if move.S1().String() != square {
+			continue
+		}
+		text := move.S2().String()
+		if move.Promo() != chess.NoPieceType {
+			text += "=" + strings.ToUpper(move.Promo().String())
+		}
+		buttons = append(buttons, telegramInlineKeyboardButton{
+			Text:         text,
+			CallbackData: fmt.Sprintf("%v %v%v", telegramGameRef(gm), telegramMovePrefix, move),
+		})
+	}
+	rows := keyboardRows(buttons)
+	rows = append(rows, []telegramInlineKeyboardButton{{Text: "« back", CallbackData: fmt.Sprintf("%v %v", telegramGameRef(gm), telegramBack)}})
+	return &telegramInlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func keyboardRows(buttons []telegramInlineKeyboardButton) [][]telegramInlineKeyboardButton {
+	rows := [][]telegramInlineKeyboardButton{}
+	for i := 0; i < len(buttons); i += telegramRowSize {
+		end := i + telegramRowSize
+		if end > len(buttons) {
+			end = len(buttons)
+		}
+		rows = append(rows, buttons[i:end])
+	}
+	return rows
+}
+
+func (t TelegramHandler) sendBoard(message *telegramMessage, gm *game.Game, caption string) {
+	var keyboard *telegramInlineKeyboardMarkup
+	if gm.Outcome() == chess.NoOutcome {
+		keyboard = pieceKeyboard(gm)
+	}
+	image := new(bytes.Buffer)
+	if err := t.RenderBoard(image, gm); err != nil {
+		log.Println(err)
+		t.sendMessage(message, caption, keyboard)
+		return
+	}
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("chat_id", strconv.FormatInt(message.Chat.ID, 10))
+	if message.MessageThreadID != 0 {
+		writer.WriteField("message_thread_id", strconv.FormatInt(message.MessageThreadID, 10))
+	}
+	writer.WriteField("caption", caption)
+	if keyboard != nil {
+		markup, _ := json.Marshal(keyboard)
+		writer.WriteField("reply_markup", string(markup))
+	}
+	part, _ := writer.CreateFormFile("photo", gm.ID+".png")
+	io.Copy(part, image)
+	writer.Close()
+	if err := t.call("sendPhoto", writer.FormDataContentType(), body); err != nil {
+		log.Println(err)
+	}
+}
+
+func (t TelegramHandler) sendMessage(message *telegramMessage, text string, keyboard *telegramInlineKeyboardMarkup) {
+	params := map[string]interface{}{
+		"chat_id": message.Chat.ID,
+		"text":    text,
+	}
+	if message.MessageThreadID != 0 {
+		params["message_thread_id"] = message.MessageThreadID
+	}
+	if keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+	if err := t.callJSON("sendMessage", params); err != nil {
+		log.Println(err)
+	}
+}
+
+func (t TelegramHandler) editReplyMarkup(message *telegramMessage, keyboard *telegramInlineKeyboardMarkup) {
+	if keyboard == nil {
+		keyboard = &telegramInlineKeyboardMarkup{InlineKeyboard: [][]telegramInlineKeyboardButton{}}
+	}
+	if err := t.callJSON("editMessageReplyMarkup", map[string]interface{}{
+		"chat_id":      message.Chat.ID,
+		"message_id":   message.MessageID,
+		"reply_markup": keyboard,
+	}); err != nil {
+		log.Println(err)
+	}
+}
+
+func (t TelegramHandler) answerCallbackQuery(query *telegramCallbackQuery, text string) {
+	params := map[string]interface{}{
+		"callback_query_id": query.ID,
+	}
+	if text != "" {
+		params["text"] = text
+	}
+	if err := t.callJSON("answerCallbackQuery", params); err != nil {
+		log.Println(err)
+	}
+}
+
+func (t TelegramHandler) callJSON(method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return t.call(method, "application/json", bytes.NewReader(body))
+}
+
+func (t TelegramHandler) call(method string, contentType string, body io.Reader) error {
+	client := t.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(fmt.Sprintf("%v/bot%v/%v", t.APIURL, t.Token, method), contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram %v failed: %v", method, result.Description)
+	}
+	return nil
+}
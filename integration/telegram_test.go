@@ -0,0 +1,209 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/integration"
+	"github.com/notnil/chess"
+)
+
+type botAPICall struct {
+	method string
+	body   string
+}
+
+type fakeBotAPI struct {
+	mu    sync.Mutex
+	calls []botAPICall
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	parts := strings.Split(r.URL.Path, "/")
+	f.mu.Lock()
+	f.calls = append(f.calls, botAPICall{method: parts[len(parts)-1], body: string(body)})
+	f.mu.Unlock()
+	w.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func (f *fakeBotAPI) last(method string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.calls) - 1; i >= 0; i-- {
+		if f.calls[i].method == method {
+			return f.calls[i].body
+		}
+	}
+	return ""
+}
+
+func sendUpdate(t *testing.T, handler http.Handler, update string) {
+	req := httptest.NewRequest(http.MethodPost, "/telegram", bytes.NewBufferString(update))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", rec.Code)
+	}
+}
+
+// telegramChallenge is a challenge sent by alice (user 1), who mentions herself and the given players
+const telegramChallenge = `{"update_id":%[1]v,"message":{"message_id":%[1]v,"from":{"id":1,"username":"alice"},"chat":{"id":-100,"type":"group"},` +
+	`"text":"/new_game @%[2]v","entities":[{"type":"bot_command","offset":0,"length":9},{"type":"mention","offset":10,"length":6},{"type":"mention","offset":19,"length":4}]}}`
+
+func TestTelegramInlineKeyboardMove(t *testing.T) {
+	api := &fakeBotAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	store := game.NewMemoryStore()
+	handler := integration.TelegramHandler{
+		Token:       "TOKEN",
+		APIURL:      server.URL,
+		SecretToken: "secret",
+		GameStorage: store,
+		RenderBoard: func(w io.Writer, gm *game.Game) error {
+			_, err := w.Write([]byte("png"))
+			return err
+		},
+		Users: integration.NewTelegramDirectory(),
+	}
+
+	sendUpdate(t, handler, `{"update_id":1,"message":{"message_id":9,"from":{"id":2,"username":"bob"},"chat":{"id":-100,"type":"group"},"text":"/help"}}`)
+	sendUpdate(t, handler, fmt.Sprintf(telegramChallenge, 10, "alice : @bob"))
+	if photo := api.last("sendPhoto"); !strings.Contains(photo, "10 pick:e2") {
+		t.Fatalf("expected the board photo to offer the e2 pawn, got %v", photo)
+	}
+	gm, err := store.RetrieveGame("telegram:-100:0:10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := gm.Players[game.White].Members()[0]
+	black := gm.Players[game.Black].Members()[0]
+
+	callback := `{"update_id":%v,"callback_query":{"id":"cb","from":{"id":%v},"message":{"message_id":11,"chat":{"id":-100,"type":"group"}},"data":"%v"}}`
+	sendUpdate(t, handler, fmt.Sprintf(callback, 2, black, "10 pick:e7"))
+	if answer := api.last("answerCallbackQuery"); !strings.Contains(answer, "Please wait for your turn.") {
+		t.Errorf("expected the other team to be rejected, got %v", answer)
+	}
+
+	sendUpdate(t, handler, fmt.Sprintf(callback, 3, white, "10 pick:e2"))
+	var edit struct {
+		ReplyMarkup struct {
+			InlineKeyboard [][]struct {
+				CallbackData string `json:"callback_data"`
+			} `json:"inline_keyboard"`
+		} `json:"reply_markup"`
+	}
+	json.Unmarshal([]byte(api.last("editMessageReplyMarkup")), &edit)
+	destinations := []string{}
+	for _, row := range edit.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			destinations = append(destinations, button.CallbackData)
+		}
+	}
+	expected := []string{"10 move:e2e3", "10 move:e2e4", "10 back"}
+	if strings.Join(destinations, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected destinations %v, got %v", expected, destinations)
+	}
+
+	sendUpdate(t, handler, fmt.Sprintf(callback, 4, white, "10 move:e2e4"))
+	gm, _ = store.RetrieveGame("telegram:-100:0:10")
+	if gm.LastMove() == nil || gm.LastMove().String() != "e2e4" {
+		t.Errorf("expected e2e4 to be played, got %v", gm.LastMove())
+	}
+	if photo := api.last("sendPhoto"); !strings.Contains(photo, "10 pick:e7") {
+		t.Errorf("expected the next board to offer black pieces, got %v", photo)
+	}
+}
+
+func TestTelegramGamesPerChallenge(t *testing.T) {
+	api := &fakeBotAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	store := game.NewMemoryStore()
+	handler := integration.TelegramHandler{
+		Token:       "TOKEN",
+		APIURL:      server.URL,
+		SecretToken: "secret",
+		GameStorage: store,
+		RenderBoard: func(w io.Writer, gm *game.Game) error {
+			_, err := w.Write([]byte("png"))
+			return err
+		},
+		Users: integration.NewTelegramDirectory(),
+	}
+	resign := `{"update_id":%[1]v,"message":{"message_id":%[1]v,"from":{"id":1,"username":"alice"},"chat":{"id":-100,"type":"group"},"text":"/resign"%[2]v}}`
+
+	sendUpdate(t, handler, fmt.Sprintf(telegramChallenge, 1, "alice : @bob"))
+	if text := api.last("sendMessage"); !strings.Contains(text, "I don't know @bob yet") {
+		t.Fatalf("expected the unknown player to be rejected, got %v", text)
+	}
+	sendUpdate(t, handler, `{"update_id":2,"message":{"message_id":2,"from":{"id":2,"username":"bob"},"chat":{"id":-100,"type":"group"},"text":"/help"}}`)
+
+	sendUpdate(t, handler, fmt.Sprintf(telegramChallenge, 10, "alice : @bob"))
+	sendUpdate(t, handler, fmt.Sprintf(resign, 11, ""))
+	first, err := store.RetrieveGame("telegram:-100:0:10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Outcome() == chess.NoOutcome {
+		t.Fatal("expected alice to resign the only game of the chat")
+	}
+
+	sendUpdate(t, handler, fmt.Sprintf(telegramChallenge, 12, "alice : @bob"))
+	sendUpdate(t, handler, fmt.Sprintf(telegramChallenge, 13, "alice : @bob"))
+	second, err := store.RetrieveGame("telegram:-100:0:12")
+	if err != nil {
+		t.Fatalf("expected a new game once the last one finished: %v", err)
+	}
+	if !second.Players[game.White].HasMember("1") && !second.Players[game.Black].HasMember("1") {
+		t.Errorf("expected alice to play by her user ID, got %v", second.Players)
+	}
+
+	sendUpdate(t, handler, fmt.Sprintf(resign, 14, ""))
+	if text := api.last("sendMessage"); !strings.Contains(text, "several games") {
+		t.Errorf("expected an ambiguous command to be rejected, got %v", text)
+	}
+	sendUpdate(t, handler, fmt.Sprintf(resign, 15, `,"reply_to_message":{"message_id":13,"chat":{"id":-100,"type":"group"}}`))
+	third, _ := store.RetrieveGame("telegram:-100:0:13")
+	second, _ = store.RetrieveGame("telegram:-100:0:12")
+	if third.Outcome() == chess.NoOutcome || second.Outcome() != chess.NoOutcome {
+		t.Errorf("expected only the game replied to to be resigned, got %v and %v", second.Outcome(), third.Outcome())
+	}
+}
+
+func TestTelegramRejectsInvalidSecret(t *testing.T) {
+	for _, test := range []struct {
+		configured string
+		sent       string
+	}{
+		{"secret", ""},
+		{"secret", "wrong"},
+		{"", ""},
+	} {
+		handler := integration.TelegramHandler{
+			SecretToken: test.configured,
+			GameStorage: game.NewMemoryStore(),
+			Users:       integration.NewTelegramDirectory(),
+		}
+		req := httptest.NewRequest(http.MethodPost, "/telegram", bytes.NewBufferString(fmt.Sprintf(telegramChallenge, 1, "alice : @bob")))
+		if test.sent != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", test.sent)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("expected status 403 for secret %q with %q configured, got %v", test.sent, test.configured, rec.Code)
+		}
+	}
+}
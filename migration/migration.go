@@ -0,0 +1,158 @@
+// Package migration applies ordered schema changes to a database and records how far each component got
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Dialect is the flavour of SQL spoken by a database
+type Dialect int
+
+// Databases supported by the stores
+const (
+	Sqlite Dialect = iota
+	Postgres
+)
+
+// placeholder is the n-th (1 based) query parameter of the dialect
+func (d Dialect) placeholder(n int) string {
+	if d == Postgres {
+		return fmt.Sprintf("$%v", n)
+	}
+	return "?"
+}
+
+const versionTableCreation = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		component text PRIMARY KEY,
+		version integer NOT NULL
+	);
+`
+
+// Migration is one schema change, Up may hold several statements.
+// Run replaces Up for changes depending on the current state of the database, e.g. of databases created before
+// their schema was versioned.
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+	Run         func(tx *sql.Tx) error
+}
+
+// Set is the ordered migrations of the tables owned by one component, e.g. the games and challenges of the game package
+type Set struct {
+	Component  string
+	Migrations []Migration
+}
+
+// Latest is the version reached once every migration of the set is applied
+func (s Set) Latest() int {
+	latest := 0
+	for _, migration := range s.Migrations {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+	return latest
+}
+
+// Apply runs the migrations of the set newer than the recorded version, each in its own transaction.
+// Concurrent callers on Postgres wait for each other so every migration runs once.
+func Apply(db *sql.DB, dialect Dialect, set Set) error {
+	if _, err := db.Exec(versionTableCreation); err != nil {
+		return err
+	}
+	for _, migration := range set.Migrations {
+		if err := apply(db, dialect, set.Component, migration); err != nil {
+			return fmt.Errorf("%v migration %v (%v): %v", set.Component, migration.Version, migration.Description, err)
+		}
+	}
+	return nil
+}
+
+func apply(db *sql.DB, dialect Dialect, component string, migration Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if dialect == Postgres {
+		if _, err := tx.Exec("lock table schema_version in exclusive mode"); err != nil {
+			return err
+		}
+	}
+	current, err := version(tx, dialect, component)
+	if err != nil {
+		return err
+	}
+	if current >= migration.Version {
+		return nil
+	}
+	if migration.Run != nil {
+		err = migration.Run(tx)
+	} else {
+		_, err = tx.Exec(migration.Up)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(
+		"insert into schema_version (component, version) values (%v, %v) on conflict(component) do update set version = %v",
+		dialect.placeholder(1), dialect.placeholder(2), dialect.placeholder(3),
+	), component, migration.Version, migration.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Version is the last migration applied to the tables of the component, 0 when none were
+func Version(db *sql.DB, dialect Dialect, component string) (int, error) {
+	if _, err := db.Exec(versionTableCreation); err != nil {
+		return 0, err
+	}
+	return version(db, dialect, component)
+}
+
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func version(db queryer, dialect Dialect, component string) (int, error) {
+	var current int
+	err := db.QueryRow("select version from schema_version where component = "+dialect.placeholder(1), component).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return current, err
+}
+
+// AddSqliteColumn adds a column to a sqlite table unless it already has it, for tables which gained the column before
+// their schema was versioned
+func AddSqliteColumn(table string, column string, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query(fmt.Sprintf("pragma table_info(%v)", table))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var cid, notNull, primaryKey int
+			var name, columnType string
+			var defaultValue sql.NullString
+			if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+				return err
+			}
+			if name == column {
+				return nil
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		_, err = tx.Exec(fmt.Sprintf("alter table %v add column %v %v", table, column, definition))
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+package migration_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/migration"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestApplyRunsEachMigrationOnce(t *testing.T) {
+	db := openTestDB(t)
+	set := migration.Set{
+		Component: "test",
+		Migrations: []migration.Migration{
+			{Version: 1, Description: "create", Up: "create table items (id text primary key)"},
+			{Version: 2, Description: "add column", Up: "alter table items add column name text"},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err := migration.Apply(db, migration.Sqlite, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if version, err := migration.Version(db, migration.Sqlite, "test"); err != nil || version != set.Latest() {
+		t.Errorf("expected version %v, got %v (%v)", set.Latest(), version, err)
+	}
+	if version, _ := migration.Version(db, migration.Sqlite, "other"); version != 0 {
+		t.Errorf("expected components to be versioned separately, got %v", version)
+	}
+}
+
+func TestApplyStopsAtFailingMigration(t *testing.T) {
+	db := openTestDB(t)
+	set := migration.Set{
+		Component: "test",
+		Migrations: []migration.Migration{
+			{Version: 1, Description: "create", Up: "create table items (id text primary key)"},
+			{Version: 2, Description: "broken", Up: "alter table missing add column name text"},
+			{Version: 3, Description: "index", Up: "create index items_id on items (id)"},
+		},
+	}
+	if err := migration.Apply(db, migration.Sqlite, set); err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+	if version, _ := migration.Version(db, migration.Sqlite, "test"); version != 1 {
+		t.Errorf("expected to stop before the broken migration, got version %v", version)
+	}
+}
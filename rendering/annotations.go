@@ -0,0 +1,187 @@
+package rendering
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/notnil/chess"
+)
+
+// annotationColors maps the color names accepted in annotations to their hex value
+var annotationColors = map[string]string{
+	"green":  "#15781b",
+	"red":    "#882020",
+	"blue":   "#003088",
+	"yellow": "#e68f00",
+}
+
+// DefaultAnnotationColor is used for annotations without a valid color
+const DefaultAnnotationColor = "green"
+
+// annotationOpacity keeps the pieces under annotations visible
+const annotationOpacity = 0.6
+
+// IsAnnotationColor determines if annotations can be drawn in the color of the given name
+func IsAnnotationColor(name string) bool {
+	_, ok := annotationColors[name]
+	return ok
+}
+
+// Arrow points from one square to another, e.g. to illustrate a move
+type Arrow struct {
+	From  string
+	To    string
+	Color string
+}
+
+// Mark highlights a single square
+type Mark struct {
+	Square string
+	Color  string
+}
+
+// Annotations are drawn on top of a board. Squares are given in algebraic coordinates (e.g. "e4").
+type Annotations struct {
+	Arrows []Arrow
+	Marks  []Mark
+}
+
+// ParseAnnotations reads annotations as encoded in board URLs ("e2e4:green,d7d5:red" and "e4:yellow"),
+// skipping any invalid entry
+func ParseAnnotations(arrows string, marks string) Annotations {
+	annotations := Annotations{}
+	for _, entry := range strings.Split(arrows, ",") {
+		squares, color := splitAnnotationColor(entry)
+		if len(squares) == 4 && parseSquare(squares[:2]) != chess.NoSquare && parseSquare(squares[2:]) != chess.NoSquare {
+			annotations.Arrows = append(annotations.Arrows, Arrow{From: squares[:2], To: squares[2:], Color: color})
+		}
+	}
+	for _, entry := range strings.Split(marks, ",") {
+		square, color := splitAnnotationColor(entry)
+		if parseSquare(square) != chess.NoSquare {
+			annotations.Marks = append(annotations.Marks, Mark{Square: square, Color: color})
+		}
+	}
+	return annotations
+}
+
+func splitAnnotationColor(entry string) (string, string) {
+	parts := strings.SplitN(entry, ":", 2)
+	if len(parts) == 2 && IsAnnotationColor(parts[1]) {
+		return parts[0], parts[1]
+	}
+	return parts[0], DefaultAnnotationColor
+}
+
+// encode returns the annotations in the form read by ParseAnnotations
+func (a Annotations) encode() (string, string) {
+	arrows, marks := []string{}, []string{}
+	for _, arrow := range a.Arrows {
+		arrows = append(arrows, fmt.Sprintf("%v%v:%v", arrow.From, arrow.To, annotationColor(arrow.Color)))
+	}
+	for _, mark := range a.Marks {
+		marks = append(marks, fmt.Sprintf("%v:%v", mark.Square, annotationColor(mark.Color)))
+	}
+	return strings.Join(arrows, ","), strings.Join(marks, ",")
+}
+
+func annotationColor(name string) string {
+	if IsAnnotationColor(name) {
+		return name
+	}
+	return DefaultAnnotationColor
+}
+
+// arrowGeometry is an arrow in board pixel coordinates: the shaft ends where the head starts
+type arrowGeometry struct {
+	fromX, fromY   float64
+	shaftX, shaftY float64
+	tipX, tipY     float64
+	leftX, leftY   float64
+	rightX, rightY float64
+	width          float64
+}
+
+func newArrowGeometry(arrow Arrow, inverted bool, size float64) arrowGeometry {
+	fromCol, fromRow := squarePosition(parseSquare(arrow.From), inverted)
+	toCol, toRow := squarePosition(parseSquare(arrow.To), inverted)
+	fromX, fromY := (float64(fromCol)+0.5)*size, (float64(fromRow)+0.5)*size
+	tipX, tipY := (float64(toCol)+0.5)*size, (float64(toRow)+0.5)*size
+	length := math.Hypot(tipX-fromX, tipY-fromY)
+	unitX, unitY := (tipX-fromX)/length, (tipY-fromY)/length
+	headLength, headWidth := size*0.45, size*0.5
+	shaftX, shaftY := tipX-unitX*headLength, tipY-unitY*headLength
+	return arrowGeometry{
+		fromX:  fromX,
+		fromY:  fromY,
+		shaftX: shaftX,
+		shaftY: shaftY,
+		tipX:   tipX,
+		tipY:   tipY,
+		leftX:  shaftX - unitY*headWidth/2,
+		leftY:  shaftY + unitX*headWidth/2,
+		rightX: shaftX + unitY*headWidth/2,
+		rightY: shaftY - unitX*headWidth/2,
+		width:  size * 0.18,
+	}
+}
+
+func setAnnotationColor(dc *gg.Context, name string) {
+	hex := strings.TrimPrefix(annotationColors[annotationColor(name)], "#")
+	var r, g, b int
+	fmt.Sscanf(hex, "%02x%02x%02x", &r, &g, &b)
+	dc.SetRGBA255(r, g, b, int(annotationOpacity*255))
+}
+
+// drawMarks highlights marked squares, it is drawn below the pieces
+func (a Annotations) drawMarks(dc *gg.Context, inverted bool) {
+	for _, mark := range a.Marks {
+		col, row := squarePosition(parseSquare(mark.Square), inverted)
+		dc.DrawRectangle(float64(col*squareSize), float64(row*squareSize), squareSize, squareSize)
+		setAnnotationColor(dc, mark.Color)
+		dc.Fill()
+	}
+}
+
+// drawArrows is drawn above the pieces
+func (a Annotations) drawArrows(dc *gg.Context, inverted bool) {
+	for _, arrow := range a.Arrows {
+		if arrow.From == arrow.To {
+			continue
+		}
+		geometry := newArrowGeometry(arrow, inverted, squareSize)
+		setAnnotationColor(dc, arrow.Color)
+		dc.SetLineWidth(geometry.width)
+		dc.DrawLine(geometry.fromX, geometry.fromY, geometry.shaftX, geometry.shaftY)
+		dc.Stroke()
+		dc.MoveTo(geometry.tipX, geometry.tipY)
+		dc.LineTo(geometry.leftX, geometry.leftY)
+		dc.LineTo(geometry.rightX, geometry.rightY)
+		dc.ClosePath()
+		dc.Fill()
+	}
+}
+
+func (a Annotations) writeSVGMarks(w io.Writer, inverted bool) {
+	for _, mark := range a.Marks {
+		x, y := svgSquareOrigin(parseSquare(mark.Square), inverted)
+		fmt.Fprintf(w, `<rect x="%v" y="%v" width="%v" height="%v" fill="%v" fill-opacity="%v"/>`, x, y, svgSquareSize, svgSquareSize, annotationColors[annotationColor(mark.Color)], annotationOpacity)
+	}
+}
+
+func (a Annotations) writeSVGArrows(w io.Writer, inverted bool) {
+	for _, arrow := range a.Arrows {
+		if arrow.From == arrow.To {
+			continue
+		}
+		g := newArrowGeometry(arrow, inverted, svgSquareSize)
+		color := annotationColors[annotationColor(arrow.Color)]
+		fmt.Fprintf(w, `<g fill="%v" stroke="%v" opacity="%v">`, color, color, annotationOpacity)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke-width="%.1f"/>`, g.fromX, g.fromY, g.shaftX, g.shaftY, g.width)
+		fmt.Fprintf(w, `<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" stroke="none"/>`, g.tipX, g.tipY, g.leftX, g.leftY, g.rightX, g.rightY)
+		fmt.Fprint(w, `</g>`)
+	}
+}
@@ -0,0 +1,212 @@
+package rendering
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cjsaylor/chessbot/game"
+)
+
+// DefaultCacheSize is how many rendered boards are kept in memory unless configured otherwise
+const DefaultCacheSize = 256
+
+// RenderCache keeps rendered boards keyed by a hash of everything drawn on them. Recently used boards are kept in
+// memory, and all of them on disk when a directory is configured. Concurrent requests for the same board share a
+// single render.
+type RenderCache struct {
+	capacity int
+	dir      string
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	// order lists the entries from the most to the least recently used
+	order    *list.List
+	inflight map[string]*renderCall
+	stats    CacheStats
+}
+
+// CacheStats counts how render requests were served
+type CacheStats struct {
+	Hits         uint64
+	DiskHits     uint64
+	Misses       uint64
+	Deduplicated uint64
+	Entries      int
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+type renderCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// NewRenderCache creates a cache holding up to capacity boards in memory, dir is the optional on-disk tier
+func NewRenderCache(capacity int, dir string) *RenderCache {
+	if capacity <= 0 {
+		capacity = DefaultCacheSize
+	}
+	return &RenderCache{
+		capacity: capacity,
+		dir:      dir,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		inflight: make(map[string]*renderCall),
+	}
+}
+
+// Get returns the cached board of the key, calling render at most once for concurrent requests of a missing board
+func (c *RenderCache) Get(key string, render func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		atomic.AddUint64(&c.stats.Hits, 1)
+		return element.Value.(*cacheEntry).data, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.stats.Deduplicated, 1)
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	call := &renderCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.data, call.err = c.load(key, render)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.add(key, call.data)
+	}
+	c.mu.Unlock()
+	call.wg.Done()
+	return call.data, call.err
+}
+
+// load reads the board from disk, rendering and storing it there when missing
+func (c *RenderCache) load(key string, render func() ([]byte, error)) ([]byte, error) {
+	path := c.path(key)
+	if path != "" {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			atomic.AddUint64(&c.stats.DiskHits, 1)
+			return data, nil
+		}
+	}
+	atomic.AddUint64(&c.stats.Misses, 1)
+	data, err := render()
+	if err != nil || path == "" {
+		return data, err
+	}
+	// The board is still served when it can't be written to disk
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Println(err)
+	} else if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Println(err)
+	}
+	return data, nil
+}
+
+// add stores the board in memory, evicting the least recently used ones beyond the capacity. The lock must be held.
+func (c *RenderCache) add(key string, data []byte) {
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, data: data})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// path is where the board of the key is stored on disk, spread over subdirectories by the key prefix
+func (c *RenderCache) path(key string) string {
+	if c.dir == "" {
+		return ""
+	}
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Stats returns how render requests were served so far
+func (c *RenderCache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:         atomic.LoadUint64(&c.stats.Hits),
+		DiskHits:     atomic.LoadUint64(&c.stats.DiskHits),
+		Misses:       atomic.LoadUint64(&c.stats.Misses),
+		Deduplicated: atomic.LoadUint64(&c.stats.Deduplicated),
+		Entries:      entries,
+	}
+}
+
+// GameStored pre-warms the cache with the new position of the game in both orientations, so that the board is
+// ready by the time the chat client requests it
+func (c *RenderCache) GameStored(gm *game.Game) {
+	board := newGameImage(gm, len(gm.Moves()))
+	go func() {
+		for _, inverted := range []bool{false, true} {
+			board.inverted = inverted
+			if _, err := c.Get(board.cacheKey("png"), board.encodePNG); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+}
+
+// cacheKey is a hash of everything drawn on the board in the given format
+func (b boardImage) cacheKey(format string) string {
+	arrows, squares := b.annotations.encode()
+	panel := ""
+	if b.panel != nil {
+		panel = fmt.Sprintf("%+v", *b.panel)
+	}
+	payload := fmt.Sprintf(
+		"%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v",
+		format, b.board, squareName(b.from), squareName(b.to), squareName(b.check), b.inverted,
+		b.style.Theme, b.style.Pieces, arrows, squares, panel)
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+func (b boardImage) encodePNG() ([]byte, error) {
+	img, err := b.render()
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	err = png.Encode(buf, img)
+	return buf.Bytes(), err
+}
+
+func (b boardImage) encodeSVG() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := RenderSVG(buf, b.board, SVGOptions{
+		From:        squareName(b.from),
+		To:          squareName(b.to),
+		Check:       squareName(b.check),
+		Inverted:    b.inverted,
+		Style:       b.style,
+		Annotations: b.annotations,
+	})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,123 @@
+package rendering_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func TestRenderCacheDeduplicatesAndEvicts(t *testing.T) {
+	cache := rendering.NewRenderCache(2, "")
+	var renders int32
+	render := func() ([]byte, error) {
+		atomic.AddInt32(&renders, 1)
+		time.Sleep(10 * time.Millisecond)
+		return []byte("board"), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Get("aa01", render)
+		}()
+	}
+	wg.Wait()
+	if renders != 1 {
+		t.Fatalf("expected concurrent requests to share one render, got %v", renders)
+	}
+	cache.Get("bb02", render)
+	cache.Get("cc03", render)
+	cache.Get("aa01", render)
+	if renders != 4 {
+		t.Errorf("expected the least recently used board to be evicted, got %v renders", renders)
+	}
+	if stats := cache.Stats(); stats.Misses != 4 || stats.Entries != 2 || stats.Hits+stats.Deduplicated != 9 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRenderCacheKeepsBoardsOnDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	render := func() ([]byte, error) { return []byte("board"), nil }
+	rendering.NewRenderCache(1, dir).Get("aa01", render)
+	cache := rendering.NewRenderCache(1, dir)
+	data, _ := cache.Get("aa01", func() ([]byte, error) {
+		t.Error("expected the board to be read from disk")
+		return nil, nil
+	})
+	if string(data) != "board" || cache.Stats().DiskHits != 1 {
+		t.Errorf("expected a disk hit, got %q and %+v", data, cache.Stats())
+	}
+}
+
+func TestBoardHandlerRevalidatesETag(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, _ := linkRenderer.CreateLink(gm)
+	handler := rendering.BoardRenderHandler{LinkRenderer: linkRenderer, Cache: rendering.NewRenderCache(10, "")}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.String()+"&format=svg", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("Cache-Control") == "" {
+		t.Fatalf("expected a cacheable board, got status %v and headers %v", rec.Code, rec.Header())
+	}
+	req := httptest.NewRequest(http.MethodGet, link.String()+"&format=svg", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected the board to be revalidated, got status %v", rec.Code)
+	}
+}
+
+func TestGameLinkChangesWithThePosition(t *testing.T) {
+	store := game.NewMemoryStore()
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.Move("e2e4")
+	gm.Move("e7e5")
+	store.StoreGame(gm.ID, gm)
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	handler := rendering.BoardRenderHandler{LinkRenderer: linkRenderer, GameStorage: store}
+	link, _ := linkRenderer.CreateGameLink(gm, 2, rendering.LinkOptions{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.String()+"&format=svg", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "public, max-age=86400" {
+		t.Fatalf("expected a cacheable board, got status %v and headers %v", rec.Code, rec.Header())
+	}
+
+	black := gm.Players[game.Black]
+	if _, err := gm.Takeback(&black); err != nil {
+		t.Fatal(err)
+	}
+	gm.Move("d7d5")
+	store.StoreGame(gm.ID, gm)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.String()+"&format=svg", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected the link to the taken back position to be gone, got status %v", rec.Code)
+	}
+	replaced, _ := linkRenderer.CreateGameLink(gm, 2, rendering.LinkOptions{})
+	if replaced.String() == link.String() {
+		t.Error("expected the new position to get a new link")
+	}
+
+	panel, _ := linkRenderer.CreateGameLink(gm, 2, rendering.LinkOptions{Panel: true})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, panel.String()+"&format=svg", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("expected the panel of the ongoing game to be revalidated, got status %v and headers %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,91 @@
+package rendering
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// pieceNames are the spoken names of each piece type, singular and plural
+var pieceNames = map[chess.PieceType][2]string{
+	chess.King:   {"King", "Kings"},
+	chess.Queen:  {"Queen", "Queens"},
+	chess.Rook:   {"Rook", "Rooks"},
+	chess.Bishop: {"Bishop", "Bishops"},
+	chess.Knight: {"Knight", "Knights"},
+	chess.Pawn:   {"Pawn", "Pawns"},
+}
+
+// describedPieceTypes is the order pieces are listed in, most valuable first
+var describedPieceTypes = []chess.PieceType{chess.King, chess.Queen, chess.Rook, chess.Bishop, chess.Knight, chess.Pawn}
+
+// DescribePosition lists the pieces of both sides with their squares, e.g. "White: King g1, Rooks a1 f1. Black: ..."
+func DescribePosition(board *chess.Board) string {
+	squares := map[chess.Piece][]string{}
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		if piece := board.Piece(sq); piece != chess.NoPiece {
+			squares[piece] = append(squares[piece], sq.String())
+		}
+	}
+	sides := []string{}
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		pieces := []string{}
+		for _, pieceType := range describedPieceTypes {
+			found := squares[pieceOf(pieceType, color)]
+			if len(found) == 0 {
+				continue
+			}
+			name := pieceNames[pieceType][0]
+			if len(found) > 1 {
+				name = pieceNames[pieceType][1]
+			}
+			pieces = append(pieces, name+" "+strings.Join(found, " "))
+		}
+		sides = append(sides, fmt.Sprintf("%v: %v.", color.Name(), strings.Join(pieces, ", ")))
+	}
+	return strings.Join(sides, " ")
+}
+
+// DescribeMove describes a move played from the position as it would be spoken, e.g. "Knight from g1 to f3, check"
+func DescribeMove(position *chess.Position, move *chess.Move) string {
+	piece := position.Board().Piece(move.S1())
+	var description string
+	switch {
+	case move.HasTag(chess.KingSideCastle):
+		description = "Castles kingside"
+	case move.HasTag(chess.QueenSideCastle):
+		description = "Castles queenside"
+	case move.HasTag(chess.EnPassant):
+		description = fmt.Sprintf("Pawn from %v takes %v en passant", move.S1(), move.S2())
+	case move.HasTag(chess.Capture):
+		captured := position.Board().Piece(move.S2())
+		description = fmt.Sprintf("%v from %v takes %v on %v", pieceNames[piece.Type()][0], move.S1(), pieceNames[captured.Type()][0], move.S2())
+	default:
+		description = fmt.Sprintf("%v from %v to %v", pieceNames[piece.Type()][0], move.S1(), move.S2())
+	}
+	if move.Promo() != chess.NoPieceType {
+		description += ", promotes to " + pieceNames[move.Promo()][0]
+	}
+	switch {
+	case position.Update(move).Status() == chess.Checkmate:
+		description += ", checkmate"
+	case move.HasTag(chess.Check):
+		description += ", check"
+	}
+	return description
+}
+
+// AltText describes the board of the game after the given number of plies for screen reader users:
+// whose turn it is, the last move and every piece
+func AltText(gm *game.Game, ply int) string {
+	positions, moves := gm.Positions(), gm.Moves()
+	position := positions[ply]
+	parts := []string{fmt.Sprintf("Chess board, %v to move.", position.Turn().Name())}
+	if ply > 0 {
+		parts = append(parts, fmt.Sprintf("Last move: %v %v.", positions[ply-1].Turn().Name(), DescribeMove(positions[ply-1], moves[ply-1])))
+	}
+	parts = append(parts, DescribePosition(position.Board()))
+	return strings.Join(parts, " ")
+}
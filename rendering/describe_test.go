@@ -0,0 +1,29 @@
+package rendering_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func TestAltTextDescribesPositionAndLastMove(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	for _, move := range []string{"e2e4", "f7f6", "d2d4", "g7g5", "d1h5"} {
+		gm.Move(move)
+	}
+	alt := rendering.AltText(gm, 5)
+	for _, expected := range []string{
+		"Chess board, Black to move.",
+		"Last move: White Queen from d1 to h5, checkmate.",
+		"White: King e1, Queen h5, Rooks a1 h1, Bishops c1 f1, Knights b1 g1, Pawns a2 b2 c2 f2 g2 h2 d4 e4.",
+	} {
+		if !strings.Contains(alt, expected) {
+			t.Errorf("expected %q in %q", expected, alt)
+		}
+	}
+	if alt := rendering.AltText(gm, 2); !strings.Contains(alt, "Last move: Black Pawn from f7 to f6.") {
+		t.Errorf("expected the description of an earlier position, got %q", alt)
+	}
+}
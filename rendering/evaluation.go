@@ -0,0 +1,175 @@
+package rendering
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/cjsaylor/chessbot/analysis"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/fogleman/gg"
+	"github.com/notnil/chess"
+)
+
+// Evaluation graph dimensions, the legend is drawn above the plot
+const (
+	graphWidth        = 640
+	graphHeight       = 320
+	graphLegendHeight = 48
+	graphMargin       = 10
+)
+
+// evaluationRange is the evaluation in centipawns drawn at the top and bottom edges of the plot
+const evaluationRange = 1000
+
+var judgementColors = map[analysis.Judgement]string{
+	analysis.Mistake: "#e69f00",
+	analysis.Blunder: "#db3031",
+}
+
+// RenderEvaluationGraph writes a PNG line chart of the evaluation across the game, marking mistakes and blunders.
+// The area below the line is white's share of the evaluation.
+func RenderEvaluationGraph(w io.Writer, review analysis.Review) error {
+	dc := gg.NewContext(graphWidth, graphHeight)
+	dc.SetHexColor("#ffffff")
+	dc.Clear()
+	left, top := float64(graphMargin), float64(graphLegendHeight)
+	width, height := float64(graphWidth-2*graphMargin), float64(graphHeight-graphLegendHeight-graphMargin)
+	middle := top + height/2
+	x := func(ply int) float64 {
+		if len(review.Evaluations) < 2 {
+			return left
+		}
+		return left + width*float64(ply)/float64(len(review.Evaluations)-1)
+	}
+	y := func(evaluation int) float64 {
+		return middle - height/2*float64(evaluation)/evaluationRange
+	}
+
+	dc.DrawRectangle(left, top, width, height)
+	dc.SetHexColor("#404040")
+	dc.Fill()
+	if len(review.Evaluations) > 0 {
+		dc.MoveTo(left, top+height)
+		for ply, evaluation := range review.Evaluations {
+			dc.LineTo(x(ply), y(evaluation))
+		}
+		dc.LineTo(x(len(review.Evaluations)-1), top+height)
+		dc.ClosePath()
+		dc.SetHexColor("#f0f0f0")
+		dc.Fill()
+	}
+	dc.SetHexColor("#909090")
+	dc.SetLineWidth(1)
+	dc.DrawLine(left, middle, left+width, middle)
+	dc.Stroke()
+	for ply, judgement := range review.Judgements {
+		dc.DrawCircle(x(ply), y(review.Evaluations[ply]), 5)
+		dc.SetHexColor(judgementColors[judgement])
+		dc.Fill()
+	}
+
+	if err := dc.LoadFontFace(assetPath+"arial.ttf", 15); err != nil {
+		// The graph is still usable without a legend
+		log.Println(err)
+	} else {
+		dc.SetHexColor("#000000")
+		for i, color := range []game.Color{game.White, game.Black} {
+			dc.DrawString(fmt.Sprintf(
+				"%v    blunders: %v    mistakes: %v    average loss: %v",
+				color, review.Count(color, analysis.Blunder), review.Count(color, analysis.Mistake), review.AverageLoss[color],
+			), left, float64(18+i*20))
+		}
+	}
+	return png.Encode(w, dc.Image())
+}
+
+// NewGameReview evaluates the game and reviews its moves
+func NewGameReview(gm *game.Game, evaluator analysis.GameEvaluator) (analysis.Review, error) {
+	evaluations, err := evaluator.EvaluateGame(gm)
+	if err != nil {
+		return analysis.Review{}, err
+	}
+	firstMover := game.White
+	if gm.Positions()[0].Turn() == chess.Black {
+		firstMover = game.Black
+	}
+	return analysis.NewReview(evaluations, firstMover), nil
+}
+
+// EvaluationRenderHandler serves evaluation graphs of stored games
+type EvaluationRenderHandler struct {
+	LinkRenderer RenderLink
+	GameStorage  game.GameStorage
+	Evaluator    analysis.GameEvaluator
+	// Cache is optional, engine evaluations are slow so graphs should be cached
+	Cache *RenderCache
+}
+
+// ServeHTTP is a request handler
+func (h EvaluationRenderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.LinkRenderer.ValidateEvaluationLink(*r.URL) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	gm, err := h.GameStorage.RetrieveGame(r.URL.Query().Get("game_id"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var data []byte
+	if h.Cache != nil {
+		data, err = h.Cache.Get(evaluationCacheKey(gm), h.renderer(gm))
+	} else {
+		data, err = h.renderer(gm)()
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", cacheControl)
+	if _, err := w.Write(data); err != nil {
+		log.Println(err)
+	}
+}
+
+func (h EvaluationRenderHandler) renderer(gm *game.Game) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		review, err := NewGameReview(gm, h.Evaluator)
+		if err != nil {
+			return nil, err
+		}
+		buf := &bytes.Buffer{}
+		err = RenderEvaluationGraph(buf, review)
+		return buf.Bytes(), err
+	}
+}
+
+// GameStored starts evaluating finished games right away, so the graph posted with the result is ready sooner
+func (h EvaluationRenderHandler) GameStored(gm *game.Game) {
+	if h.Cache == nil || gm.Outcome() == chess.NoOutcome {
+		return
+	}
+	go func() {
+		if _, err := h.Cache.Get(evaluationCacheKey(gm), h.renderer(gm)); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// evaluationCacheKey covers every move of the game, so the graph is redrawn after takebacks
+func evaluationCacheKey(gm *game.Game) string {
+	sum := sha256.Sum256([]byte("evaluation\n" + gm.ID + "\n" + gm.PGN()))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,18 +2,31 @@
 package rendering
 
 import (
-	"image/png"
 	"log"
 	"net/http"
+	"strconv"
 
-	"github.com/cjsaylor/chessimage"
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
 )
 
 // BoardRenderHandler handles all image requests from Slack
 type BoardRenderHandler struct {
 	LinkRenderer RenderLink
+	// GameStorage resolves links to positions of stored games
+	GameStorage game.GameStorage
+	// Cache is optional, boards are rendered on every request without it
+	Cache *RenderCache
 }
 
+// cacheControl lets clients and proxies keep boards for a day, the ETag allows revalidating them afterwards.
+// Every parameter of a link is signed and game links include a hash of the moves leading to their position,
+// so the image of a URL never changes, except for the panel of the latest position of an unfinished game.
+const cacheControl = "public, max-age=86400"
+
+// revalidateCacheControl makes clients check the ETag of boards which can still change
+const revalidateCacheControl = "no-cache"
+
 // ServeHTTP is a request handler
 func (b BoardRenderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -21,8 +34,7 @@ func (b BoardRenderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	query := r.URL.Query()
-	fen := query.Get("fen")
-	if fen == "" {
+	if query.Get("fen") == "" && query.Get("game_id") == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -30,31 +42,92 @@ func (b BoardRenderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
-	board, err := chessimage.NewRendererFromFEN(fen)
+	options := ParseLinkOptions(*r.URL)
+	var board boardImage
+	caching := cacheControl
+	if gameID := query.Get("game_id"); gameID != "" {
+		gm, ply, status := b.retrievePosition(gameID, query.Get("ply"), query.Get("position"))
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		board = newGameImage(gm, ply)
+		if options.Panel {
+			board.panel = newPanel(gm, ply)
+			if ply == len(gm.Moves()) && gm.Outcome() == chess.NoOutcome {
+				caching = revalidateCacheControl
+			}
+		}
+	} else {
+		fenOption, err := chess.FEN(query.Get("fen"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		board = boardImage{
+			board: chess.NewGame(fenOption).Position().Board(),
+			from:  parseSquare(query.Get("from")),
+			to:    parseSquare(query.Get("to")),
+			check: parseSquare(query.Get("check")),
+		}
+	}
+	board.inverted = options.Inverted
+	board.style = options.Style
+	board.annotations = options.Annotations
+	format, contentType, encode := "png", "image/png", board.encodePNG
+	if query.Get("format") == "svg" {
+		format, contentType, encode = "svg", "image/svg+xml", board.encodeSVG
+	}
+	key := board.cacheKey(format)
+	etag := `"` + key + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var data []byte
+	var err error
+	if b.Cache != nil {
+		data, err = b.Cache.Get(key, encode)
+	} else {
+		data, err = encode()
+	}
 	if err != nil {
+		log.Println(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if from := query.Get("from"); from != "" {
-		to := query.Get("to")
-		tFrom, _ := chessimage.TileFromAN(from)
-		tTo, _ := chessimage.TileFromAN(to)
-		board.SetLastMove(chessimage.LastMove{
-			From: tFrom,
-			To:   tTo,
-		})
-	}
-	if check := query.Get("check"); check != "" {
-		tCheck, _ := chessimage.TileFromAN(check)
-		board.SetCheckTile(tCheck)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", caching)
+	if _, err := w.Write(data); err != nil {
+		log.Println(err)
 	}
+}
 
-	inverted := false //query.Get("inverted") == "true"
-	// Doubled render to fix font resolve issue
-	image, err := board.Render(chessimage.Options{AssetPath: "./assets/", Inverted: inverted})
-	image, err = board.Render(chessimage.Options{AssetPath: "./assets/", Inverted: inverted})
+// retrievePosition looks up a stored game and validates the requested ply and the moves leading to it,
+// returning the HTTP status to respond with
+func (b BoardRenderHandler) retrievePosition(gameID string, plyParam string, position string) (*game.Game, int, int) {
+	if b.GameStorage == nil {
+		return nil, 0, http.StatusNotFound
+	}
+	gm, err := b.GameStorage.RetrieveGame(gameID)
 	if err != nil {
-		log.Println(err)
+		return nil, 0, http.StatusNotFound
+	}
+	ply, err := strconv.Atoi(plyParam)
+	if err != nil {
+		return nil, 0, http.StatusBadRequest
+	}
+	if ply < 0 || ply > len(gm.Moves()) || positionHash(gm, ply) != position {
+		return nil, 0, http.StatusNotFound
+	}
+	return gm, ply, http.StatusOK
+}
+
+// squareName returns the algebraic coordinates of a square, empty for chess.NoSquare
+func squareName(sq chess.Square) string {
+	if sq == chess.NoSquare {
+		return ""
 	}
-	png.Encode(w, image)
+	return sq.String()
 }
@@ -0,0 +1,185 @@
+package rendering
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/fogleman/gg"
+	"github.com/notnil/chess"
+)
+
+// panelWidth is the width of the side panel drawn next to the board
+const panelWidth = 224
+
+// panelMoves is how many of the most recent plies are listed in the side panel
+const panelMoves = 12
+
+// capturedPieceSize is the size of the captured pieces listed in the side panel
+const capturedPieceSize = 24
+
+var pieceValues = map[chess.PieceType]int{
+	chess.Queen:  9,
+	chess.Rook:   5,
+	chess.Bishop: 3,
+	chess.Knight: 3,
+	chess.Pawn:   1,
+}
+
+// capturedOrder lists the types of captured pieces, most valuable first
+var capturedOrder = []chess.PieceType{chess.Queen, chess.Rook, chess.Bishop, chess.Knight, chess.Pawn}
+
+// panel is the game summary drawn next to a board: the players, their captures, the material balance,
+// whose turn it is and the last few moves
+type panel struct {
+	players map[chess.Color]string
+	// captured holds the opposing pieces captured by each side
+	captured map[chess.Color][]chess.Piece
+	// material is the material balance, positive when white is ahead
+	material int
+	status   string
+	moves    []string
+}
+
+// newPanel summarizes the game as it was after the given number of plies
+func newPanel(gm *game.Game, ply int) *panel {
+	position := gm.Positions()[ply]
+	p := &panel{
+		players: map[chess.Color]string{
+			chess.White: strings.Join(gm.Players[game.White].Members(), ", "),
+			chess.Black: strings.Join(gm.Players[game.Black].Members(), ", "),
+		},
+		captured: map[chess.Color][]chess.Piece{},
+		status:   fmt.Sprintf("%v to move", position.Turn().Name()),
+	}
+	for _, piece := range position.Board().SquareMap() {
+		if piece.Color() == chess.White {
+			p.material += pieceValues[piece.Type()]
+		} else {
+			p.material -= pieceValues[piece.Type()]
+		}
+	}
+	// captures are taken from the moves, counting missing pieces would list promoted pawns as captured
+	captured := capturedPieces(gm, ply)
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		for _, pieceType := range capturedOrder {
+			piece := pieceOf(pieceType, color.Other())
+			for i := 0; i < captured[piece]; i++ {
+				p.captured[color] = append(p.captured[color], piece)
+			}
+		}
+	}
+	if ply == len(gm.Moves()) && gm.Outcome() != chess.NoOutcome {
+		p.status = fmt.Sprintf("%v by %v", gm.Outcome(), gm.Method())
+	}
+	p.moves = panelMoveList(gm, ply)
+	return p
+}
+
+// capturedPieces counts the pieces captured by the moves played before the given ply
+func capturedPieces(gm *game.Game, ply int) map[chess.Piece]int {
+	positions, moves := gm.Positions(), gm.Moves()
+	captured := map[chess.Piece]int{}
+	for i, move := range moves[:ply] {
+		if move.HasTag(chess.EnPassant) {
+			captured[pieceOf(chess.Pawn, positions[i].Turn().Other())]++
+		} else if move.HasTag(chess.Capture) {
+			captured[positions[i].Board().Piece(move.S2())]++
+		}
+	}
+	return captured
+}
+
+func pieceOf(pieceType chess.PieceType, color chess.Color) chess.Piece {
+	for piece := range pieceFiles {
+		if piece.Type() == pieceType && piece.Color() == color {
+			return piece
+		}
+	}
+	return chess.NoPiece
+}
+
+// panelMoveList returns the last moves before the given ply in SAN, one line per move number
+func panelMoveList(gm *game.Game, ply int) []string {
+	positions, moves := gm.Positions(), gm.Moves()
+	first := ply - panelMoves
+	if first < 0 {
+		first = 0
+	}
+	lines := []string{}
+	for i := first; i < ply; i++ {
+		san := chess.AlgebraicNotation{}.Encode(positions[i], moves[i])
+		switch {
+		case positions[i].Turn() == chess.White:
+			lines = append(lines, fmt.Sprintf("%v. %v", i/2+1, san))
+		case i == first:
+			lines = append(lines, fmt.Sprintf("%v... %v", i/2+1, san))
+		default:
+			lines[len(lines)-1] += "  " + san
+		}
+	}
+	return lines
+}
+
+// draw renders the panel at the right of the board, the side shown at the bottom of the board is listed at the bottom
+func (p *panel) draw(dc *gg.Context, theme Theme, pieceDir string, inverted bool) error {
+	left := float64(boardSize)
+	dc.DrawRectangle(left, 0, panelWidth, boardSize)
+	dc.SetHexColor(theme.Light)
+	dc.Fill()
+	top, bottom := chess.Black, chess.White
+	if inverted {
+		top, bottom = chess.White, chess.Black
+	}
+	if err := p.drawCaptures(dc, top, 34, pieceDir); err != nil {
+		return err
+	}
+	if err := p.drawCaptures(dc, bottom, boardSize-8-capturedPieceSize, pieceDir); err != nil {
+		return err
+	}
+	if err := dc.LoadFontFace(assetPath+"arial.ttf", 16); err != nil {
+		// The captured pieces are still shown without text
+		log.Println(err)
+		return nil
+	}
+	dc.SetHexColor(theme.Coordinates)
+	p.drawPlayer(dc, top, 20)
+	p.drawPlayer(dc, bottom, boardSize-8-capturedPieceSize-14)
+	dc.DrawString(p.status, left+10, 100)
+	for i, line := range p.moves {
+		dc.DrawString(line, left+10, float64(130+i*20))
+	}
+	return nil
+}
+
+// drawCaptures lists the pieces captured by the color
+func (p *panel) drawCaptures(dc *gg.Context, color chess.Color, y int, pieceDir string) error {
+	x := boardSize + 10
+	for _, piece := range p.captured[color] {
+		img, err := loadPiece(pieceDir+pieceFiles[piece], capturedPieceSize)
+		if err != nil {
+			return err
+		}
+		dc.DrawImage(img, x, y)
+		x += capturedPieceSize * 3 / 5
+	}
+	return nil
+}
+
+// drawPlayer names the player of the color followed by their material advantage
+func (p *panel) drawPlayer(dc *gg.Context, color chess.Color, y float64) {
+	name := p.players[color]
+	if runes := []rune(name); len(runes) > 20 {
+		name = string(runes[:19]) + "…"
+	}
+	text := fmt.Sprintf("%v: %v", color.Name(), name)
+	advantage := p.material
+	if color == chess.Black {
+		advantage = -advantage
+	}
+	if advantage > 0 {
+		text += fmt.Sprintf(" (+%v)", advantage)
+	}
+	dc.DrawString(text, float64(boardSize)+10, y)
+}
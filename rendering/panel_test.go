@@ -0,0 +1,86 @@
+package rendering_test
+
+import (
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func renderPanel(t *testing.T, gm *game.Game) image.Image {
+	store := game.NewMemoryStore()
+	store.StoreGame(gm.ID, gm)
+	linkRenderer := rendering.NewRenderLink("http://localhost", "secret")
+	link, _ := linkRenderer.CreateGameLink(gm, len(gm.Moves()), rendering.LinkOptions{Panel: true})
+	rec := httptest.NewRecorder()
+	rendering.BoardRenderHandler{LinkRenderer: linkRenderer, GameStorage: store}.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", rec.Code)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func sameRegion(a image.Image, b image.Image, region image.Rectangle) bool {
+	for x := region.Min.X; x < region.Max.X; x++ {
+		for y := region.Min.Y; y < region.Max.Y; y++ {
+			if a.At(x, y) != b.At(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// the captures of black are listed at the top of the panel, the ones of white at the bottom
+var (
+	blackCaptures = image.Rect(512, 30, 736, 62)
+	whiteCaptures = image.Rect(512, 476, 736, 508)
+)
+
+func TestPanelListsCapturesFromMoves(t *testing.T) {
+	players := []game.Player{{ID: " U1 "}, {ID: " U2 "}}
+	start := renderPanel(t, game.NewGame("start", players[0], players[1]))
+	// the a pawn captures its way to a8 and promotes, black never captures
+	gm, err := game.NewGameFromPGN("1234", "1. a4 b5 2. axb5 a6 3. bxa6 Bb7 4. axb7 Nc6 5. bxa8=Q *", players[0], players[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	promoted := renderPanel(t, gm)
+	if !sameRegion(start, promoted, blackCaptures) {
+		t.Error("expected the promoted pawn not to be listed as captured by black")
+	}
+	if sameRegion(start, promoted, whiteCaptures) {
+		t.Error("expected the captures of white to be listed")
+	}
+}
+
+// newNamedGame starts a game without shuffling the players, so white is named as given
+func newNamedGame(t *testing.T, white string) *game.Game {
+	gm, err := game.NewGameFromPGN("1234", "*", game.Player{ID: " " + white + " "}, game.Player{ID: " U2 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gm
+}
+
+func TestPanelTruncatesNamesByRune(t *testing.T) {
+	truncated := renderPanel(t, newNamedGame(t, strings.Repeat("í", 25)))
+	expected := renderPanel(t, newNamedGame(t, strings.Repeat("í", 19)+"…"))
+	if !sameRegion(truncated, expected, truncated.Bounds()) {
+		t.Error("expected long names to be cut after 19 characters")
+	}
+	// 20 characters take 40 bytes, but still fit
+	fitting := renderPanel(t, newNamedGame(t, strings.Repeat("í", 20)))
+	if sameRegion(fitting, expected, fitting.Bounds()) {
+		t.Error("expected names of 20 characters to be shown in full")
+	}
+}
@@ -0,0 +1,189 @@
+package rendering
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/fogleman/gg"
+	"github.com/notnil/chess"
+	"golang.org/x/image/draw"
+)
+
+const assetPath = "./assets/"
+
+// Board dimensions match the boards previously rendered by chessimage
+const (
+	boardSize  = 512
+	squareSize = boardSize / 8
+	pieceSize  = squareSize * 4 / 5
+)
+
+var pieceFiles = map[chess.Piece]string{
+	chess.WhiteKing:   "kl.png",
+	chess.WhiteQueen:  "ql.png",
+	chess.WhiteRook:   "rl.png",
+	chess.WhiteBishop: "bl.png",
+	chess.WhiteKnight: "nl.png",
+	chess.WhitePawn:   "pl.png",
+	chess.BlackKing:   "kd.png",
+	chess.BlackQueen:  "qd.png",
+	chess.BlackRook:   "rd.png",
+	chess.BlackBishop: "bd.png",
+	chess.BlackKnight: "nd.png",
+	chess.BlackPawn:   "pd.png",
+}
+
+// pieceCache holds piece images already scaled, keyed by file path and size
+var pieceCache = struct {
+	sync.Mutex
+	images map[string]image.Image
+}{images: make(map[string]image.Image)}
+
+// boardImage describes everything drawn on a raster board
+type boardImage struct {
+	board       *chess.Board
+	from        chess.Square
+	to          chess.Square
+	check       chess.Square
+	inverted    bool
+	style       Style
+	annotations Annotations
+	// panel is drawn at the right of the board when set
+	panel *panel
+}
+
+// RenderGame writes a PNG image of the current game state, highlighting the last move and any check
+func RenderGame(w io.Writer, gm *game.Game) error {
+	positions := gm.Positions()
+	img, err := newBoardImage(positions[len(positions)-1], gm.LastMove()).render()
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// newGameImage prepares a board of the game as it was after the given number of plies
+func newGameImage(gm *game.Game, ply int) boardImage {
+	var lastMove *chess.Move
+	if ply > 0 {
+		lastMove = gm.Moves()[ply-1]
+	}
+	return newBoardImage(gm.Positions()[ply], lastMove)
+}
+
+// newBoardImage prepares a board of the position, highlighting the move leading to it and any check
+func newBoardImage(position *chess.Position, lastMove *chess.Move) boardImage {
+	board := boardImage{
+		board: position.Board(),
+		from:  chess.NoSquare,
+		to:    chess.NoSquare,
+		check: chess.NoSquare,
+	}
+	if lastMove != nil {
+		board.from = lastMove.S1()
+		board.to = lastMove.S2()
+		if lastMove.HasTag(chess.Check) {
+			board.check = kingSquare(position, position.Turn())
+		}
+	}
+	return board
+}
+
+func kingSquare(position *chess.Position, color chess.Color) chess.Square {
+	for square, piece := range position.Board().SquareMap() {
+		if piece.Type() == chess.King && piece.Color() == color {
+			return square
+		}
+	}
+	return chess.NoSquare
+}
+
+// squarePosition returns the column and row of a square as drawn, counted from the top left corner
+func squarePosition(sq chess.Square, inverted bool) (int, int) {
+	col, row := int(sq.File()), 7-int(sq.Rank())
+	if inverted {
+		col, row = 7-col, 7-row
+	}
+	return col, row
+}
+
+func (b boardImage) render() (image.Image, error) {
+	theme, pieceDir := b.style.resolve()
+	width := boardSize
+	if b.panel != nil {
+		width += panelWidth
+	}
+	dc := gg.NewContext(width, boardSize)
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		col, row := squarePosition(sq, b.inverted)
+		fill := theme.Dark
+		if (int(sq.File())+int(sq.Rank()))%2 == 1 {
+			fill = theme.Light
+		}
+		switch sq {
+		case b.check:
+			fill = theme.Check
+		case b.from:
+			fill = theme.Highlight
+		case b.to:
+			fill = theme.HighlightDim
+		}
+		dc.DrawRectangle(float64(col*squareSize), float64(row*squareSize), squareSize, squareSize)
+		dc.SetHexColor(fill)
+		dc.Fill()
+	}
+	b.annotations.drawMarks(dc, b.inverted)
+	if err := dc.LoadFontFace(assetPath+"arial.ttf", 18); err != nil {
+		// The board is still usable without coordinates
+		log.Println(err)
+	} else {
+		files, ranks := "abcdefgh", "87654321"
+		if b.inverted {
+			files, ranks = "hgfedcba", "12345678"
+		}
+		dc.SetHexColor(theme.Coordinates)
+		for i := range files {
+			dc.DrawString(files[i:i+1], float64(squareSize*(i+1)-12), float64(boardSize-3))
+			dc.DrawString(ranks[i:i+1], 2, float64(squareSize*i+15))
+		}
+	}
+	pieceOffset := (squareSize - pieceSize) / 2
+	for sq, piece := range b.board.SquareMap() {
+		img, err := loadPiece(pieceDir+pieceFiles[piece], pieceSize)
+		if err != nil {
+			return nil, err
+		}
+		col, row := squarePosition(sq, b.inverted)
+		dc.DrawImage(img, col*squareSize+pieceOffset, row*squareSize+pieceOffset)
+	}
+	b.annotations.drawArrows(dc, b.inverted)
+	if b.panel != nil {
+		if err := b.panel.draw(dc, theme, pieceDir, b.inverted); err != nil {
+			return nil, err
+		}
+	}
+	return dc.Image(), nil
+}
+
+func loadPiece(path string, size int) (image.Image, error) {
+	pieceCache.Lock()
+	defer pieceCache.Unlock()
+	key := fmt.Sprintf("%v@%v", path, size)
+	if img, ok := pieceCache.images[key]; ok {
+		return img, nil
+	}
+	src, err := gg.LoadPNG(path)
+	if err != nil {
+		return nil, err
+	}
+	rect := image.Rect(0, 0, size, size)
+	dst := image.NewRGBA(rect)
+	draw.BiLinear.Scale(dst, rect, src, src.Bounds(), draw.Over, nil)
+	pieceCache.images[key] = dst
+	return dst, nil
+}
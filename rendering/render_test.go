@@ -0,0 +1,92 @@
+package rendering_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+// squareSize is the size of a square on the 512 pixel board
+const squareSize = 64
+
+func renderBoard(t *testing.T, gm *game.Game, options rendering.LinkOptions) image.Image {
+	linkRenderer := rendering.NewRenderLink("http://localhost", "secret")
+	link, _ := linkRenderer.CreateLinkWithOptions(gm, options)
+	rec := httptest.NewRecorder()
+	rendering.BoardRenderHandler{LinkRenderer: linkRenderer}.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", rec.Code)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// squareColor returns the color near the top left corner of a square as drawn, away from pieces and coordinates
+func squareColor(img image.Image, col int, row int) string {
+	c := color.RGBAModel.Convert(img.At(col*squareSize+8, row*squareSize+3)).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func TestBoardUsesThemeColors(t *testing.T) {
+	// the bishop checks the king from f7
+	gm, err := game.NewGameFromPGN("1234", "1. e4 e5 2. Bc4 Nc6 3. Bxf7+ *", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, theme := range rendering.Themes() {
+		img := renderBoard(t, gm, rendering.LinkOptions{Style: rendering.Style{Theme: theme.Name}})
+		for _, test := range []struct {
+			square string
+			col    int
+			row    int
+			color  string
+		}{
+			{"d5", 3, 3, theme.Light},
+			{"d4", 3, 4, theme.Dark},
+			{"c4", 2, 4, theme.Highlight},
+			{"f7", 5, 1, theme.HighlightDim},
+			{"e8", 4, 0, theme.Check},
+		} {
+			if actual := squareColor(img, test.col, test.row); actual != test.color {
+				t.Errorf("expected %v to be %v with the %v theme, got %v", test.square, test.color, theme.Name, actual)
+			}
+		}
+	}
+
+	inverted := renderBoard(t, gm, rendering.LinkOptions{Inverted: true})
+	classic, _ := rendering.LookupTheme(rendering.DefaultTheme)
+	if actual := squareColor(inverted, 3, 7); actual != classic.Check {
+		t.Errorf("expected the checked king at the bottom of an inverted board, got %v", actual)
+	}
+}
+
+func TestBoardUsesPieceSet(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	standard := renderBoard(t, gm, rendering.LinkOptions{Style: rendering.Style{Pieces: "standard"}})
+	contrast := renderBoard(t, gm, rendering.LinkOptions{Style: rendering.Style{Pieces: "contrast"}})
+	// the white king on e1
+	differences := 0
+	for x := 4 * squareSize; x < 5*squareSize; x++ {
+		for y := 7 * squareSize; y < 8*squareSize; y++ {
+			if standard.At(x, y) != contrast.At(x, y) {
+				differences++
+			}
+		}
+	}
+	if differences == 0 {
+		t.Error("expected the piece sets to draw different pieces")
+	}
+	if squareColor(standard, 4, 7) != squareColor(contrast, 4, 7) {
+		t.Error("expected the piece set to leave the board colors unchanged")
+	}
+}
@@ -1,10 +1,14 @@
 package rendering
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/cjsaylor/chessbot/game"
 	"github.com/notnil/chess"
@@ -14,13 +18,37 @@ import (
 type RenderLink struct {
 	hostName   string
 	signingKey string
+	// expiry is how long game links stay valid, zero when they never expire
+	expiry time.Duration
 }
 
 // CreateLink returns an externally accessible board URL at the current game state
+// The board is shown from the side of the player to move.
 func (r RenderLink) CreateLink(gm *game.Game) (*url.URL, error) {
+	return r.CreateOrientedLink(gm, gm.Turn() == game.Black)
+}
+
+// LinkOptions are the presentation choices signed into a board URL
+type LinkOptions struct {
+	// Inverted places black at the bottom of the board
+	Inverted bool
+	Style    Style
+	// Annotations are arrows and highlighted squares drawn on the board
+	Annotations Annotations
+	// Panel adds a side panel with the players, captures and recent moves, it only applies to game links
+	Panel bool
+	// Expires limits how long a board link is valid, links created by a RenderLink with an expiry default to it
+	Expires time.Time
+}
+
+// CreateOrientedLink returns a board URL at the current game state, with black at the bottom when inverted
+func (r RenderLink) CreateOrientedLink(gm *game.Game, inverted bool) (*url.URL, error) {
+	return r.CreateLinkWithOptions(gm, LinkOptions{Inverted: inverted})
+}
+
+// CreateLinkWithOptions returns a board URL at the current game state with the given presentation
+func (r RenderLink) CreateLinkWithOptions(gm *game.Game, options LinkOptions) (*url.URL, error) {
 	fen := gm.FEN()
-	sig := sha256.New()
-	sig.Write([]byte(fen + r.signingKey))
 	from, to, check := "", "", ""
 	if lastMove := gm.LastMove(); lastMove != nil {
 		from = lastMove.S1().String()
@@ -33,22 +61,168 @@ func (r RenderLink) CreateLink(gm *game.Game) (*url.URL, error) {
 	u, _ := url.Parse(fmt.Sprintf("%v/board", r.hostName))
 	q := u.Query()
 	q.Add("fen", fen)
-	q.Add("signature", hex.EncodeToString(sig.Sum(nil)))
 	q.Add("from", from)
 	q.Add("to", to)
 	q.Add("check", check)
-	/*if gm.Turn() == game.Black {
-		q.Add("inverted", "true")
-	}*/
+	addOptions(q, r.withDefaultExpiry(options))
+	q.Add("signature", r.sign(boardLink, q))
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// CreateGameLink returns a board URL of a stored game as it was after the given number of plies.
+// The position, last move and check are resolved from storage when the board is rendered, so none of them can be
+// forged, and the link keeps showing that position as the game goes on. The moves leading to the position are
+// hashed into the link, so a position replaced by a take back gets a new URL instead of a cached image.
+func (r RenderLink) CreateGameLink(gm *game.Game, ply int, options LinkOptions) (*url.URL, error) {
+	if ply < 0 || ply > len(gm.Moves()) {
+		return nil, ErrPlyOutOfRange
+	}
+	u, _ := url.Parse(fmt.Sprintf("%v/board", r.hostName))
+	q := u.Query()
+	q.Add("game_id", gm.ID)
+	q.Add("ply", strconv.Itoa(ply))
+	q.Add("position", positionHash(gm, ply))
+	addOptions(q, r.withDefaultExpiry(options))
+	if options.Panel {
+		q.Add("panel", "true")
+	}
+	q.Add("signature", r.sign(boardLink, q))
 	u.RawQuery = q.Encode()
 	return u, nil
 }
 
+// ErrPlyOutOfRange is returned for links to a position the game has not reached
+var ErrPlyOutOfRange = errors.New("the game has no position at that ply")
+
+// positionHash identifies the moves leading to a position of a game
+func positionHash(gm *game.Game, ply int) string {
+	sum := sha256.New()
+	for _, move := range gm.Moves()[:ply] {
+		sum.Write([]byte(move.String() + "\n"))
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:16]
+}
+
+// withDefaultExpiry sets the expiry of the RenderLink on options without one
+func (r RenderLink) withDefaultExpiry(options LinkOptions) LinkOptions {
+	if options.Expires.IsZero() && r.expiry > 0 {
+		options.Expires = time.Now().Add(r.expiry)
+	}
+	return options
+}
+
+func addOptions(q url.Values, options LinkOptions) {
+	if options.Inverted {
+		q.Add("inverted", "true")
+	}
+	if options.Style.Theme != "" {
+		q.Add("theme", options.Style.Theme)
+	}
+	if options.Style.Pieces != "" {
+		q.Add("pieces", options.Style.Pieces)
+	}
+	arrows, squares := options.Annotations.encode()
+	if arrows != "" {
+		q.Add("arrows", arrows)
+	}
+	if squares != "" {
+		q.Add("squares", squares)
+	}
+	if !options.Expires.IsZero() {
+		q.Add("expires", strconv.FormatInt(options.Expires.Unix(), 10))
+	}
+}
+
+// Kinds of links, signed along with their parameters so that a signature is only valid for the kind it was made for
+const (
+	boardLink      = "board"
+	replayLink     = "replay"
+	evaluationLink = "evaluation"
+)
+
+// unsignedParameters are left to the client, they change how a board or replay is encoded but not what it shows
+var unsignedParameters = map[string]bool{
+	"signature": true,
+	"format":    true,
+	"delay":     true,
+}
+
+// sign returns the HMAC of the kind and every parameter of a link, so that none can be added, removed or changed
+func (r RenderLink) sign(kind string, query url.Values) string {
+	signed := url.Values{}
+	for key, values := range query {
+		if !unsignedParameters[key] {
+			signed[key] = values
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(r.signingKey))
+	mac.Write([]byte(kind + "?" + signed.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validSignature compares the signature of a link in constant time
+func (r RenderLink) validSignature(kind string, query url.Values) bool {
+	return hmac.Equal([]byte(r.sign(kind, query)), []byte(query.Get("signature")))
+}
+
 // ValidateLink ensures that the link signatuer is signed properly with the app signing key
+// Links are also refused once they expired.
 func (r RenderLink) ValidateLink(url url.URL) bool {
-	sig := sha256.New()
-	sig.Write([]byte(url.Query().Get("fen") + r.signingKey))
-	return hex.EncodeToString(sig.Sum(nil)) == url.Query().Get("signature")
+	if !r.validSignature(boardLink, url.Query()) {
+		return false
+	}
+	options := ParseLinkOptions(url)
+	return options.Expires.IsZero() || time.Now().Before(options.Expires)
+}
+
+// ParseLinkOptions reads the presentation options of a board URL
+func ParseLinkOptions(url url.URL) LinkOptions {
+	query := url.Query()
+	options := LinkOptions{
+		Inverted:    query.Get("inverted") == "true",
+		Style:       Style{Theme: query.Get("theme"), Pieces: query.Get("pieces")},
+		Annotations: ParseAnnotations(query.Get("arrows"), query.Get("squares")),
+		Panel:       query.Get("panel") == "true",
+	}
+	if expires, err := strconv.ParseInt(query.Get("expires"), 10, 64); err == nil {
+		options.Expires = time.Unix(expires, 0)
+	}
+	return options
+}
+
+// CreateReplayLink returns an externally accessible URL of the animated replay of the whole game
+func (r RenderLink) CreateReplayLink(gm *game.Game) (*url.URL, error) {
+	u, _ := url.Parse(fmt.Sprintf("%v/replay", r.hostName))
+	q := u.Query()
+	q.Add("game_id", gm.ID)
+	// The ply count keeps clients from showing a cached replay of an earlier state
+	q.Add("ply", fmt.Sprint(len(gm.Moves())))
+	q.Add("signature", r.sign(replayLink, q))
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// ValidateReplayLink ensures that the replay link is signed properly with the app signing key
+func (r RenderLink) ValidateReplayLink(url url.URL) bool {
+	return r.validSignature(replayLink, url.Query())
+}
+
+// CreateEvaluationLink returns an externally accessible URL of the evaluation graph of the whole game
+func (r RenderLink) CreateEvaluationLink(gm *game.Game) (*url.URL, error) {
+	u, _ := url.Parse(fmt.Sprintf("%v/evaluation", r.hostName))
+	q := u.Query()
+	q.Add("game_id", gm.ID)
+	// The ply count keeps clients from showing a cached graph of an earlier state
+	q.Add("ply", fmt.Sprint(len(gm.Moves())))
+	q.Add("signature", r.sign(evaluationLink, q))
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// ValidateEvaluationLink ensures that the evaluation link is signed properly with the app signing key
+func (r RenderLink) ValidateEvaluationLink(url url.URL) bool {
+	return r.validSignature(evaluationLink, url.Query())
 }
 
 // NewRenderLink creates a new RenderLink struct instance
@@ -58,3 +232,9 @@ func NewRenderLink(hostname string, signingKey string) RenderLink {
 		signingKey: signingKey,
 	}
 }
+
+// WithExpiry returns a copy of the RenderLink whose board links expire after the given duration
+func (r RenderLink) WithExpiry(expiry time.Duration) RenderLink {
+	r.expiry = expiry
+	return r
+}
@@ -0,0 +1,164 @@
+package rendering_test
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func TestLinkSignatureCoversOrientation(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.Move("e2e4")
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, _ := linkRenderer.CreateLink(gm)
+	if link.Query().Get("inverted") != "true" || !linkRenderer.ValidateLink(*link) {
+		t.Fatalf("expected a valid flipped board with black to move, got %v", link)
+	}
+	tampered := *link
+	query := tampered.Query()
+	query.Del("inverted")
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateLink(tampered) {
+		t.Error("expected the signature to cover the orientation")
+	}
+}
+
+func TestLinkSignatureCoversEveryParameter(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.Move("e2e4")
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, _ := linkRenderer.CreateOrientedLink(gm, false)
+	for _, change := range []url.Values{{"from": {"d2"}}, {"to": {"d4"}}, {"check": {"e8"}}, {"extra": {"1"}}} {
+		tampered := *link
+		query := tampered.Query()
+		for key, values := range change {
+			query[key] = values
+		}
+		tampered.RawQuery = query.Encode()
+		if linkRenderer.ValidateLink(tampered) {
+			t.Errorf("expected the signature to cover %v", change)
+		}
+	}
+	replay, _ := linkRenderer.CreateReplayLink(gm)
+	evaluation, _ := linkRenderer.CreateEvaluationLink(gm)
+	if !linkRenderer.ValidateReplayLink(*replay) || !linkRenderer.ValidateEvaluationLink(*evaluation) {
+		t.Fatalf("expected valid replay and evaluation links, got %v and %v", replay, evaluation)
+	}
+	if linkRenderer.ValidateEvaluationLink(*replay) {
+		t.Error("expected the signature of a replay link to be refused for an evaluation")
+	}
+	tampered := *replay
+	query := tampered.Query()
+	query.Set("ply", "0")
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateReplayLink(tampered) {
+		t.Error("expected the signature to cover the ply of the replay")
+	}
+}
+
+func TestLinkSignatureCoversStyle(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, _ := linkRenderer.CreateLinkWithOptions(gm, rendering.LinkOptions{Style: rendering.Style{Theme: "dark", Pieces: "contrast"}})
+	if link.Query().Get("theme") != "dark" || !linkRenderer.ValidateLink(*link) {
+		t.Fatalf("expected a valid dark themed board, got %v", link)
+	}
+	tampered := *link
+	query := tampered.Query()
+	query.Set("theme", "classic")
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateLink(tampered) {
+		t.Error("expected the signature to cover the theme")
+	}
+}
+
+func TestLinkSignatureCoversAnnotations(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, _ := linkRenderer.CreateLinkWithOptions(gm, rendering.LinkOptions{Annotations: rendering.Annotations{
+		Arrows: []rendering.Arrow{{From: "e2", To: "e4"}, {From: "d7", To: "d5", Color: "red"}},
+		Marks:  []rendering.Mark{{Square: "f7", Color: "blue"}},
+	}})
+	if link.Query().Get("arrows") != "e2e4:green,d7d5:red" || link.Query().Get("squares") != "f7:blue" {
+		t.Fatalf("expected encoded annotations, got %v", link)
+	}
+	if !linkRenderer.ValidateLink(*link) {
+		t.Fatal("expected the annotated link to be valid")
+	}
+	tampered := *link
+	query := tampered.Query()
+	query.Set("arrows", "e2e4:green,d8h4:red")
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateLink(tampered) {
+		t.Error("expected the signature to cover the annotations")
+	}
+}
+
+func TestGameLinkRendersPanel(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	for _, move := range []string{"e2e4", "d7d5", "e4d5", "d8d5"} {
+		gm.Move(move)
+	}
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, err := linkRenderer.CreateGameLink(gm, 3, rendering.LinkOptions{Panel: true})
+	if err != nil || link.Query().Get("ply") != "3" || !linkRenderer.ValidateLink(*link) {
+		t.Fatalf("expected a valid link to the third ply, got %v (%v)", link, err)
+	}
+	tampered := *link
+	query := tampered.Query()
+	query.Set("ply", "4")
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateLink(tampered) {
+		t.Error("expected the signature to cover the ply")
+	}
+	if _, err := linkRenderer.CreateGameLink(gm, 5, rendering.LinkOptions{}); err != rendering.ErrPlyOutOfRange {
+		t.Errorf("expected links past the last move to be refused, got %v", err)
+	}
+}
+
+func TestGameLinkExpires(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	linkRenderer := rendering.NewRenderLink("", "secret").WithExpiry(time.Hour)
+	link, _ := linkRenderer.CreateGameLink(gm, 0, rendering.LinkOptions{})
+	if link.Query().Get("expires") == "" || !linkRenderer.ValidateLink(*link) {
+		t.Fatalf("expected a valid link with an expiry, got %v", link)
+	}
+	tampered := *link
+	query := tampered.Query()
+	query.Set("expires", fmt.Sprint(time.Now().Add(24*time.Hour).Unix()))
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateLink(tampered) {
+		t.Error("expected the signature to cover the expiry")
+	}
+	expired, _ := linkRenderer.CreateGameLink(gm, 0, rendering.LinkOptions{Expires: time.Now().Add(-time.Minute)})
+	if linkRenderer.ValidateLink(*expired) {
+		t.Error("expected expired links to be refused")
+	}
+}
+
+func TestPositionLinkExpires(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	linkRenderer := rendering.NewRenderLink("", "secret").WithExpiry(time.Hour)
+	link, _ := linkRenderer.CreateLink(gm)
+	if link.Query().Get("fen") == "" || link.Query().Get("expires") == "" || !linkRenderer.ValidateLink(*link) {
+		t.Fatalf("expected a valid position link with an expiry, got %v", link)
+	}
+	tampered := *link
+	query := tampered.Query()
+	query.Del("expires")
+	tampered.RawQuery = query.Encode()
+	if linkRenderer.ValidateLink(tampered) {
+		t.Error("expected the signature to cover the expiry")
+	}
+	expired, _ := linkRenderer.CreateLinkWithOptions(gm, rendering.LinkOptions{Expires: time.Now().Add(-time.Minute)})
+	if linkRenderer.ValidateLink(*expired) {
+		t.Error("expected expired links to be refused")
+	}
+	if permanent, _ := rendering.NewRenderLink("", "secret").CreateLink(gm); permanent.Query().Get("expires") != "" {
+		t.Errorf("expected links to never expire without an expiry, got %v", permanent)
+	}
+}
@@ -0,0 +1,111 @@
+package rendering
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// DefaultFrameDelay is how long each ply of a replay is shown unless configured otherwise
+const DefaultFrameDelay = time.Second
+
+// finalFrameHold is how many frame delays the final position of a replay stays on screen before looping
+const finalFrameHold = 3
+
+// RenderReplay writes an animated GIF of the game with one frame per ply, highlighting each move and any check
+func RenderReplay(w io.Writer, gm *game.Game, delay time.Duration) error {
+	positions := gm.Positions()
+	moves := gm.Moves()
+	// GIF delays are expressed in hundredths of a second
+	frameDelay := int(delay / (10 * time.Millisecond))
+	replay := &gif.GIF{}
+	for i, position := range positions {
+		var lastMove *chess.Move
+		if i > 0 {
+			lastMove = moves[i-1]
+		}
+		frame, err := newBoardImage(position, lastMove).render()
+		if err != nil {
+			return err
+		}
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Rect, frame, frame.Bounds().Min, draw.Src)
+		replay.Image = append(replay.Image, paletted)
+		replay.Delay = append(replay.Delay, frameDelay)
+	}
+	replay.Delay[len(replay.Delay)-1] = frameDelay * finalFrameHold
+	return gif.EncodeAll(w, replay)
+}
+
+// ReplayRenderHandler serves animated replays of stored games
+type ReplayRenderHandler struct {
+	LinkRenderer RenderLink
+	GameStorage  game.GameStorage
+	FrameDelay   time.Duration
+	// Cache is optional, every ply of the game is drawn and encoded so replays should be cached
+	Cache *RenderCache
+}
+
+// ServeHTTP is a request handler
+func (h ReplayRenderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.LinkRenderer.ValidateReplayLink(*r.URL) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	gm, err := h.GameStorage.RetrieveGame(r.URL.Query().Get("game_id"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	delay := h.FrameDelay
+	if delay == 0 {
+		delay = DefaultFrameDelay
+	}
+	if milliseconds, err := strconv.Atoi(r.URL.Query().Get("delay")); err == nil && milliseconds > 0 {
+		delay = time.Duration(milliseconds) * time.Millisecond
+	}
+	render := func() ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := RenderReplay(buf, gm, delay)
+		return buf.Bytes(), err
+	}
+	var data []byte
+	if h.Cache != nil {
+		data, err = h.Cache.Get(replayCacheKey(gm, delay), render)
+	} else {
+		data, err = render()
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", cacheControl)
+	if _, err := w.Write(data); err != nil {
+		log.Println(err)
+	}
+}
+
+// replayCacheKey covers every move of the game and the frame delay, so the replay is redrawn after takebacks
+func replayCacheKey(gm *game.Game, delay time.Duration) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("replay\n%v\n%v\n%v", gm.ID, gm.PGN(), delay)))
+	return hex.EncodeToString(sum[:])
+}
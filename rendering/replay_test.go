@@ -0,0 +1,88 @@
+package rendering_test
+
+import (
+	"bytes"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+// TestMain runs the tests from the repository root, where the piece images are loaded from
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func replayGame(t *testing.T) *game.Game {
+	gm, err := game.NewGameFromPGN("1234", "1. e4 e5 *", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gm
+}
+
+func TestRenderReplayHoldsFinalFrame(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := rendering.RenderReplay(buf, replayGame(t), 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Image) != 3 {
+		t.Fatalf("expected a frame for the start and each ply, got %v", len(replay.Image))
+	}
+	if replay.Delay[0] != 20 || replay.Delay[1] != 20 || replay.Delay[2] != 60 {
+		t.Errorf("expected the final position to be held for three delays, got %v", replay.Delay)
+	}
+}
+
+func TestReplayHandler(t *testing.T) {
+	store := game.NewMemoryStore()
+	gm := replayGame(t)
+	store.StoreGame(gm.ID, gm)
+	linkRenderer := rendering.NewRenderLink("http://localhost", "secret")
+	cache := rendering.NewRenderCache(10, "")
+	handler := rendering.ReplayRenderHandler{
+		LinkRenderer: linkRenderer,
+		GameStorage:  store,
+		FrameDelay:   time.Second,
+		Cache:        cache,
+	}
+	link, _ := linkRenderer.CreateReplayLink(gm)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get(link.RequestURI() + "&delay=100"); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", rec.Code)
+	} else if replay, err := gif.DecodeAll(rec.Body); err != nil || replay.Delay[0] != 10 {
+		t.Errorf("expected the delay parameter to override the frame delay, got %v", err)
+	}
+	if rec := get(link.RequestURI() + "&delay=100"); rec.Code != http.StatusOK || cache.Stats().Hits != 1 {
+		t.Errorf("expected the replay to be served from the cache, got %v and %+v", rec.Code, cache.Stats())
+	}
+	rec := get(link.RequestURI())
+	if replay, err := gif.DecodeAll(rec.Body); err != nil || replay.Delay[0] != 100 {
+		t.Errorf("expected the configured frame delay, got %v", err)
+	}
+
+	tampered := *link
+	query := tampered.Query()
+	query.Set("game_id", "5678")
+	tampered.RawQuery = query.Encode()
+	if rec := get(tampered.RequestURI()); rec.Code != http.StatusForbidden {
+		t.Errorf("expected a bad signature to be refused, got %v", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package rendering
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/notnil/chess"
+)
+
+// svgSquareSize is the side of a single square in SVG user units
+const svgSquareSize = 64
+
+// svgPieceGlyphs are the filled Unicode chess symbols, followed by the text presentation selector
+// so that pawns are not drawn as emoji.
+var svgPieceGlyphs = map[chess.PieceType]string{
+	chess.King:   "♚︎",
+	chess.Queen:  "♛︎",
+	chess.Rook:   "♜︎",
+	chess.Bishop: "♝︎",
+	chess.Knight: "♞︎",
+	chess.Pawn:   "♟︎",
+}
+
+// SVGOptions controls the highlighting and orientation of an SVG board
+// Squares are given in algebraic coordinates (e.g. "e4"), empty when not highlighted.
+type SVGOptions struct {
+	From     string
+	To       string
+	Check    string
+	Inverted bool
+	// Style selects the colors of the board, piece sets only apply to raster boards
+	Style       Style
+	Annotations Annotations
+}
+
+// RenderSVG writes the board as a scalable SVG image, drawing pieces with Unicode glyphs so no font files are needed
+func RenderSVG(w io.Writer, board *chess.Board, options SVGOptions) error {
+	buf := &bytes.Buffer{}
+	size := svgSquareSize * 8
+	from, to, check := parseSquare(options.From), parseSquare(options.To), parseSquare(options.Check)
+	theme, _ := options.Style.resolve()
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %v %v" width="%v" height="%v">`, size, size, size, size)
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		x, y := svgSquareOrigin(sq, options.Inverted)
+		fill := theme.Dark
+		if (int(sq.File())+int(sq.Rank()))%2 == 1 {
+			fill = theme.Light
+		}
+		switch sq {
+		case check:
+			fill = theme.Check
+		case from:
+			fill = theme.Highlight
+		case to:
+			fill = theme.HighlightDim
+		}
+		fmt.Fprintf(buf, `<rect x="%v" y="%v" width="%v" height="%v" fill="%v"/>`, x, y, svgSquareSize, svgSquareSize, fill)
+	}
+	options.Annotations.writeSVGMarks(buf, options.Inverted)
+	writeSVGCoordinates(buf, options.Inverted, theme.Coordinates)
+	fmt.Fprint(buf, `<g font-family="DejaVu Sans, Segoe UI Symbol, Arial Unicode MS, sans-serif" font-size="52" text-anchor="middle" stroke="#000000" stroke-width="1.5">`)
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		piece := board.Piece(sq)
+		if piece == chess.NoPiece {
+			continue
+		}
+		x, y := svgSquareOrigin(sq, options.Inverted)
+		fill := "#000000"
+		if piece.Color() == chess.White {
+			fill = "#ffffff"
+		}
+		fmt.Fprintf(buf, `<text x="%v" y="%v" fill="%v">%v</text>`, x+svgSquareSize/2, y+svgSquareSize-12, fill, svgPieceGlyphs[piece.Type()])
+	}
+	fmt.Fprint(buf, `</g>`)
+	options.Annotations.writeSVGArrows(buf, options.Inverted)
+	fmt.Fprint(buf, `</svg>`)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// parseSquare returns the square of algebraic coordinates such as "e4", or chess.NoSquare
+func parseSquare(coordinates string) chess.Square {
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		if sq.String() == coordinates {
+			return sq
+		}
+	}
+	return chess.NoSquare
+}
+
+func svgSquareOrigin(sq chess.Square, inverted bool) (int, int) {
+	col, row := squarePosition(sq, inverted)
+	return col * svgSquareSize, row * svgSquareSize
+}
+
+// writeSVGCoordinates labels files along the bottom edge and ranks along the left edge like the raster boards
+func writeSVGCoordinates(buf *bytes.Buffer, inverted bool, color string) {
+	files, ranks := "abcdefgh", "87654321"
+	if inverted {
+		files, ranks = "hgfedcba", "12345678"
+	}
+	fmt.Fprintf(buf, `<g font-family="Arial, sans-serif" font-size="14" fill="%v">`, color)
+	for i := range files {
+		fmt.Fprintf(buf, `<text x="%v" y="%v" text-anchor="end">%c</text>`, svgSquareSize*(i+1)-3, svgSquareSize*8-3, files[i])
+		fmt.Fprintf(buf, `<text x="%v" y="%v">%c</text>`, 2, svgSquareSize*i+15, ranks[i])
+	}
+	fmt.Fprint(buf, `</g>`)
+}
@@ -0,0 +1,51 @@
+package rendering_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func TestBoardRendersSVG(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	for _, move := range []string{"e2e4", "f7f6", "d2d4", "g7g5", "d1h5"} {
+		if _, err := gm.Move(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	linkRenderer := rendering.NewRenderLink("", "secret")
+	link, _ := linkRenderer.CreateLink(gm)
+	rec := httptest.NewRecorder()
+	rendering.BoardRenderHandler{LinkRenderer: linkRenderer}.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.String()+"&format=svg", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected an SVG board, got status %v and %v", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	svg := rec.Body.String()
+	if count := strings.Count(svg, "<text x=") - 16; count != 32 {
+		t.Errorf("expected 32 pieces, got %v", count)
+	}
+	for _, color := range []string{"#cdd27a", "#aaa04b", "#e31e20"} {
+		if !strings.Contains(svg, color) {
+			t.Errorf("expected the last move and check to be highlighted with %v", color)
+		}
+	}
+}
+
+func TestSVGFlipsBoard(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	normal, flipped := &bytes.Buffer{}, &bytes.Buffer{}
+	rendering.RenderSVG(normal, gm.Board(), rendering.SVGOptions{})
+	rendering.RenderSVG(flipped, gm.Board(), rendering.SVGOptions{Inverted: true})
+	// The white king starts on e1 which is at the bottom unless the board is flipped
+	if !strings.Contains(normal.String(), `<text x="288" y="500" fill="#ffffff">♚`) {
+		t.Error("expected the white king at the bottom of the board")
+	}
+	if !strings.Contains(flipped.String(), `<text x="224" y="52" fill="#ffffff">♚`) {
+		t.Error("expected the white king at the top of the flipped board")
+	}
+}
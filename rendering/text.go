@@ -0,0 +1,63 @@
+package rendering
+
+import (
+	"strings"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// textPieceGlyphs are the Unicode chess symbols of each piece, outlined for white and filled for black
+var textPieceGlyphs = map[chess.Piece]string{
+	chess.WhiteKing:   "♔",
+	chess.WhiteQueen:  "♕",
+	chess.WhiteRook:   "♖",
+	chess.WhiteBishop: "♗",
+	chess.WhiteKnight: "♘",
+	chess.WhitePawn:   "♙",
+	chess.BlackKing:   "♚",
+	chess.BlackQueen:  "♛",
+	chess.BlackRook:   "♜",
+	chess.BlackBishop: "♝",
+	chess.BlackKnight: "♞",
+	chess.BlackPawn:   "♟",
+}
+
+// textEmptySquare is drawn for squares without a piece
+const textEmptySquare = "·"
+
+// RenderText draws the game after the given number of plies as a monospace Unicode board with coordinates,
+// for chat clients which can't load board images. The squares of the last move are bracketed and a checked
+// king is wrapped in parentheses.
+func RenderText(gm *game.Game, ply int, inverted bool) string {
+	board := newGameImage(gm, ply)
+	files, ranks := []string{"a", "b", "c", "d", "e", "f", "g", "h"}, []int{7, 6, 5, 4, 3, 2, 1, 0}
+	if inverted {
+		files, ranks = []string{"h", "g", "f", "e", "d", "c", "b", "a"}, []int{0, 1, 2, 3, 4, 5, 6, 7}
+	}
+	coordinates := "   " + strings.Join(files, "  ") + "\n"
+	out := &strings.Builder{}
+	out.WriteString(coordinates)
+	for _, rank := range ranks {
+		label := string(rune('1' + rank))
+		out.WriteString(label + " ")
+		for _, file := range files {
+			sq := chess.Square(rank*8 + int(file[0]-'a'))
+			glyph := textEmptySquare
+			if piece := board.board.Piece(sq); piece != chess.NoPiece {
+				glyph = textPieceGlyphs[piece]
+			}
+			switch sq {
+			case board.check:
+				out.WriteString("(" + glyph + ")")
+			case board.from, board.to:
+				out.WriteString("[" + glyph + "]")
+			default:
+				out.WriteString(" " + glyph + " ")
+			}
+		}
+		out.WriteString(" " + label + "\n")
+	}
+	out.WriteString(coordinates)
+	return out.String()
+}
@@ -0,0 +1,35 @@
+package rendering_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+)
+
+func TestRenderTextMarksLastMoveAndCheck(t *testing.T) {
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	for _, move := range []string{"e2e4", "f7f6", "d2d4", "g7g5", "d1h5"} {
+		gm.Move(move)
+	}
+	lines := strings.Split(strings.TrimRight(rendering.RenderText(gm, 5, false), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("expected 8 ranks between coordinates, got %v lines", len(lines))
+	}
+	expected := map[int]string{
+		0: "   a  b  c  d  e  f  g  h",
+		1: "8  ♜  ♞  ♝  ♛ (♚) ♝  ♞  ♜  8",
+		4: "5  ·  ·  ·  ·  ·  ·  ♟ [♕] 5",
+		8: "1  ♖  ♘  ♗ [·] ♔  ♗  ♘  ♖  1",
+	}
+	for line, text := range expected {
+		if lines[line] != text {
+			t.Errorf("expected line %v to be %q, got %q", line, text, lines[line])
+		}
+	}
+	inverted := strings.Split(rendering.RenderText(gm, 0, true), "\n")
+	if inverted[0] != "   h  g  f  e  d  c  b  a" || !strings.HasPrefix(inverted[1], "1  ♖  ♘  ♗  ♔  ♕") {
+		t.Errorf("expected the starting position from black's side, got %q", inverted[:2])
+	}
+}
@@ -0,0 +1,116 @@
+package rendering
+
+// Theme is a board color palette. Colors are hex strings as used in SVG and by gg.
+type Theme struct {
+	Name         string
+	Description  string
+	Light        string
+	Dark         string
+	Highlight    string
+	HighlightDim string
+	Check        string
+	Coordinates  string
+	// Pieces is the piece set used unless another one is selected
+	Pieces string
+}
+
+// DefaultTheme is used when no theme is selected or the selected one does not exist
+const DefaultTheme = "classic"
+
+// DefaultPieceSet is the piece set of the images directly in the asset directory
+const DefaultPieceSet = "standard"
+
+var themes = []Theme{
+	{
+		Name:         "classic",
+		Description:  "Brown wooden board",
+		Light:        "#efdab7",
+		Dark:         "#b48766",
+		Highlight:    "#cdd27a",
+		HighlightDim: "#aaa04b",
+		Check:        "#e31e20",
+		Coordinates:  "#000000",
+		Pieces:       "standard",
+	},
+	{
+		Name:         "dark",
+		Description:  "Dark mode board for dark chat themes",
+		Light:        "#5c5f66",
+		Dark:         "#33363b",
+		Highlight:    "#7d8a4e",
+		HighlightDim: "#5e6a35",
+		Check:        "#c0392b",
+		Coordinates:  "#d0d0d0",
+		Pieces:       "contrast",
+	},
+	{
+		Name:         "contrast",
+		Description:  "High contrast board with outlined black and white pieces",
+		Light:        "#ffffff",
+		Dark:         "#8a8a8a",
+		Highlight:    "#ffd500",
+		HighlightDim: "#e0a800",
+		Check:        "#ff0000",
+		Coordinates:  "#000000",
+		Pieces:       "contrast",
+	},
+	{
+		Name:         "colorblind",
+		Description:  "Blue board with highlights that do not rely on telling red from green",
+		Light:        "#dee3e6",
+		Dark:         "#8ca2ad",
+		Highlight:    "#56b4e9",
+		HighlightDim: "#0072b2",
+		Check:        "#e69f00",
+		Coordinates:  "#000000",
+		Pieces:       "standard",
+	},
+}
+
+// pieceSets maps a piece set to its directory within the asset path
+var pieceSets = map[string]string{
+	"standard": "",
+	"contrast": "contrast/",
+}
+
+// Themes returns every available theme
+func Themes() []Theme {
+	return append([]Theme{}, themes...)
+}
+
+// LookupTheme returns the theme of the given name, or the default theme if there is none
+func LookupTheme(name string) (Theme, bool) {
+	for _, theme := range themes {
+		if theme.Name == name {
+			return theme, true
+		}
+	}
+	return themes[0], false
+}
+
+// PieceSets returns the names of the available piece sets
+func PieceSets() []string {
+	return []string{"standard", "contrast"}
+}
+
+// ValidPieceSet determines if a piece set of the given name exists
+func ValidPieceSet(name string) bool {
+	_, ok := pieceSets[name]
+	return ok
+}
+
+// Style selects the theme and piece set of a board. Empty values fall back to the defaults.
+type Style struct {
+	Theme  string
+	Pieces string
+}
+
+// resolve returns the theme and asset directory of the pieces the style renders with
+func (s Style) resolve() (Theme, string) {
+	theme, _ := LookupTheme(s.Theme)
+	pieces, ok := pieceSets[s.Pieces]
+	if !ok {
+		pieces = pieceSets[theme.Pieces]
+	}
+	return theme, assetPath + pieces
+}
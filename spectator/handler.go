@@ -0,0 +1,219 @@
+// Package spectator serves live updating pages for following games without playing them
+package spectator
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/gorilla/websocket"
+	"github.com/notnil/chess"
+)
+
+// PingInterval is how often idle spectator connections are pinged to keep proxies from closing them
+const PingInterval = 30 * time.Second
+
+// Handler serves the spectator page under /watch/{game_id} and pushes every stored move over a WebSocket
+// It must be registered as an observer of the game storage to receive updates.
+type Handler struct {
+	gameStorage  game.GameStorage
+	watchLink    WatchLink
+	linkRenderer rendering.RenderLink
+	upgrader     websocket.Upgrader
+	mu           sync.Mutex
+	subscribers  map[string]map[chan *game.Game]bool
+}
+
+// NewHTTPHandler returns an instance of the spectator handler
+func NewHTTPHandler(store game.GameStorage, watchLink WatchLink, linkRenderer rendering.RenderLink) *Handler {
+	return &Handler{
+		gameStorage:  store,
+		watchLink:    watchLink,
+		linkRenderer: linkRenderer,
+		subscribers:  make(map[string]map[chan *game.Game]bool),
+	}
+}
+
+type gameState struct {
+	ID     string   `json:"id"`
+	White  []string `json:"white"`
+	Black  []string `json:"black"`
+	Turn   string   `json:"turn"`
+	Result string   `json:"result"`
+	Moves  []string `json:"moves"`
+	Board  string   `json:"board"`
+}
+
+func (h *Handler) newGameState(gm *game.Game) gameState {
+	state := gameState{
+		ID:    gm.ID,
+		White: gm.Players[game.White].Members(),
+		Black: gm.Players[game.Black].Members(),
+		Turn:  string(gm.Turn()),
+		Moves: []string{},
+	}
+	if gm.Outcome() != chess.NoOutcome {
+		state.Result = gm.ResultText()
+	}
+	for _, move := range gm.Moves() {
+		state.Moves = append(state.Moves, move.String())
+	}
+	// Wall displays keep white at the bottom instead of flipping every move
+	if link, err := h.linkRenderer.CreateOrientedLink(gm, false); err == nil {
+		state.Board = link.String()
+	}
+	return state
+}
+
+// GameStored forwards the stored game to every spectator of it
+func (h *Handler) GameStored(gm *game.Game) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subscriber := range h.subscribers[gm.ID] {
+		select {
+		case subscriber <- gm:
+		default:
+			log.Printf("Dropped update of game %v for a slow spectator", gm.ID)
+		}
+	}
+}
+
+func (h *Handler) subscribe(gameID string) chan *game.Game {
+	subscriber := make(chan *game.Game, 16)
+	h.mu.Lock()
+	if h.subscribers[gameID] == nil {
+		h.subscribers[gameID] = make(map[chan *game.Game]bool)
+	}
+	h.subscribers[gameID][subscriber] = true
+	h.mu.Unlock()
+	return subscriber
+}
+
+func (h *Handler) unsubscribe(gameID string, subscriber chan *game.Game) {
+	h.mu.Lock()
+	delete(h.subscribers[gameID], subscriber)
+	if len(h.subscribers[gameID]) == 0 {
+		delete(h.subscribers, gameID)
+	}
+	h.mu.Unlock()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	gameID, err := url.PathUnescape(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/watch"), "/"))
+	if err != nil || gameID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !h.watchLink.ValidateLink(gameID, r.URL.Query().Get("signature")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	gm, err := h.gameStorage.RetrieveGame(gameID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		h.stream(w, r, gm)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := watchTemplate.Execute(w, h.newGameState(gm)); err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, gm *game.Game) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+	subscriber := h.subscribe(gm.ID)
+	defer h.unsubscribe(gm.ID, subscriber)
+
+	// Spectators never send anything, reading only detects when they leave
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	if err := conn.WriteJSON(h.newGameState(gm)); err != nil {
+		return
+	}
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case gm := <-subscriber:
+			if err := conn.WriteJSON(h.newGameState(gm)); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(PingInterval)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+var watchTemplate = template.Must(template.New("watch").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ChessBot - {{ range .White }}{{ . }} {{ end }}vs {{ range .Black }}{{ . }} {{ end }}</title>
+<style>
+body { background: #222; color: #eee; font-family: sans-serif; display: flex; justify-content: center; align-items: flex-start; gap: 2em; margin: 2em; }
+#board { height: 90vh; max-width: 60vw; object-fit: contain; }
+#moves { columns: 2; font-family: monospace; font-size: 1.5em; }
+#status { font-size: 1.5em; }
+</style>
+</head>
+<body>
+<img id="board" src="{{ .Board }}" alt="Current board">
+<div>
+<h1>{{ range .White }}{{ . }} {{ end }}vs {{ range .Black }}{{ . }} {{ end }}</h1>
+<p id="status">{{ if .Result }}{{ .Result }}{{ else }}{{ .Turn }} to move{{ end }}</p>
+<ol id="moves">{{ range .Moves }}<li>{{ . }}</li>{{ end }}</ol>
+</div>
+<script>
+function connect() {
+	var socket = new WebSocket(location.href.replace(/^http/, "ws"));
+	socket.onmessage = function(event) {
+		var state = JSON.parse(event.data);
+		document.getElementById("board").src = state.board;
+		document.getElementById("status").textContent = state.result || state.turn + " to move";
+		var moves = document.getElementById("moves");
+		moves.innerHTML = "";
+		state.moves.forEach(function(move) {
+			var item = document.createElement("li");
+			item.textContent = move;
+			moves.appendChild(item);
+		});
+	};
+	socket.onclose = function() {
+		setTimeout(connect, 5000);
+	};
+}
+connect();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,72 @@
+package spectator_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/rendering"
+	"github.com/cjsaylor/chessbot/spectator"
+	"github.com/gorilla/websocket"
+)
+
+type state struct {
+	Moves []string `json:"moves"`
+	Board string   `json:"board"`
+}
+
+func TestWatchStreamsStoredMoves(t *testing.T) {
+	store := game.NewObservedStore(game.NewMemoryStore())
+	gm := game.NewGame("1234.5678", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	store.StoreGame(gm.ID, gm)
+	watchLink := spectator.NewWatchLink("", "secret")
+	handler := spectator.NewHTTPHandler(store, watchLink, rendering.NewRenderLink("http://localhost", "secret"))
+	store.Observe(handler)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	link, _ := watchLink.CreateLink(gm.ID)
+	resp, err := http.Get(server.URL + link.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the spectator page to be served, got status %v", resp.StatusCode)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+link.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var initial state
+	if err := conn.ReadJSON(&initial); err != nil {
+		t.Fatal(err)
+	}
+	if len(initial.Moves) != 0 || !strings.Contains(initial.Board, "/board?") {
+		t.Errorf("expected the opening position, got %v", initial)
+	}
+	gm.Move("e2e4")
+	store.StoreGame(gm.ID, gm)
+	var update state
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Moves) != 1 || update.Moves[0] != "e2e4" {
+		t.Errorf("expected the stored move to be pushed, got %v", update)
+	}
+}
+
+func TestWatchRequiresSignature(t *testing.T) {
+	store := game.NewMemoryStore()
+	store.StoreGame("1234", game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "}))
+	handler := spectator.NewHTTPHandler(store, spectator.NewWatchLink("", "secret"), rendering.NewRenderLink("", "secret"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/watch/1234?signature=invalid", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %v", rec.Code)
+	}
+}
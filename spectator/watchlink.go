@@ -0,0 +1,47 @@
+package spectator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+)
+
+// WatchLink creates and validates signed spectator page URLs
+type WatchLink struct {
+	hostName   string
+	signingKey string
+}
+
+// NewWatchLink creates a new WatchLink struct instance
+func NewWatchLink(hostname string, signingKey string) WatchLink {
+	return WatchLink{
+		hostName:   hostname,
+		signingKey: signingKey,
+	}
+}
+
+// sign returns the HMAC of the watched game, prefixed so it cannot be used as the signature of another kind of link
+func (w WatchLink) sign(gameID string) string {
+	mac := hmac.New(sha256.New, []byte(w.signingKey))
+	mac.Write([]byte("watch:" + gameID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateLink returns an externally accessible spectator URL for the game
+func (w WatchLink) CreateLink(gameID string) (*url.URL, error) {
+	u, err := url.Parse(fmt.Sprintf("%v/watch/%v", w.hostName, url.PathEscape(gameID)))
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Add("signature", w.sign(gameID))
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// ValidateLink ensures that the signature of the link matches the watched game
+func (w WatchLink) ValidateLink(gameID string, signature string) bool {
+	return hmac.Equal([]byte(w.sign(gameID)), []byte(signature))
+}
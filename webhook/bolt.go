@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	webhooksBucket   = []byte("webhooks")
+	deliveriesBucket = []byte("webhook_deliveries")
+)
+
+// BoltStore implements the WebhookStorage interface and persists in an embedded bolt database.
+// Like the memory store, it keeps the latest maxDeliveries of each workspace.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore creates (if not exists) the buckets of webhooks and their delivery log in the database.
+// The database can be shared with the bolt stores of other packages.
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{webhooksBucket, deliveriesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// webhookPrefix is the start of the keys of every webhook of the workspace, which are followed by their URL
+func webhookPrefix(workspaceID string) []byte {
+	return []byte(workspaceID + "\x00")
+}
+
+// StoreWebhook registers a webhook, replacing the secret of an already registered URL
+func (b *BoltStore) StoreWebhook(hook Webhook) error {
+	value, err := json.Marshal(hook)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).Put(append(webhookPrefix(hook.WorkspaceID), hook.URL...), value)
+	})
+}
+
+// RemoveWebhook unregisters the webhook of the workspace with the given URL
+func (b *BoltStore) RemoveWebhook(workspaceID string, url string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).Delete(append(webhookPrefix(workspaceID), url...))
+	})
+}
+
+// WebhooksByWorkspace returns every webhook registered by the workspace
+func (b *BoltStore) WebhooksByWorkspace(workspaceID string) ([]Webhook, error) {
+	hooks := []Webhook{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := webhookPrefix(workspaceID)
+		cursor := tx.Bucket(webhooksBucket).Cursor()
+		for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			hook := Webhook{}
+			if err := json.Unmarshal(value, &hook); err != nil {
+				return err
+			}
+			hooks = append(hooks, hook)
+		}
+		return nil
+	})
+	return hooks, err
+}
+
+// LogDelivery records a delivery attempt, keeping the latest maxDeliveries of the workspace
+func (b *BoltStore) LogDelivery(delivery Delivery) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(deliveriesBucket).CreateBucketIfNotExists([]byte(delivery.WorkspaceID))
+		if err != nil {
+			return err
+		}
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, sequence)
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+		// the keys are ordered by sequence, the oldest deliveries come first
+		cursor := bucket.Cursor()
+		logged := 0
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			logged++
+		}
+		for ; logged > maxDeliveries; logged-- {
+			cursor.First()
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deliveries returns the most recent deliveries of the workspace first
+func (b *BoltStore) Deliveries(workspaceID string, limit int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deliveriesBucket).Bucket([]byte(workspaceID))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil && len(deliveries) < limit; key, value = cursor.Prev() {
+			delivery := Delivery{}
+			if err := json.Unmarshal(value, &delivery); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// dump returns every webhook and delivery of the store, the deliveries of each workspace oldest first
+func (b *BoltStore) dump() (storedWebhooks, error) {
+	stored := storedWebhooks{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(webhooksBucket).ForEach(func(key []byte, value []byte) error {
+			hook := Webhook{}
+			if err := json.Unmarshal(value, &hook); err != nil {
+				return err
+			}
+			stored.webhooks = append(stored.webhooks, hook)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		workspaces := tx.Bucket(deliveriesBucket)
+		return workspaces.ForEach(func(workspaceID []byte, _ []byte) error {
+			return workspaces.Bucket(workspaceID).ForEach(func(key []byte, value []byte) error {
+				delivery := Delivery{}
+				if err := json.Unmarshal(value, &delivery); err != nil {
+					return err
+				}
+				stored.deliveries = append(stored.deliveries, delivery)
+				return nil
+			})
+		})
+	})
+	return stored, err
+}
+
+// ImportSqlite copies every webhook and delivery of the sqlite store into the bolt store
+func (b *BoltStore) ImportSqlite(store *SqliteStore) error {
+	stored, err := store.dump()
+	if err != nil {
+		return err
+	}
+	return stored.copyTo(b)
+}
+
+// ExportSqlite copies every webhook and delivery of the bolt store into the sqlite store
+func (b *BoltStore) ExportSqlite(store *SqliteStore) error {
+	stored, err := b.dump()
+	if err != nil {
+		return err
+	}
+	return stored.copyTo(store)
+}
+
+// storedWebhooks is everything kept by a webhook store, as copied between the bolt and sqlite stores
+type storedWebhooks struct {
+	webhooks   []Webhook
+	deliveries []Delivery
+}
+
+func (s storedWebhooks) copyTo(store WebhookStorage) error {
+	for _, hook := range s.webhooks {
+		if err := store.StoreWebhook(hook); err != nil {
+			return err
+		}
+	}
+	for _, delivery := range s.deliveries {
+		if err := store.LogDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
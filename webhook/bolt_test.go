@@ -0,0 +1,75 @@
+package webhook_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/webhook"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStoreImportsAndExportsSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chessbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := webhook.NewSqliteStore(filepath.Join(dir, "chessbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := webhook.Webhook{WorkspaceID: "T1", URL: "https://example.com/hook", Secret: "secret"}
+	sqlite.StoreWebhook(hook)
+	sqlite.LogDelivery(webhook.Delivery{EventID: "E1", WorkspaceID: "T1", URL: hook.URL, Event: "move.played", GameID: "1234", Attempt: 1, StatusCode: 200, CreatedAt: time.Now().UTC()})
+
+	db, err := bolt.Open(filepath.Join(dir, "chessbot.bolt"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, err := webhook.NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ImportSqlite(sqlite); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, err := store.WebhooksByWorkspace("T1"); err != nil || len(hooks) != 1 || hooks[0] != hook {
+		t.Errorf("expected the webhook to be imported, got %v (%v)", hooks, err)
+	}
+	if deliveries, err := store.Deliveries("T1", 10); err != nil || len(deliveries) != 1 || deliveries[0].EventID != "E1" {
+		t.Errorf("expected the delivery to be imported, got %v (%v)", deliveries, err)
+	}
+
+	store.StoreWebhook(webhook.Webhook{WorkspaceID: "T2", URL: "https://example.com/other", Secret: "other"})
+	store.RemoveWebhook("T1", hook.URL)
+	for i := 0; i < 150; i++ {
+		store.LogDelivery(webhook.Delivery{EventID: fmt.Sprint(i), WorkspaceID: "T2", CreatedAt: time.Now().UTC()})
+	}
+	deliveries, err := store.Deliveries("T2", 200)
+	if err != nil || len(deliveries) != 100 {
+		t.Fatalf("expected the latest 100 deliveries to be kept, got %v (%v)", len(deliveries), err)
+	}
+	if deliveries[0].EventID != "149" || deliveries[99].EventID != "50" {
+		t.Errorf("expected the most recent deliveries first, got %v to %v", deliveries[0].EventID, deliveries[99].EventID)
+	}
+
+	exported, err := webhook.NewSqliteStore(filepath.Join(dir, "exported.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ExportSqlite(exported); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, _ := exported.WebhooksByWorkspace("T1"); len(hooks) != 0 {
+		t.Errorf("expected the removed webhook not to be exported, got %v", hooks)
+	}
+	if hooks, _ := exported.WebhooksByWorkspace("T2"); len(hooks) != 1 || hooks[0].Secret != "other" {
+		t.Errorf("expected the webhook to be exported, got %v", hooks)
+	}
+	if deliveries, _ := exported.Deliveries("T2", 1); len(deliveries) != 1 || deliveries[0].EventID != "149" {
+		t.Errorf("expected the deliveries to be exported in order, got %v", deliveries)
+	}
+}
@@ -0,0 +1,264 @@
+// Package webhook delivers signed game lifecycle events to HTTP callbacks registered by a workspace
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/notnil/chess"
+)
+
+// Event types delivered to webhooks
+const (
+	EventChallengeCreated = "challenge.created"
+	EventGameStarted      = "game.started"
+	EventMovePlayed       = "move.played"
+	EventTakeback         = "takeback"
+	EventGameFinished     = "game.finished"
+)
+
+// SignatureHeader carries "sha256=" followed by the hex encoded HMAC-SHA256 of the request body keyed with the webhook secret
+const SignatureHeader = "X-Chessbot-Signature"
+
+// Event is the JSON body posted to webhooks
+type Event struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	WorkspaceID string    `json:"workspace_id"`
+	GameID      string    `json:"game_id"`
+	White       []string  `json:"white"`
+	Black       []string  `json:"black"`
+	Move        string    `json:"move,omitempty"`
+	FEN         string    `json:"fen"`
+	Outcome     string    `json:"outcome,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	PGN         string    `json:"pgn,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Dispatcher turns stored games into webhook deliveries
+// It must be registered as an observer of the game storage.
+type Dispatcher struct {
+	// HTTPClient refuses to connect to private addresses by default
+	HTTPClient  *http.Client
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled on every following one
+	Backoff time.Duration
+	storage WebhookStorage
+	pending sync.WaitGroup
+	mu      sync.Mutex
+	// queues hold the events waiting for each webhook, keyed by workspace and URL
+	queues map[string]*hookQueue
+}
+
+// hookQueue holds the events waiting to be delivered to one webhook, in the order their games were stored
+type hookQueue struct {
+	hook   Webhook
+	events []Event
+}
+
+// NewDispatcher returns a dispatcher delivering to the webhooks of the storage
+func NewDispatcher(store WebhookStorage) *Dispatcher {
+	return &Dispatcher{
+		HTTPClient:  newHTTPClient(),
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		storage:     store,
+		queues:      make(map[string]*hookQueue),
+	}
+}
+
+// NewSecret returns a random secret for signing the events of a new webhook
+func NewSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature of a body as sent in the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GameStored queues an event for every lifecycle change stored along with the game.
+// A game is started by its first move.
+func (d *Dispatcher) GameStored(gm *game.Game) {
+	if gm.WorkspaceID == "" {
+		return
+	}
+	stored := gm.StoredEvents()
+	// count back from the current position to the one the stored events were played from
+	ply := len(gm.Moves())
+	for _, event := range stored {
+		if event.Type == game.EventMove {
+			ply--
+		} else if event.Type == game.EventTakeback {
+			ply++
+		}
+	}
+	events := []Event{}
+	played := false
+	for _, change := range stored {
+		switch change.Type {
+		case game.EventChallenge:
+			events = append(events, newEvent(EventChallengeCreated, gm))
+		case game.EventMove:
+			if ply++; ply == 1 {
+				events = append(events, newEvent(EventGameStarted, gm))
+			}
+			event := newEvent(EventMovePlayed, gm)
+			event.Move = change.Move
+			events = append(events, event)
+			played = true
+		case game.EventTakeback:
+			ply--
+			events = append(events, newEvent(EventTakeback, gm))
+		case game.EventResign:
+			played = true
+		}
+	}
+	if played && gm.Outcome() != chess.NoOutcome {
+		event := newEvent(EventGameFinished, gm)
+		event.Outcome = gm.Outcome().String()
+		event.Method = gm.Method().String()
+		event.PGN = gm.PGN()
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	hooks, err := d.storage.WebhooksByWorkspace(gm.WorkspaceID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, hook := range hooks {
+		d.enqueue(hook, events)
+	}
+}
+
+// enqueue appends events to the queue of a webhook, starting a worker delivering them unless one is running
+func (d *Dispatcher) enqueue(hook Webhook, events []Event) {
+	key := hook.WorkspaceID + " " + hook.URL
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if queue, ok := d.queues[key]; ok {
+		queue.hook = hook
+		queue.events = append(queue.events, events...)
+		return
+	}
+	queue := &hookQueue{hook: hook, events: append([]Event{}, events...)}
+	d.queues[key] = queue
+	d.pending.Add(1)
+	go d.drain(key, queue)
+}
+
+// drain delivers the events of a queue one after another, so a retried delivery holds back the following ones
+func (d *Dispatcher) drain(key string, queue *hookQueue) {
+	defer d.pending.Done()
+	for {
+		d.mu.Lock()
+		if len(queue.events) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		hook, event := queue.hook, queue.events[0]
+		queue.events = queue.events[1:]
+		d.mu.Unlock()
+		d.deliver(hook, event)
+	}
+}
+
+// Wait blocks until every queued delivery succeeded or ran out of attempts
+func (d *Dispatcher) Wait() {
+	d.pending.Wait()
+}
+
+func newEventID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func newEvent(eventType string, gm *game.Game) Event {
+	return Event{
+		ID:          newEventID(),
+		Type:        eventType,
+		WorkspaceID: gm.WorkspaceID,
+		GameID:      gm.ID,
+		White:       gm.Players[game.White].Members(),
+		Black:       gm.Players[game.Black].Members(),
+		FEN:         gm.FEN(),
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (d *Dispatcher) deliver(hook Webhook, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	backoff := d.Backoff
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		delivery := Delivery{
+			EventID:     event.ID,
+			WorkspaceID: hook.WorkspaceID,
+			URL:         hook.URL,
+			Event:       event.Type,
+			GameID:      event.GameID,
+			Attempt:     attempt,
+			CreatedAt:   time.Now(),
+		}
+		delivery.StatusCode, err = d.post(hook, event, body)
+		if err != nil {
+			delivery.Error = err.Error()
+		} else if !delivery.Succeeded() {
+			delivery.Error = fmt.Sprintf("unexpected status %v", delivery.StatusCode)
+		}
+		if err := d.storage.LogDelivery(delivery); err != nil {
+			log.Println(err)
+		}
+		if delivery.Error == "" {
+			return
+		}
+		if attempt < d.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	log.Printf("Giving up delivering %v of game %v to %v", event.Type, event.GameID, hook.URL)
+}
+
+func (d *Dispatcher) post(hook Webhook, event Event, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chessbot-Event", event.Type)
+	req.Header.Set("X-Chessbot-Delivery", event.ID)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
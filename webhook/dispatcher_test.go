@@ -0,0 +1,209 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cjsaylor/chessbot/game"
+	"github.com/cjsaylor/chessbot/webhook"
+)
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	var mu sync.Mutex
+	received := []string{}
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(webhook.SignatureHeader) != webhook.Sign("secret", body) {
+			t.Errorf("unexpected signature %v", r.Header.Get(webhook.SignatureHeader))
+		}
+		var event webhook.Event
+		json.Unmarshal(body, &event)
+		mu.Lock()
+		defer mu.Unlock()
+		// Fail the first move once to exercise the retry
+		if event.Type == webhook.EventMovePlayed && !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received = append(received, event.Type)
+	}))
+	defer server.Close()
+
+	hooks := webhook.NewMemoryStore()
+	hooks.StoreWebhook(webhook.Webhook{WorkspaceID: "T1", URL: server.URL, Secret: "secret"})
+	dispatcher := webhook.NewDispatcher(hooks)
+	dispatcher.HTTPClient = server.Client()
+	dispatcher.Backoff = time.Millisecond
+	store := game.NewObservedStore(game.NewMemoryStore(), dispatcher)
+
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.WorkspaceID = "T1"
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+	gm.Start()
+	gm.Move("e2e4")
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+	gm.Resign(gm.TurnPlayer())
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+
+	expected := []string{webhook.EventChallengeCreated, webhook.EventGameStarted, webhook.EventMovePlayed, webhook.EventGameFinished}
+	if len(received) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("expected events %v, got %v", expected, received)
+		}
+	}
+	deliveries, _ := hooks.Deliveries("T1", 10)
+	if len(deliveries) != 5 || deliveries[1].Attempt != 2 || deliveries[2].Succeeded() {
+		t.Errorf("expected the failed attempt and its retry in the delivery log, got %v", deliveries)
+	}
+}
+
+func TestDispatcherOnlyDeliversStoredChanges(t *testing.T) {
+	var mu sync.Mutex
+	received := []webhook.Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	memory := game.NewMemoryStore()
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.WorkspaceID = "T1"
+	gm.Move("e2e4")
+	memory.StoreGame(gm.ID, gm)
+
+	// a dispatcher started after the game was created, e.g. after a restart
+	hooks := webhook.NewMemoryStore()
+	hooks.StoreWebhook(webhook.Webhook{WorkspaceID: "T1", URL: server.URL, Secret: "secret"})
+	dispatcher := webhook.NewDispatcher(hooks)
+	dispatcher.HTTPClient = server.Client()
+	store := game.NewObservedStore(memory, dispatcher)
+	mover := gm.TurnPlayer()
+	gm.Move("e7e5")
+	if _, err := gm.Takeback(&mover); err != nil {
+		t.Fatal(err)
+	}
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+
+	if len(received) != 2 || received[0].Type != webhook.EventMovePlayed || received[0].Move != "e7e5" || received[1].Type != webhook.EventTakeback {
+		t.Errorf("expected the move and take back of the last store only, got %+v", received)
+	}
+}
+
+func TestDispatcherDeliversInStoreOrder(t *testing.T) {
+	var mu sync.Mutex
+	received := []string{}
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		defer mu.Unlock()
+		// the move is retried while the resignation is already stored
+		if event.Type == webhook.EventMovePlayed && !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received = append(received, event.Type)
+	}))
+	defer server.Close()
+
+	hooks := webhook.NewMemoryStore()
+	hooks.StoreWebhook(webhook.Webhook{WorkspaceID: "T1", URL: server.URL, Secret: "secret"})
+	dispatcher := webhook.NewDispatcher(hooks)
+	dispatcher.HTTPClient = server.Client()
+	dispatcher.Backoff = 50 * time.Millisecond
+	store := game.NewObservedStore(game.NewMemoryStore(), dispatcher)
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.WorkspaceID = "T1"
+	gm.Move("e2e4")
+	store.StoreGame(gm.ID, gm)
+	gm.Resign(gm.TurnPlayer())
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+
+	expected := []string{webhook.EventChallengeCreated, webhook.EventGameStarted, webhook.EventMovePlayed, webhook.EventGameFinished}
+	if len(received) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Fatalf("expected events %v, got %v", expected, received)
+		}
+	}
+}
+
+func TestWebhooksCannotReachPrivateAddresses(t *testing.T) {
+	for _, hookURL := range []string{"http://127.0.0.1:8080/", "http://10.1.2.3/", "http://169.254.169.254/latest/meta-data", "http://[::1]/", "http://[fe80::1]/", "http://192.168.1.1/"} {
+		if err := webhook.ValidateURL(hookURL); err != webhook.ErrPrivateAddress {
+			t.Errorf("expected %v to be rejected, got %v", hookURL, err)
+		}
+	}
+	if err := webhook.ValidateURL("ftp://example.org/"); err != webhook.ErrInvalidURL {
+		t.Errorf("expected non http URLs to be rejected, got %v", err)
+	}
+	if err := webhook.ValidateURL("https://93.184.216.34/hook"); err != nil {
+		t.Errorf("expected public addresses to be accepted, got %v", err)
+	}
+
+	delivered := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered = true
+	}))
+	defer server.Close()
+	hooks := webhook.NewMemoryStore()
+	hooks.StoreWebhook(webhook.Webhook{WorkspaceID: "T1", URL: server.URL, Secret: "secret"})
+	dispatcher := webhook.NewDispatcher(hooks)
+	dispatcher.MaxAttempts = 1
+	store := game.NewObservedStore(game.NewMemoryStore(), dispatcher)
+	gm := game.NewGame("1234", game.Player{ID: " U1 "}, game.Player{ID: " U2 "})
+	gm.WorkspaceID = "T1"
+	store.StoreGame(gm.ID, gm)
+	dispatcher.Wait()
+	if delivered {
+		t.Error("expected the delivery to a loopback address to be refused")
+	}
+	if host, _, _ := net.SplitHostPort(server.Listener.Addr().String()); host != "127.0.0.1" {
+		t.Skipf("test server listens on %v", host)
+	}
+	deliveries, _ := hooks.Deliveries("T1", 10)
+	if len(deliveries) != 1 || deliveries[0].Succeeded() {
+		t.Errorf("expected the refused delivery in the log, got %+v", deliveries)
+	}
+}
+
+func TestMemoryStoreKeepsLatestDeliveries(t *testing.T) {
+	hooks := webhook.NewMemoryStore()
+	hooks.LogDelivery(webhook.Delivery{WorkspaceID: "T2", EventID: "other"})
+	for i := 0; i < 150; i++ {
+		hooks.LogDelivery(webhook.Delivery{WorkspaceID: "T1", Attempt: i})
+	}
+	deliveries, _ := hooks.Deliveries("T1", 1000)
+	if len(deliveries) != 100 || deliveries[0].Attempt != 149 || deliveries[99].Attempt != 50 {
+		t.Errorf("expected the latest 100 deliveries, got %v from %v", len(deliveries), deliveries[0].Attempt)
+	}
+	if deliveries, _ := hooks.Deliveries("T2", 10); len(deliveries) != 1 {
+		t.Errorf("expected the deliveries of other workspaces to be kept, got %v", deliveries)
+	}
+}
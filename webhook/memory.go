@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"sync"
+)
+
+// MemoryStore implements the WebhookStorage interface and holds all state in memory
+// Once the MemoryStore instance is released, all data in that storage is lost
+type MemoryStore struct {
+	mu         sync.Mutex
+	webhooks   map[string][]Webhook
+	deliveries map[string][]Delivery
+}
+
+// maxDeliveries is the number of delivery attempts kept per workspace, older ones are dropped
+const maxDeliveries = 100
+
+// NewMemoryStore returns a MemoryStore pointer
+func NewMemoryStore() *MemoryStore {
+	store := MemoryStore{
+		webhooks:   make(map[string][]Webhook, 10),
+		deliveries: make(map[string][]Delivery, 10),
+	}
+	return &store
+}
+
+// StoreWebhook registers a webhook, replacing the secret of an already registered URL
+func (m *MemoryStore) StoreWebhook(hook Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.webhooks[hook.WorkspaceID] {
+		if existing.URL == hook.URL {
+			m.webhooks[hook.WorkspaceID][i] = hook
+			return nil
+		}
+	}
+	m.webhooks[hook.WorkspaceID] = append(m.webhooks[hook.WorkspaceID], hook)
+	return nil
+}
+
+// RemoveWebhook unregisters the webhook of the workspace with the given URL
+func (m *MemoryStore) RemoveWebhook(workspaceID string, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hooks := []Webhook{}
+	for _, hook := range m.webhooks[workspaceID] {
+		if hook.URL != url {
+			hooks = append(hooks, hook)
+		}
+	}
+	m.webhooks[workspaceID] = hooks
+	return nil
+}
+
+// WebhooksByWorkspace returns every webhook registered by the workspace
+func (m *MemoryStore) WebhooksByWorkspace(workspaceID string) ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Webhook{}, m.webhooks[workspaceID]...), nil
+}
+
+// LogDelivery records a delivery attempt, keeping the latest maxDeliveries of the workspace
+func (m *MemoryStore) LogDelivery(delivery Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deliveries := append(m.deliveries[delivery.WorkspaceID], delivery)
+	if len(deliveries) > maxDeliveries {
+		deliveries = append([]Delivery{}, deliveries[len(deliveries)-maxDeliveries:]...)
+	}
+	m.deliveries[delivery.WorkspaceID] = deliveries
+	return nil
+}
+
+// Deliveries returns the most recent deliveries of the workspace first
+func (m *MemoryStore) Deliveries(workspaceID string, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deliveries := []Delivery{}
+	logged := m.deliveries[workspaceID]
+	for i := len(logged) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, logged[i])
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"database/sql"
+
+	"github.com/cjsaylor/chessbot/migration"
+
+	// import postgres package for use with the sql interface
+	_ "github.com/lib/pq"
+)
+
+// PostgresMigrations creates and evolves the webhook tables on Postgres
+var PostgresMigrations = migration.Set{
+	Component: "webhook",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create webhooks and their delivery log",
+			Up: `
+				CREATE TABLE webhooks (
+					workspace_id text NOT NULL,
+					url text NOT NULL,
+					secret text NOT NULL,
+					PRIMARY KEY (workspace_id, url)
+				);
+				CREATE TABLE webhook_deliveries (
+					id bigserial PRIMARY KEY,
+					event_id text NOT NULL,
+					workspace_id text NOT NULL,
+					url text NOT NULL,
+					event text NOT NULL,
+					game_id text NOT NULL,
+					attempt integer NOT NULL,
+					status_code integer NOT NULL,
+					error text NOT NULL,
+					created_at timestamptz NOT NULL
+				);
+				CREATE INDEX webhook_deliveries_workspace_id ON webhook_deliveries (workspace_id, id);
+			`,
+		},
+	},
+}
+
+// PostgresStore is an implementation of the WebhookStorage interface that persists using Postgres
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore migrates the webhook tables of the database to the latest version
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	if err := migration.Apply(db, migration.Postgres, PostgresMigrations); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// StoreWebhook registers a webhook, replacing the secret of an already registered URL
+func (s *PostgresStore) StoreWebhook(hook Webhook) error {
+	_, err := s.db.Exec(`
+		insert into webhooks (workspace_id, url, secret) values ($1, $2, $3)
+		on conflict(workspace_id, url) do update set secret = $3
+	`, hook.WorkspaceID, hook.URL, hook.Secret)
+	return err
+}
+
+// RemoveWebhook unregisters the webhook of the workspace with the given URL
+func (s *PostgresStore) RemoveWebhook(workspaceID string, url string) error {
+	_, err := s.db.Exec("delete from webhooks where workspace_id = $1 and url = $2", workspaceID, url)
+	return err
+}
+
+// WebhooksByWorkspace returns every webhook registered by the workspace
+func (s *PostgresStore) WebhooksByWorkspace(workspaceID string) ([]Webhook, error) {
+	rows, err := s.db.Query("select url, secret from webhooks where workspace_id = $1 order by url", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []Webhook{}
+	for rows.Next() {
+		hook := Webhook{WorkspaceID: workspaceID}
+		if err := rows.Scan(&hook.URL, &hook.Secret); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// LogDelivery records a delivery attempt
+func (s *PostgresStore) LogDelivery(delivery Delivery) error {
+	_, err := s.db.Exec(`
+		insert into webhook_deliveries (event_id, workspace_id, url, event, game_id, attempt, status_code, error, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`,
+		delivery.EventID,
+		delivery.WorkspaceID,
+		delivery.URL,
+		delivery.Event,
+		delivery.GameID,
+		delivery.Attempt,
+		delivery.StatusCode,
+		delivery.Error,
+		delivery.CreatedAt)
+	return err
+}
+
+// Deliveries returns the most recent deliveries of the workspace first
+func (s *PostgresStore) Deliveries(workspaceID string, limit int) ([]Delivery, error) {
+	rows, err := s.db.Query(`
+		select event_id, url, event, game_id, attempt, status_code, error, created_at
+		from webhook_deliveries where workspace_id = $1 order by id desc limit $2
+	`, workspaceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery := Delivery{WorkspaceID: workspaceID}
+		if err := rows.Scan(
+			&delivery.EventID,
+			&delivery.URL,
+			&delivery.Event,
+			&delivery.GameID,
+			&delivery.Attempt,
+			&delivery.StatusCode,
+			&delivery.Error,
+			&delivery.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
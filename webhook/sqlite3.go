@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"database/sql"
+
+	"github.com/cjsaylor/chessbot/migration"
+
+	// import sqlite package for use with the sql interface
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SqliteMigrations creates and evolves the webhook tables on sqlite.
+// The first migrations tolerate databases created before the schema was versioned.
+var SqliteMigrations = migration.Set{
+	Component: "webhook",
+	Migrations: []migration.Migration{
+		{
+			Version:     1,
+			Description: "create webhooks and their delivery log",
+			Up: `
+				CREATE TABLE IF NOT EXISTS webhooks (
+					workspace_id text NOT NULL,
+					url text NOT NULL,
+					secret text NOT NULL,
+					PRIMARY KEY (workspace_id, url)
+				);
+				CREATE TABLE IF NOT EXISTS webhook_deliveries (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					event_id text NOT NULL,
+					workspace_id text NOT NULL,
+					url text NOT NULL,
+					event text NOT NULL,
+					game_id text NOT NULL,
+					attempt integer NOT NULL,
+					status_code integer NOT NULL,
+					error text NOT NULL,
+					created_at timestamp NOT NULL
+				);
+			`,
+		},
+	},
+}
+
+// SqliteStore is an implementation of the WebhookStorage interface that persists using sqlite3
+type SqliteStore struct {
+	path string
+	db   *sql.DB
+}
+
+// NewSqliteStore creates (if not exists) the DB file at the path specified and migrates its structure
+// It implements the WebhookStorage interface and is intended as a suitable
+// perminent storage of webhooks and their delivery log
+func NewSqliteStore(path string) (*SqliteStore, error) {
+	store := SqliteStore{
+		path: path,
+	}
+	db, err := sql.Open("sqlite3", store.path)
+	if err != nil {
+		return nil, err
+	}
+	if err = migration.Apply(db, migration.Sqlite, SqliteMigrations); err != nil {
+		return nil, err
+	}
+	store.db = db
+	return &store, nil
+}
+
+// StoreWebhook registers a webhook, replacing the secret of an already registered URL
+func (s *SqliteStore) StoreWebhook(hook Webhook) error {
+	stmt, _ := s.db.Prepare(`
+		insert into webhooks (workspace_id, url, secret) values (?, ?, ?)
+		on conflict(workspace_id, url) do update set secret = ?
+	`)
+	defer stmt.Close()
+	_, err := stmt.Exec(hook.WorkspaceID, hook.URL, hook.Secret, hook.Secret)
+	return err
+}
+
+// RemoveWebhook unregisters the webhook of the workspace with the given URL
+func (s *SqliteStore) RemoveWebhook(workspaceID string, url string) error {
+	stmt, _ := s.db.Prepare("delete from webhooks where workspace_id = ? and url = ?")
+	defer stmt.Close()
+	_, err := stmt.Exec(workspaceID, url)
+	return err
+}
+
+// WebhooksByWorkspace returns every webhook registered by the workspace
+func (s *SqliteStore) WebhooksByWorkspace(workspaceID string) ([]Webhook, error) {
+	rows, err := s.db.Query("select url, secret from webhooks where workspace_id = ? order by url", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []Webhook{}
+	for rows.Next() {
+		hook := Webhook{WorkspaceID: workspaceID}
+		if err := rows.Scan(&hook.URL, &hook.Secret); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// LogDelivery records a delivery attempt
+func (s *SqliteStore) LogDelivery(delivery Delivery) error {
+	stmt, _ := s.db.Prepare(`
+		insert into webhook_deliveries (event_id, workspace_id, url, event, game_id, attempt, status_code, error, created_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	defer stmt.Close()
+	_, err := stmt.Exec(
+		delivery.EventID,
+		delivery.WorkspaceID,
+		delivery.URL,
+		delivery.Event,
+		delivery.GameID,
+		delivery.Attempt,
+		delivery.StatusCode,
+		delivery.Error,
+		delivery.CreatedAt)
+	return err
+}
+
+// Deliveries returns the most recent deliveries of the workspace first
+func (s *SqliteStore) Deliveries(workspaceID string, limit int) ([]Delivery, error) {
+	rows, err := s.db.Query(`
+		select event_id, url, event, game_id, attempt, status_code, error, created_at
+		from webhook_deliveries where workspace_id = ? order by id desc limit ?
+	`, workspaceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery := Delivery{WorkspaceID: workspaceID}
+		if err := rows.Scan(
+			&delivery.EventID,
+			&delivery.URL,
+			&delivery.Event,
+			&delivery.GameID,
+			&delivery.Attempt,
+			&delivery.StatusCode,
+			&delivery.Error,
+			&delivery.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// dump returns every webhook and delivery of the store, the deliveries oldest first
+func (s *SqliteStore) dump() (storedWebhooks, error) {
+	stored := storedWebhooks{}
+	rows, err := s.db.Query("select workspace_id, url, secret from webhooks")
+	if err != nil {
+		return stored, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		hook := Webhook{}
+		if err := rows.Scan(&hook.WorkspaceID, &hook.URL, &hook.Secret); err != nil {
+			return stored, err
+		}
+		stored.webhooks = append(stored.webhooks, hook)
+	}
+	if err := rows.Err(); err != nil {
+		return stored, err
+	}
+	deliveries, err := s.db.Query(`
+		select event_id, workspace_id, url, event, game_id, attempt, status_code, error, created_at
+		from webhook_deliveries order by id
+	`)
+	if err != nil {
+		return stored, err
+	}
+	defer deliveries.Close()
+	for deliveries.Next() {
+		delivery := Delivery{}
+		if err := deliveries.Scan(
+			&delivery.EventID,
+			&delivery.WorkspaceID,
+			&delivery.URL,
+			&delivery.Event,
+			&delivery.GameID,
+			&delivery.Attempt,
+			&delivery.StatusCode,
+			&delivery.Error,
+			&delivery.CreatedAt); err != nil {
+			return stored, err
+		}
+		stored.deliveries = append(stored.deliveries, delivery)
+	}
+	return stored, deliveries.Err()
+}
@@ -0,0 +1,38 @@
+package webhook
+
+import "time"
+
+// Webhook is an HTTP callback registered by a workspace to receive game events
+type Webhook struct {
+	WorkspaceID string
+	URL         string
+	Secret      string
+}
+
+// Delivery records a single attempt to deliver an event to a webhook
+type Delivery struct {
+	EventID     string
+	WorkspaceID string
+	URL         string
+	Event       string
+	GameID      string
+	Attempt     int
+	StatusCode  int
+	Error       string
+	CreatedAt   time.Time
+}
+
+// Succeeded reports whether the webhook accepted the event
+func (d Delivery) Succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// WebhookStorage interface guarentees implemented methods for webhook registration and delivery log storage
+type WebhookStorage interface {
+	StoreWebhook(hook Webhook) error
+	RemoveWebhook(workspaceID string, url string) error
+	WebhooksByWorkspace(workspaceID string) ([]Webhook, error)
+	LogDelivery(delivery Delivery) error
+	// Deliveries returns the most recent deliveries of the workspace first
+	Deliveries(workspaceID string, limit int) ([]Delivery, error)
+}